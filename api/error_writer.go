@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"strings"
 )
 
 type ErrorWriter struct {
@@ -12,16 +13,120 @@ type ErrorWriter struct {
 }
 
 var (
-	ErrUserName            = errors.New("username already exists")
-	ErrInvalidUserDetails  = errors.New("invalid user details")
-	ErrInvalidLoginDetails = errors.New("invalid username or password")
-	ErrJWTToken            = errors.New("could not generate token")
-	ErrInvalidToken        = errors.New("invalid input token")
-	ErrInvalidExpredToken  = errors.New("invalid or expired token")
-	ErrInvalidTokenClaims  = errors.New("invalid token claims")
+	ErrUserName                 = errors.New("username already exists")
+	ErrInvalidUserDetails       = errors.New("invalid user details")
+	ErrInvalidLoginDetails      = errors.New("invalid username or password")
+	ErrJWTToken                 = errors.New("could not generate token")
+	ErrInvalidToken             = errors.New("invalid input token")
+	ErrInvalidExpredToken       = errors.New("invalid or expired token")
+	ErrInvalidTokenClaims       = errors.New("invalid token claims")
+	ErrWorkoutPlanNotFound      = errors.New("workout plan not found")
+	ErrExerciseNotFound         = errors.New("exercise not found")
+	ErrExerciseExists           = errors.New("exercise already exists")
+	ErrExerciseInUse            = errors.New("exercise is used by workout plans or sessions")
+	ErrInvalidExercise          = errors.New("invalid exercise details")
+	ErrInvalidID                = errors.New("invalid id")
+	ErrSessionNotFound          = errors.New("workout session not found")
+	ErrInvalidSession           = errors.New("invalid workout session")
+	ErrInvalidSet               = errors.New("invalid logged set")
+	ErrScheduleNotFound         = errors.New("workout schedule not found")
+	ErrInvalidSchedule          = errors.New("invalid workout schedule")
+	ErrInvalidTimeZone          = errors.New("invalid time zone")
+	ErrInvalidRRule             = errors.New("invalid recurrence rule")
+	ErrInvalidTimeRange         = errors.New("invalid time range")
+	ErrInvalidStatus            = errors.New("invalid status")
+	ErrInvalidRefreshToken      = errors.New("invalid or expired refresh token")
+	ErrTokenRevoked             = errors.New("token has been revoked")
+	ErrInvalidResetToken        = errors.New("invalid or expired password reset token")
+	ErrInvalidEmail             = errors.New("invalid email address")
+	ErrInvalidVerificationToken = errors.New("invalid or expired email verification token")
+	ErrUserNotFound             = errors.New("user not found")
+	ErrInvalidRole              = errors.New("invalid role, must be one of user, coach or admin")
+	ErrEmailNotVerified         = errors.New("email address has not been verified")
+	ErrRefreshTokenReused       = errors.New("refresh token reuse detected, all sessions of this login were revoked")
+	ErrInvalidQuery             = errors.New("invalid query parameter")
+	ErrInvalidCursor            = errors.New("invalid cursor")
+	ErrForbidden                = errors.New("insufficient permissions")
+	ErrTooManyLoginAttempts     = errors.New("too many login attempts, try again later")
+	ErrInvalidImport            = errors.New("invalid import, see the errors of the rows")
+	ErrUnmappedExercises        = errors.New("exercises of the import are not in the catalog, add aliases for them or skip them")
+	ErrExerciseAliasNotFound    = errors.New("exercise alias not found")
+	ErrInvalidExerciseAlias     = errors.New("invalid exercise alias")
+	ErrMailNotConfigured        = errors.New("mail delivery is not configured")
 )
 
-func writeError(w http.ResponseWriter, code int, message string) {
+// Errors lists the errors above. Clients use it to map the message of an
+// error response back to the error.
+var Errors = []error{
+	ErrUserName,
+	ErrInvalidUserDetails,
+	ErrInvalidLoginDetails,
+	ErrJWTToken,
+	ErrInvalidToken,
+	ErrInvalidExpredToken,
+	ErrInvalidTokenClaims,
+	ErrWorkoutPlanNotFound,
+	ErrExerciseNotFound,
+	ErrExerciseExists,
+	ErrExerciseInUse,
+	ErrInvalidExercise,
+	ErrInvalidID,
+	ErrSessionNotFound,
+	ErrInvalidSession,
+	ErrInvalidSet,
+	ErrScheduleNotFound,
+	ErrInvalidSchedule,
+	ErrInvalidTimeZone,
+	ErrInvalidRRule,
+	ErrInvalidTimeRange,
+	ErrInvalidStatus,
+	ErrInvalidRefreshToken,
+	ErrTokenRevoked,
+	ErrInvalidResetToken,
+	ErrInvalidEmail,
+	ErrInvalidVerificationToken,
+	ErrUserNotFound,
+	ErrInvalidRole,
+	ErrEmailNotVerified,
+	ErrRefreshTokenReused,
+	ErrInvalidQuery,
+	ErrInvalidCursor,
+	ErrForbidden,
+	ErrTooManyLoginAttempts,
+	ErrInvalidImport,
+	ErrUnmappedExercises,
+	ErrExerciseAliasNotFound,
+	ErrInvalidExerciseAlias,
+	ErrMailNotConfigured,
+}
+
+// FromMessage returns the error of Errors an ErrorMessage of an ErrorWriter
+// was written for, also when it was wrapped with details, and nil when there
+// is none.
+func FromMessage(message string) error {
+	// Drop the status, "Bad Request: " or "Not Found: "
+	if _, text, ok := strings.Cut(message, ": "); ok {
+		message = text
+	}
+	for _, err := range Errors {
+		if message == err.Error() || strings.HasPrefix(message, err.Error()+": ") {
+			return err
+		}
+	}
+	return nil
+}
+
+// ErrorRecorder is implemented by response writers that keep the error an
+// error response was written for, such as the one of the access log.
+type ErrorRecorder interface {
+	RecordError(err error)
+}
+
+func writeError(w http.ResponseWriter, code int, prefix string, err error) {
+	if recorder, ok := w.(ErrorRecorder); ok {
+		recorder.RecordError(err)
+	}
+	message := prefix + err.Error()
 	w.WriteHeader(code)
 	w.Header().Set("Content-Type", "application/json")
 	errorResponse := ErrorWriter{
@@ -33,18 +138,39 @@ func writeError(w http.ResponseWriter, code int, message string) {
 
 var (
 	InternalServerError = func(w http.ResponseWriter, err error) {
-		writeError(w, http.StatusInternalServerError, "Internal Server Error: "+err.Error())
+		writeError(w, http.StatusInternalServerError, "Internal Server Error: ", err)
 	}
 
 	StatusBadRequestServerError = func(w http.ResponseWriter, err error) {
-		writeError(w, http.StatusBadRequest, "Bad Request: "+err.Error())
+		writeError(w, http.StatusBadRequest, "Bad Request: ", err)
 	}
 
 	DatabaseError = func(w http.ResponseWriter, err error) {
-		writeError(w, http.StatusInternalServerError, "Database Error: "+err.Error())
+		writeError(w, http.StatusInternalServerError, "Database Error: ", err)
+	}
+
+	UnauthorizedError = func(w http.ResponseWriter, err error) {
+		writeError(w, http.StatusUnauthorized, "Unauthorized: ", err)
+	}
+
+	NotFoundError = func(w http.ResponseWriter, err error) {
+		writeError(w, http.StatusNotFound, "Not Found: ", err)
+	}
+
+	ForbiddenError = func(w http.ResponseWriter, err error) {
+		writeError(w, http.StatusForbidden, "Forbidden: ", err)
+	}
+
+	// TooManyRequestsError should be preceded by a Retry-After header.
+	TooManyRequestsError = func(w http.ResponseWriter, err error) {
+		writeError(w, http.StatusTooManyRequests, "Too Many Requests: ", err)
+	}
+
+	ServiceUnavailableError = func(w http.ResponseWriter, err error) {
+		writeError(w, http.StatusServiceUnavailable, "Service Unavailable: ", err)
 	}
 
 	RequestBodyError = func(w http.ResponseWriter, err error) {
-		writeError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		writeError(w, http.StatusBadRequest, "Invalid request body: ", err)
 	}
 )
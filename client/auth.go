@@ -0,0 +1,91 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// token is the response of a login or refresh.
+type token struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int    `json:"expires_in,omitempty"`
+}
+
+// Register creates a user. Depending on the server the email address has to
+// be verified before the user can log in.
+func (c *Client) Register(ctx context.Context, username, password, email string) error {
+	body := map[string]string{"username": username, "password": password, "email": email}
+	return c.do(ctx, http.MethodPost, "/auth/register", body, nil, false)
+}
+
+// Login logs the user in. The client uses and refreshes the returned tokens
+// from then on.
+func (c *Client) Login(ctx context.Context, username, password string) (Tokens, error) {
+	body := map[string]string{"username": username, "password": password}
+	var t token
+	if err := c.do(ctx, http.MethodPost, "/auth/login", body, &t, false); err != nil {
+		return Tokens{}, err
+	}
+	return c.useToken(t), nil
+}
+
+// Refresh exchanges the refresh token for new tokens. Requests do this on
+// their own shortly before the access token expires and when the server
+// rejects it.
+func (c *Client) Refresh(ctx context.Context) error {
+	return c.refresh(ctx, "")
+}
+
+// refresh refreshes the tokens unless they already replaced the access token
+// stale, which another request refreshed in the meantime. Refresh tokens can
+// be used only once, the server revokes all tokens of a login that uses one
+// twice, so refreshes never run concurrently.
+func (c *Client) refresh(ctx context.Context, stale string) error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	tokens := c.Tokens()
+	if stale != "" && tokens.AccessToken != stale {
+		return nil
+	}
+	var t token
+	body := map[string]string{"refresh_token": tokens.RefreshToken}
+	if err := c.do(ctx, http.MethodPost, "/auth/refresh", body, &t, false); err != nil {
+		return err
+	}
+	c.useToken(t)
+	return nil
+}
+
+// Logout revokes the access token and the refresh token and forgets them.
+func (c *Client) Logout(ctx context.Context) error {
+	body := map[string]string{"refresh_token": c.Tokens().RefreshToken}
+	if err := c.do(ctx, http.MethodPost, "/auth/logout", body, nil, true); err != nil {
+		return err
+	}
+	c.SetTokens(Tokens{})
+	return nil
+}
+
+// LogoutAll revokes the tokens of every login of the user.
+func (c *Client) LogoutAll(ctx context.Context) error {
+	if err := c.do(ctx, http.MethodPost, "/auth/logout-all", nil, nil, true); err != nil {
+		return err
+	}
+	c.SetTokens(Tokens{})
+	return nil
+}
+
+func (c *Client) useToken(t token) Tokens {
+	tokens := Tokens{AccessToken: t.Token, RefreshToken: t.RefreshToken}
+	if t.ExpiresIn > 0 {
+		tokens.ExpiresAt = time.Now().Add(time.Duration(t.ExpiresIn) * time.Second)
+	}
+	c.SetTokens(tokens)
+	if c.OnTokens != nil {
+		c.OnTokens(tokens)
+	}
+	return tokens
+}
@@ -0,0 +1,181 @@
+// Package client calls the workout tracker API. A Client logs in once and
+// then keeps its access token fresh with the refresh token, retries
+// idempotent requests that failed with a server error and returns error
+// responses as *Error values that match the api.Err* errors:
+//
+//	c := client.New("http://localhost:8080")
+//	if _, err := c.Login(ctx, "alice", "secret"); errors.Is(err, api.ErrInvalidLoginDetails) {
+//		...
+//	}
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// refreshMargin is how long before it expires an access token is refreshed,
+// so that it does not expire on the way to the server.
+const refreshMargin = 30 * time.Second
+
+// Tokens are the tokens of a login. ExpiresAt is when the access token
+// expires, zero when unknown.
+type Tokens struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	ExpiresAt    time.Time `json:"expires_at,omitempty"`
+}
+
+// Client calls the API at BaseURL. The fields may be changed before the
+// first request; a Client is safe for concurrent use after that.
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	// MaxRetries is how often GET, PUT and DELETE requests are retried after
+	// a server error or a failed connection. POST requests are never retried,
+	// a retry could log the same session twice.
+	MaxRetries int
+	// Backoff is the wait before the first retry. It doubles with every
+	// further retry and is jittered.
+	Backoff time.Duration
+	// OnTokens, when set, is called with the new tokens after every login
+	// and refresh, for example to save them.
+	OnTokens func(Tokens)
+
+	mu        sync.Mutex
+	tokens    Tokens
+	refreshMu sync.Mutex
+}
+
+// New returns a Client for the API at baseURL, such as
+// http://localhost:8080, that retries three times.
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		HTTPClient: http.DefaultClient,
+		MaxRetries: 3,
+		Backoff:    200 * time.Millisecond,
+	}
+}
+
+// SetTokens makes the client use tokens of an earlier login.
+func (c *Client) SetTokens(tokens Tokens) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tokens = tokens
+}
+
+// Tokens returns the tokens the client currently uses.
+func (c *Client) Tokens() Tokens {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tokens
+}
+
+// do sends a request with in as the JSON body, unless it is nil, and
+// decodes the JSON response into out, unless it is nil. Authenticated
+// requests refresh an expiring access token first and once more when the
+// server rejects it.
+func (c *Client) do(ctx context.Context, method, path string, in, out any, authenticated bool) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+
+	accessToken := ""
+	if authenticated {
+		if err := c.refreshIfExpiring(ctx); err != nil {
+			return err
+		}
+		accessToken = c.Tokens().AccessToken
+	}
+	response, err := c.send(ctx, method, path, body, accessToken)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode == http.StatusUnauthorized && authenticated && c.Tokens().RefreshToken != "" {
+		response.Body.Close()
+		if err := c.refresh(ctx, accessToken); err != nil {
+			return err
+		}
+		if response, err = c.send(ctx, method, path, body, c.Tokens().AccessToken); err != nil {
+			return err
+		}
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= http.StatusBadRequest {
+		return newError(response)
+	}
+	if out == nil {
+		io.Copy(io.Discard, response.Body)
+		return nil
+	}
+	return json.NewDecoder(response.Body).Decode(out)
+}
+
+// send sends a request, with accessToken as the bearer token unless it is
+// empty, and retries idempotent ones that fail with a server error or before
+// reaching the server.
+func (c *Client) send(ctx context.Context, method, path string, body []byte, accessToken string) (*http.Response, error) {
+	retries := 0
+	if method == http.MethodGet || method == http.MethodPut || method == http.MethodDelete {
+		retries = c.MaxRetries
+	}
+
+	backoff := c.Backoff
+	for attempt := 0; ; attempt++ {
+		request, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		if body != nil {
+			request.Header.Set("Content-Type", "application/json")
+		}
+		request.Header.Set("Accept", "application/json")
+		if accessToken != "" {
+			request.Header.Set("Authorization", "Bearer "+accessToken)
+		}
+
+		response, err := c.HTTPClient.Do(request)
+		if attempt == retries || ctx.Err() != nil || (err == nil && response.StatusCode < http.StatusInternalServerError) {
+			return response, err
+		}
+		if err == nil {
+			io.Copy(io.Discard, response.Body)
+			response.Body.Close()
+		}
+
+		// Jitter keeps clients that failed together from retrying together
+		wait := backoff
+		if backoff > 0 {
+			wait = backoff/2 + rand.N(backoff)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+func (c *Client) refreshIfExpiring(ctx context.Context) error {
+	tokens := c.Tokens()
+	if tokens.RefreshToken == "" || tokens.ExpiresAt.IsZero() || time.Until(tokens.ExpiresAt) > refreshMargin {
+		return nil
+	}
+	return c.refresh(ctx, tokens.AccessToken)
+}
@@ -0,0 +1,266 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Oriseer/workout_tracker/api"
+	"github.com/Oriseer/workout_tracker/config"
+	tracker "github.com/Oriseer/workout_tracker/internal"
+)
+
+func newTestServer(t *testing.T) *Client {
+	t.Helper()
+	cfg := config.Default()
+	cfg.JWT.Key = "test-key"
+	store := tracker.NewInMemoryStore()
+	if _, err := store.AddExercise(tracker.Exercise{Name: "pushup", Category: "strength"}); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(tracker.NewWorkoutServer(store, cfg))
+	t.Cleanup(server.Close)
+
+	c := New(server.URL)
+	c.Backoff = time.Millisecond
+	return c
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	c := newTestServer(t)
+
+	if err := c.Register(ctx, "alice", "testpass", "alice@example.com"); err != nil {
+		t.Fatalf("Expected to register, got %v", err)
+	}
+	if err := c.Register(ctx, "alice", "testpass", "alice@example.com"); !errors.Is(err, api.ErrUserName) {
+		t.Errorf("Expected %v, got %v", api.ErrUserName, err)
+	}
+	if _, err := c.Login(ctx, "alice", "wrong"); !errors.Is(err, api.ErrInvalidLoginDetails) {
+		t.Errorf("Expected %v, got %v", api.ErrInvalidLoginDetails, err)
+	}
+	var saved Tokens
+	c.OnTokens = func(tokens Tokens) { saved = tokens }
+	tokens, err := c.Login(ctx, "alice", "testpass")
+	if err != nil {
+		t.Fatalf("Expected to log in, got %v", err)
+	}
+	if tokens.AccessToken == "" || tokens.RefreshToken == "" || tokens.ExpiresAt.IsZero() || saved != tokens {
+		t.Fatalf("Expected saved tokens that expire, got %+v and saved %+v", tokens, saved)
+	}
+
+	t.Run("manages workout plans", func(t *testing.T) {
+		for _, sets := range []int{3, 5} {
+			if err := c.CreatePlan(ctx, WorkoutPlan{ExerciseName: "pushup", Repetitions: 10, Sets: sets, Weight: 20}); err != nil {
+				t.Fatalf("Expected to create a plan, got %v", err)
+			}
+		}
+		if err := c.CreatePlan(ctx, WorkoutPlan{ExerciseName: "unknown"}); !errors.Is(err, api.ErrExerciseNotFound) {
+			t.Errorf("Expected %v, got %v", api.ErrExerciseNotFound, err)
+		}
+
+		plans, err := c.AllPlans(ctx, PlanQuery{Limit: 1, Sort: "sets", Order: "desc"})
+		if err != nil {
+			t.Fatalf("Expected plans, got %v", err)
+		}
+		if len(plans) != 2 || plans[0].Sets != 5 || plans[0].ExerciseName != "pushup" {
+			t.Errorf("Expected both plans by sets descending, got %+v", plans)
+		}
+		minSets := 4
+		page, err := c.ListPlans(ctx, PlanQuery{MinSets: &minSets})
+		if err != nil || len(page.WorkoutPlans) != 1 {
+			t.Errorf("Expected one plan with at least 4 sets, got %+v, %v", page, err)
+		}
+
+		if err := c.DeletePlan(ctx, "squat"); !errors.Is(err, api.ErrWorkoutPlanNotFound) {
+			t.Errorf("Expected %v, got %v", api.ErrWorkoutPlanNotFound, err)
+		}
+		var apiErr *Error
+		if err := c.DeletePlan(ctx, "squat"); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+			t.Errorf("Expected a 404 *Error, got %v", err)
+		}
+	})
+
+	t.Run("logs sessions and reports on them", func(t *testing.T) {
+		startedAt := time.Date(2024, 3, 4, 18, 0, 0, 0, time.UTC)
+		session, err := c.LogSession(ctx, Session{StartedAt: startedAt, Exercises: []SessionExercise{
+			{ExerciseName: "pushup", Sets: []Set{{Reps: 10, Weight: 20}}},
+		}})
+		if err != nil {
+			t.Fatalf("Expected to log a session, got %v", err)
+		}
+		if _, err := c.LogSet(ctx, session.Id, SetInput{ExerciseName: "pushup", Set: Set{Reps: 12, Weight: 20}}); err != nil {
+			t.Fatalf("Expected to log a set, got %v", err)
+		}
+		got, err := c.GetSession(ctx, session.Id)
+		if err != nil || len(got.Exercises) != 1 || len(got.Exercises[0].Sets) != 2 {
+			t.Errorf("Expected the session with two sets, got %+v, %v", got, err)
+		}
+
+		report, err := c.Progress(ctx, ReportFilter{From: startedAt.AddDate(0, 0, -1)})
+		if err != nil || len(report) != 1 || report[0].TotalReps != 22 || report[0].SetCount != 2 {
+			t.Errorf("Expected 22 reps in 2 sets, got %+v, %v", report, err)
+		}
+		weekly, err := c.WeeklyProgress(ctx, ReportFilter{})
+		if err != nil || len(weekly) != 1 || !weekly[0].WeekStart.Equal(startedAt.Truncate(24*time.Hour)) {
+			t.Errorf("Expected the week of the session, got %+v, %v", weekly, err)
+		}
+		if _, err := c.Progress(ctx, ReportFilter{From: startedAt, To: startedAt}); !errors.Is(err, api.ErrInvalidTimeRange) {
+			t.Errorf("Expected %v, got %v", api.ErrInvalidTimeRange, err)
+		}
+
+		if err := c.DeleteSession(ctx, session.Id); err != nil {
+			t.Errorf("Expected to delete the session, got %v", err)
+		}
+		if _, err := c.GetSession(ctx, session.Id); !errors.Is(err, api.ErrSessionNotFound) {
+			t.Errorf("Expected %v, got %v", api.ErrSessionNotFound, err)
+		}
+	})
+
+	t.Run("refreshes expiring tokens once", func(t *testing.T) {
+		expiring := c.Tokens()
+		expiring.ExpiresAt = time.Now()
+		c.SetTokens(expiring)
+
+		// A refresh token used twice revokes the login, so concurrent
+		// requests must share one refresh
+		var wg sync.WaitGroup
+		errs := make(chan error, 5)
+		for range 5 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := c.ListSessions(ctx)
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				t.Errorf("Expected requests with a refreshed token, got %v", err)
+			}
+		}
+		if c.Tokens().RefreshToken == expiring.RefreshToken {
+			t.Error("Expected the tokens to be refreshed")
+		}
+	})
+
+	t.Run("logs out", func(t *testing.T) {
+		if err := c.Logout(ctx); err != nil {
+			t.Fatalf("Expected to log out, got %v", err)
+		}
+		if c.Tokens() != (Tokens{}) {
+			t.Errorf("Expected the tokens to be forgotten, got %+v", c.Tokens())
+		}
+	})
+}
+
+func TestClientRefreshesRejectedTokens(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.Default()
+	cfg.JWT.Key = "test-key"
+	cfg.JWT.AccessTTL = time.Second
+	store := tracker.NewInMemoryStore()
+	store.AddUser(tracker.UserDetails{Username: "alice", Password: "testpass", Email: "alice@example.com"})
+	server := httptest.NewServer(tracker.NewWorkoutServer(store, cfg))
+	defer server.Close()
+
+	c := New(server.URL)
+	tokens, err := c.Login(ctx, "alice", "testpass")
+	if err != nil {
+		t.Fatalf("Expected to log in, got %v", err)
+	}
+	// Without an expiry the client cannot refresh ahead of time, it only
+	// learns that the token expired from the server
+	tokens.ExpiresAt = time.Time{}
+	time.Sleep(1100 * time.Millisecond)
+
+	request, _ := http.NewRequest(http.MethodGet, server.URL+"/sessions", nil)
+	request.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Expected the expired token to be rejected with %d, got %d", http.StatusUnauthorized, response.StatusCode)
+	}
+
+	c.SetTokens(tokens)
+	if _, err := c.ListSessions(ctx); err != nil {
+		t.Fatalf("Expected the request to succeed after a refresh, got %v", err)
+	}
+	if refreshed := c.Tokens(); refreshed.AccessToken == tokens.AccessToken || refreshed.RefreshToken == tokens.RefreshToken {
+		t.Errorf("Expected the tokens to be refreshed, got %+v", refreshed)
+	}
+
+	c.SetTokens(Tokens{AccessToken: tokens.AccessToken})
+	if _, err := c.ListSessions(ctx); !errors.Is(err, api.ErrInvalidExpredToken) {
+		t.Errorf("Expected %v without a refresh token, got %v", api.ErrInvalidExpredToken, err)
+	}
+}
+
+func TestClientRetries(t *testing.T) {
+	newServer := func(failures int32) (*Client, *atomic.Int32) {
+		var requests atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if requests.Add(1) <= failures {
+				api.DatabaseError(w, errors.New("connection refused"))
+				return
+			}
+			w.Write([]byte(`[]`))
+		}))
+		t.Cleanup(server.Close)
+		c := New(server.URL)
+		c.Backoff = time.Millisecond
+		c.SetTokens(Tokens{AccessToken: "token"})
+		return c, &requests
+	}
+
+	t.Run("retries server errors of idempotent requests", func(t *testing.T) {
+		c, requests := newServer(2)
+		if _, err := c.ListSessions(context.Background()); err != nil {
+			t.Errorf("Expected the third attempt to succeed, got %v", err)
+		}
+		if requests.Load() != 3 {
+			t.Errorf("Expected 3 requests, got %d", requests.Load())
+		}
+	})
+
+	t.Run("gives up after MaxRetries", func(t *testing.T) {
+		c, requests := newServer(10)
+		var apiErr *Error
+		if _, err := c.ListSessions(context.Background()); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError {
+			t.Errorf("Expected a 500 *Error, got %v", err)
+		}
+		if requests.Load() != 4 {
+			t.Errorf("Expected 4 requests, got %d", requests.Load())
+		}
+	})
+
+	t.Run("does not retry POST requests", func(t *testing.T) {
+		c, requests := newServer(1)
+		if _, err := c.LogSession(context.Background(), Session{}); err == nil {
+			t.Error("Expected the server error")
+		}
+		if requests.Load() != 1 {
+			t.Errorf("Expected 1 request, got %d", requests.Load())
+		}
+	})
+
+	t.Run("stops waiting when the context ends", func(t *testing.T) {
+		c, _ := newServer(10)
+		c.Backoff = time.Hour
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if _, err := c.ListSessions(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected %v, got %v", context.DeadlineExceeded, err)
+		}
+	})
+}
@@ -0,0 +1,54 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/Oriseer/workout_tracker/api"
+)
+
+// Error is an error response of the API. Err is the api.Err* error the
+// server answered with, nil when the message matches none of them, so
+//
+//	errors.Is(err, api.ErrWorkoutPlanNotFound)
+//
+// tells why a request failed.
+type Error struct {
+	StatusCode int
+	Message    string
+	Err        error
+	// RetryAfter is the Retry-After header of 429 responses, in seconds.
+	RetryAfter string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// newError reads an api.ErrorWriter body. Bodies of other servers, such as
+// a proxy in front of the API, are kept as the message.
+func newError(response *http.Response) *Error {
+	body, _ := io.ReadAll(io.LimitReader(response.Body, 64<<10))
+	e := &Error{
+		StatusCode: response.StatusCode,
+		RetryAfter: response.Header.Get("Retry-After"),
+	}
+
+	var written api.ErrorWriter
+	if err := json.Unmarshal(body, &written); err == nil && written.ErrorMessage != "" {
+		e.Message = written.ErrorMessage
+		e.Err = api.FromMessage(written.ErrorMessage)
+	} else {
+		e.Message = string(body)
+	}
+	return e
+}
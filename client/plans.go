@@ -0,0 +1,122 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// WorkoutPlan plans sets of a catalog exercise, given by ExerciseId or, when
+// no id is set, by ExerciseName.
+type WorkoutPlan struct {
+	Id           int    `json:"Id,omitempty"`
+	ExerciseId   int    `json:"ExerciseId,omitempty"`
+	ExerciseName string `json:"ExerciseName"`
+	Repetitions  int    `json:"Repetitions"`
+	Sets         int    `json:"Sets"`
+	Weight       int    `json:"Weight"`
+}
+
+// PlanQuery filters and sorts the workout plans. Nil bounds and empty
+// fields do not filter.
+type PlanQuery struct {
+	// ExerciseName matches plans whose exercise name contains it, ignoring
+	// case.
+	ExerciseName string
+	MinWeight    *int
+	MaxWeight    *int
+	MinReps      *int
+	MaxReps      *int
+	MinSets      *int
+	MaxSets      *int
+	// Sort is id, exercise_name, weight, reps or sets and Order asc or desc.
+	Sort  string
+	Order string
+	// Limit is the page size, between 1 and 200.
+	Limit int
+	// Cursor is the NextCursor of the previous page.
+	Cursor string
+}
+
+// PlanPage is a page of workout plans. NextCursor is empty on the last page.
+type PlanPage struct {
+	WorkoutPlans []WorkoutPlan `json:"workout_plans"`
+	NextCursor   string        `json:"next_cursor,omitempty"`
+}
+
+func (q PlanQuery) values() url.Values {
+	values := url.Values{}
+	set := func(key, value string) {
+		if value != "" {
+			values.Set(key, value)
+		}
+	}
+	bound := func(key string, value *int) {
+		if value != nil {
+			values.Set(key, strconv.Itoa(*value))
+		}
+	}
+	set("exercise_name", q.ExerciseName)
+	bound("min_weight", q.MinWeight)
+	bound("max_weight", q.MaxWeight)
+	bound("min_reps", q.MinReps)
+	bound("max_reps", q.MaxReps)
+	bound("min_sets", q.MinSets)
+	bound("max_sets", q.MaxSets)
+	set("sort", q.Sort)
+	set("order", q.Order)
+	if q.Limit > 0 {
+		values.Set("limit", strconv.Itoa(q.Limit))
+	}
+	set("cursor", q.Cursor)
+	return values
+}
+
+// ListPlans returns a page of the user's workout plans.
+func (c *Client) ListPlans(ctx context.Context, query PlanQuery) (PlanPage, error) {
+	var page PlanPage
+	err := c.do(ctx, http.MethodGet, withQuery("/workouts", query.values()), nil, &page, true)
+	return page, err
+}
+
+// AllPlans returns every workout plan that matches query, reading page after
+// page.
+func (c *Client) AllPlans(ctx context.Context, query PlanQuery) ([]WorkoutPlan, error) {
+	plans := []WorkoutPlan{}
+	for {
+		page, err := c.ListPlans(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, page.WorkoutPlans...)
+		if page.NextCursor == "" {
+			return plans, nil
+		}
+		query.Cursor = page.NextCursor
+	}
+}
+
+// CreatePlan adds a workout plan. It fails with api.ErrExerciseNotFound when
+// the exercise is not in the catalog.
+func (c *Client) CreatePlan(ctx context.Context, plan WorkoutPlan) error {
+	return c.do(ctx, http.MethodPost, "/workout-plans/", plan, nil, true)
+}
+
+// UpdatePlan replaces the workout plan of the exercise. It fails with
+// api.ErrWorkoutPlanNotFound when the user has no plan for it.
+func (c *Client) UpdatePlan(ctx context.Context, plan WorkoutPlan) error {
+	return c.do(ctx, http.MethodPut, "/workout-plans/", plan, nil, true)
+}
+
+// DeletePlan deletes the workout plan of the exercise named name.
+func (c *Client) DeletePlan(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, "/workout-plans/"+url.PathEscape(name), nil, nil, true)
+}
+
+func withQuery(path string, values url.Values) string {
+	if len(values) == 0 {
+		return path
+	}
+	return path + "?" + values.Encode()
+}
@@ -0,0 +1,70 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReportFilter limits reports to sessions started in [From, To) and to the
+// given exercises. Zero values do not filter.
+type ReportFilter struct {
+	From        time.Time
+	To          time.Time
+	ExerciseIds []int
+}
+
+// ExerciseProgress aggregates the logged sets of one exercise. The best set
+// is the one with the highest estimated one rep max.
+type ExerciseProgress struct {
+	ExerciseId         int     `json:"exercise_id"`
+	ExerciseName       string  `json:"exercise_name"`
+	SessionCount       int     `json:"session_count"`
+	SetCount           int     `json:"set_count"`
+	TotalReps          int     `json:"total_reps"`
+	TotalVolume        float64 `json:"total_volume"`
+	BestSetReps        int     `json:"best_set_reps"`
+	BestSetWeight      float64 `json:"best_set_weight"`
+	EstimatedOneRepMax float64 `json:"estimated_one_rep_max"`
+}
+
+// WeeklyProgress is ExerciseProgress for the ISO week starting on WeekStart.
+type WeeklyProgress struct {
+	WeekStart time.Time `json:"week_start"`
+	ExerciseProgress
+}
+
+func (f ReportFilter) values() url.Values {
+	values := url.Values{}
+	if !f.From.IsZero() {
+		values.Set("from", f.From.Format(time.RFC3339))
+	}
+	if !f.To.IsZero() {
+		values.Set("to", f.To.Format(time.RFC3339))
+	}
+	if len(f.ExerciseIds) > 0 {
+		ids := make([]string, len(f.ExerciseIds))
+		for i, id := range f.ExerciseIds {
+			ids[i] = strconv.Itoa(id)
+		}
+		values.Set("exercise_id", strings.Join(ids, ","))
+	}
+	return values
+}
+
+// Progress reports the progress per exercise.
+func (c *Client) Progress(ctx context.Context, filter ReportFilter) ([]ExerciseProgress, error) {
+	var report []ExerciseProgress
+	err := c.do(ctx, http.MethodGet, withQuery("/reports/progress", filter.values()), nil, &report, true)
+	return report, err
+}
+
+// WeeklyProgress reports the progress per exercise and week.
+func (c *Client) WeeklyProgress(ctx context.Context, filter ReportFilter) ([]WeeklyProgress, error) {
+	var report []WeeklyProgress
+	err := c.do(ctx, http.MethodGet, withQuery("/reports/progress/weekly", filter.values()), nil, &report, true)
+	return report, err
+}
@@ -0,0 +1,87 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Session is a workout that was performed, optionally following the plan
+// PlanId.
+type Session struct {
+	Id        int               `json:"id,omitempty"`
+	PlanId    *int              `json:"plan_id,omitempty"`
+	Plan      *WorkoutPlan      `json:"plan,omitempty"`
+	StartedAt time.Time         `json:"started_at"`
+	EndedAt   *time.Time        `json:"ended_at,omitempty"`
+	Notes     string            `json:"notes"`
+	Exercises []SessionExercise `json:"exercises"`
+}
+
+// SessionExercise is a catalog exercise performed during a session, given by
+// ExerciseId or, when no id is set, by ExerciseName.
+type SessionExercise struct {
+	Id           int    `json:"id,omitempty"`
+	ExerciseId   int    `json:"exercise_id,omitempty"`
+	ExerciseName string `json:"exercise_name"`
+	Sets         []Set  `json:"sets"`
+}
+
+// Set is a set logged for an exercise of a session.
+type Set struct {
+	Id     int      `json:"id,omitempty"`
+	Reps   int      `json:"reps"`
+	Weight float64  `json:"weight"`
+	RPE    *float64 `json:"rpe,omitempty"`
+	Notes  string   `json:"notes"`
+}
+
+// SetInput is a set of the exercise given by ExerciseId or ExerciseName.
+type SetInput struct {
+	ExerciseId   int    `json:"exercise_id,omitempty"`
+	ExerciseName string `json:"exercise_name"`
+	Set
+}
+
+// ListSessions returns the user's workout sessions.
+func (c *Client) ListSessions(ctx context.Context) ([]Session, error) {
+	var sessions []Session
+	err := c.do(ctx, http.MethodGet, "/sessions", nil, &sessions, true)
+	return sessions, err
+}
+
+// GetSession returns a session with the plan it followed.
+func (c *Client) GetSession(ctx context.Context, id int) (Session, error) {
+	var session Session
+	err := c.do(ctx, http.MethodGet, sessionPath(id), nil, &session, true)
+	return session, err
+}
+
+// LogSession adds a session and returns it with the ids it was given.
+func (c *Client) LogSession(ctx context.Context, session Session) (Session, error) {
+	var logged Session
+	err := c.do(ctx, http.MethodPost, "/sessions", session, &logged, true)
+	return logged, err
+}
+
+// UpdateSession replaces the session session.Id.
+func (c *Client) UpdateSession(ctx context.Context, session Session) error {
+	return c.do(ctx, http.MethodPut, sessionPath(session.Id), session, nil, true)
+}
+
+// DeleteSession deletes the session id with its sets.
+func (c *Client) DeleteSession(ctx context.Context, id int) error {
+	return c.do(ctx, http.MethodDelete, sessionPath(id), nil, nil, true)
+}
+
+// LogSet adds one more set to the session id.
+func (c *Client) LogSet(ctx context.Context, id int, set SetInput) (Set, error) {
+	var logged Set
+	err := c.do(ctx, http.MethodPost, sessionPath(id)+"/sets", set, &logged, true)
+	return logged, err
+}
+
+func sessionPath(id int) string {
+	return "/sessions/" + strconv.Itoa(id)
+}
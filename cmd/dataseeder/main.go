@@ -1,18 +1,47 @@
 package main
 
 import (
-	sqlx "github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/Oriseer/workout_tracker/api"
+	"github.com/Oriseer/workout_tracker/config"
+	tracker "github.com/Oriseer/workout_tracker/internal"
 )
 
-func main() {
-	db := sqlx.MustConnect("postgres", "user=khel dbname=khel sslmode=disable password=plsqlkhel")
+const usage = `Usage: dataseeder [flags]
+
+Adds the demo exercises to the exercise catalog of the database.
 
-	tx := db.MustBegin()
+Flags:
+`
+
+// The dataseeder fills the exercise catalog of the configured database with
+// the demo exercises. Exercises that already exist are left alone.
+func main() {
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Fprint(os.Stderr, usage+config.Usage())
+		os.Exit(0)
+	} else if err != nil {
+		log.Fatal(err)
+	}
+	db, err := tracker.NewDatabase(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
 
-	tx.MustExec("INSERT INTO EXERCISES (id, exercise_name, description, category) VALUES ($1, $2, $3, $4)", 1, "pushup", "bodyweight exercise", "strength")
-	tx.MustExec("INSERT INTO EXERCISES (id, exercise_name, description, category) VALUES ($1, $2, $3, $4)", 2, "pullup", "bodyweight exercise", "strength")
-	tx.MustExec("INSERT INTO EXERCISES (id, exercise_name, description, category) VALUES ($1, $2, $3, $4)", 3, "curlup", "bodyweight exercise", "strength")
-	tx.MustExec("INSERT INTO EXERCISES (id, exercise_name, description, category) VALUES ($1, $2, $3, $4)", 4, "bench press", "barbell", "strength")
-	tx.Commit()
+	for _, exercise := range tracker.DemoExercises {
+		_, err := db.AddExercise(exercise)
+		if err == api.ErrExerciseExists {
+			continue
+		} else if err != nil {
+			log.Fatalf("%s: %v", exercise.Name, err)
+		}
+		fmt.Println("added", exercise.Name)
+	}
 }
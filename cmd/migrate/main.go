@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/Oriseer/workout_tracker/config"
+	tracker "github.com/Oriseer/workout_tracker/internal"
+)
+
+const usage = `Usage: migrate [flags] <command>
+
+Commands:
+  up            apply all pending migrations
+  down          revert the most recently applied migration
+  status        list migrations and when they were applied
+  to VERSION    migrate up or down to VERSION (0 reverts everything)
+
+Flags:
+`
+
+func main() {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, usage+config.Usage()) }
+	cfg, err := config.LoadFlags(flags, os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	db, err := tracker.OpenDatabase(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	migrator, err := tracker.NewMigrator(db.DB)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch flags.Arg(0) {
+	case "up":
+		err = migrator.Up()
+	case "down":
+		err = migrator.Down()
+	case "status":
+		err = printStatus(migrator)
+	case "to":
+		if flags.NArg() != 2 {
+			flags.Usage()
+			os.Exit(2)
+		}
+		version, convErr := strconv.Atoi(flags.Arg(1))
+		if convErr != nil {
+			log.Fatalf("invalid version %q", flags.Arg(1))
+		}
+		err = migrator.To(version)
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func printStatus(migrator *tracker.Migrator) error {
+	statuses, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+	for _, status := range statuses {
+		appliedAt := "pending"
+		if status.AppliedAt != nil {
+			appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/Oriseer/workout_tracker/config"
+	tracker "github.com/Oriseer/workout_tracker/internal"
+	"github.com/Oriseer/workout_tracker/middleware"
+)
+
+const usage = `Usage: promote [-role ROLE] [flags] USERNAME
+
+Gives a registered user a role, admin unless -role is given. Use it to
+bootstrap the first admin, who can then manage roles through the API.
+
+Flags:
+  -role ROLE
+    	one of user, coach or admin (default admin)
+`
+
+func main() {
+	flags := flag.NewFlagSet("promote", flag.ExitOnError)
+	role := flags.String("role", middleware.RoleAdmin, "role to give the user")
+	flags.Usage = func() { fmt.Fprint(os.Stderr, usage+config.Usage()) }
+	cfg, err := config.LoadFlags(flags, os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if !slices.Contains(middleware.Roles, *role) {
+		log.Fatalf("invalid role %q", *role)
+	}
+
+	db, err := tracker.NewDatabase(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	username := flags.Arg(0)
+	if err := db.SetUserRole(username, *role); err != nil {
+		log.Fatalf("%s: %v", username, err)
+	}
+	// Tokens carrying the previous role stop working right away
+	if err := db.RevokeUserTokens(username, time.Now()); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%s is now %s\n", username, *role)
+}
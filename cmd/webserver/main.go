@@ -1,18 +1,114 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	_ "time/tzdata"
 
+	"github.com/Oriseer/workout_tracker/config"
 	tracker "github.com/Oriseer/workout_tracker/internal"
+	"github.com/Oriseer/workout_tracker/middleware"
 )
 
+const usage = `Usage: webserver [flags]
+
+Serves the workout tracker API. Every flag can also be set in the
+environment, a .env file or the file given by -config.
+
+Flags:
+`
+
 func main() {
-	db := tracker.NewDatabase()
-	defer db.Close()
-	server := tracker.NewWorkoutServer(db)
-	fmt.Println("Starting web server on :8080")
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Fprint(os.Stderr, usage+config.Usage())
+		os.Exit(0)
+	} else if err != nil {
+		log.Fatal(err)
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	slog.SetDefault(middleware.NewLogger(cfg.Log, os.Stderr))
+
+	if err := run(cfg); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run serves the API until SIGINT or SIGTERM, then stops accepting
+// connections and gives in-flight requests cfg.Server.ShutdownTimeout to
+// finish before the store is closed.
+func run(cfg config.Config) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var store tracker.WorkoutPlanStore
+	if cfg.Store == config.StoreMemory {
+		slog.Warn("using the in-memory store, all data is lost when the server stops")
+		memory := tracker.NewInMemoryStore()
+		for _, exercise := range tracker.DemoExercises {
+			if _, err := memory.AddExercise(exercise); err != nil {
+				return err
+			}
+		}
+		store = memory
+	} else {
+		db, err := tracker.NewDatabase(cfg)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		store = db
+	}
+
+	switch cfg.Mail.Delivery() {
+	case "":
+		slog.Warn("mail delivery is not configured, password resets and email verification are disabled")
+	case config.MailLog:
+		slog.Warn("mail is written to the log, reset and verification tokens included, use it for local development only")
+	}
+
+	go tracker.PruneRevokedTokens(ctx, store, time.Hour)
+	go tracker.PruneLoginFailures(ctx, store, cfg.Auth.LockoutWindow, time.Hour)
+	server := &http.Server{
+		Addr:              cfg.Addr(),
+		Handler:           tracker.NewWorkoutServer(store, cfg).Handler,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("starting web server", "addr", cfg.Addr())
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+	// A second signal kills the server right away
+	stop()
+	slog.Info("shutting down, waiting for in-flight requests", "timeout", cfg.Server.ShutdownTimeout)
 
-	log.Fatal(http.ListenAndServe(":8080", server.Handler))
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		server.Close()
+		return fmt.Errorf("shutdown: %w", err)
+	}
+	return nil
 }
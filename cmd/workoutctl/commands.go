@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Oriseer/workout_tracker/client"
+)
+
+// cli runs the commands of usage.
+type cli struct {
+	configPath string
+	server     string
+	stdin      io.Reader
+	stderr     io.Writer
+	out        *output
+}
+
+func (c *cli) run(ctx context.Context, args []string) error {
+	command, args := args[0], args[1:]
+	if command == "plans" || command == "sessions" {
+		if len(args) == 0 {
+			return errUsage
+		}
+		command += " " + args[0]
+		args = args[1:]
+	}
+
+	switch command {
+	case "login":
+		return c.login(ctx, args)
+	case "logout":
+		return c.logout(ctx, args)
+	case "plans list":
+		return c.listPlans(ctx, args)
+	case "plans add":
+		return c.savePlan(ctx, args, false)
+	case "plans update":
+		return c.savePlan(ctx, args, true)
+	case "plans delete":
+		return c.deletePlan(ctx, args)
+	case "sessions list":
+		return c.listSessions(ctx, args)
+	case "sessions log":
+		return c.logSession(ctx, args)
+	}
+	return errUsage
+}
+
+// parse parses the flags of a command, which takes argCount arguments or,
+// when argCount is negative, any number of them.
+func (c *cli) parse(flags *flag.FlagSet, args []string, argCount int) error {
+	// The flag package reports the invalid flag, run the usage
+	flags.SetOutput(c.stderr)
+	flags.Usage = func() {}
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+	if argCount >= 0 && flags.NArg() != argCount {
+		return errUsage
+	}
+	return nil
+}
+
+// client returns an API client that saves refreshed tokens. Unless login is
+// set the client has to be logged in already.
+func (c *cli) client(login bool) (*client.Client, settings, error) {
+	s, err := loadSettings(c.configPath)
+	if err != nil {
+		return nil, s, err
+	}
+	if c.server != "" {
+		s.Server = c.server
+	} else if s.Server == "" {
+		s.Server = defaultServer
+	}
+	if !login && s.Tokens.AccessToken == "" {
+		return nil, s, errNotLoggedIn
+	}
+
+	apiClient := client.New(s.Server)
+	apiClient.SetTokens(s.Tokens)
+	apiClient.OnTokens = func(tokens client.Tokens) {
+		s.Tokens = tokens
+		if err := saveSettings(c.configPath, s); err != nil {
+			fmt.Fprintln(c.stderr, "workoutctl: saving the tokens:", err)
+		}
+	}
+	return apiClient, s, nil
+}
+
+func (c *cli) login(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("login", flag.ContinueOnError)
+	if err := c.parse(flags, args, 1); err != nil {
+		return err
+	}
+	password := os.Getenv("WORKOUTCTL_PASSWORD")
+	if password == "" {
+		fmt.Fprint(c.stderr, "Password: ")
+		line, err := bufio.NewReader(c.stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return fmt.Errorf("reading the password: %w", err)
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+
+	apiClient, _, err := c.client(true)
+	if err != nil {
+		return err
+	}
+	if _, err := apiClient.Login(ctx, flags.Arg(0), password); err != nil {
+		return err
+	}
+	c.out.message("Logged in as %s", flags.Arg(0))
+	return nil
+}
+
+func (c *cli) logout(ctx context.Context, args []string) error {
+	if err := c.parse(flag.NewFlagSet("logout", flag.ContinueOnError), args, 0); err != nil {
+		return err
+	}
+	apiClient, s, err := c.client(false)
+	if err != nil {
+		return err
+	}
+	if err := apiClient.Logout(ctx); err != nil {
+		return err
+	}
+	s.Tokens = client.Tokens{}
+	return saveSettings(c.configPath, s)
+}
+
+func (c *cli) listPlans(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("plans list", flag.ContinueOnError)
+	query := client.PlanQuery{}
+	flags.StringVar(&query.ExerciseName, "exercise", "", "exercise name contains")
+	flags.StringVar(&query.Sort, "sort", "", "id, exercise_name, weight, reps or sets")
+	flags.StringVar(&query.Order, "order", "", "asc or desc")
+	if err := c.parse(flags, args, 0); err != nil {
+		return err
+	}
+	apiClient, _, err := c.client(false)
+	if err != nil {
+		return err
+	}
+
+	plans, err := apiClient.AllPlans(ctx, query)
+	if err != nil {
+		return err
+	}
+	t := table{header: []string{"ID", "EXERCISE", "REPS", "SETS", "WEIGHT"}, value: plans}
+	for _, plan := range plans {
+		t.rows = append(t.rows, []string{
+			strconv.Itoa(plan.Id), plan.ExerciseName, strconv.Itoa(plan.Repetitions), strconv.Itoa(plan.Sets), strconv.Itoa(plan.Weight),
+		})
+	}
+	return c.out.write(t)
+}
+
+// savePlan adds a plan or, when update is set, replaces the plan of the
+// exercise.
+func (c *cli) savePlan(ctx context.Context, args []string, update bool) error {
+	name := "plans add"
+	if update {
+		name = "plans update"
+	}
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	plan := client.WorkoutPlan{}
+	flags.StringVar(&plan.ExerciseName, "exercise", "", "catalog exercise of the plan")
+	flags.IntVar(&plan.Repetitions, "reps", 0, "repetitions per set")
+	flags.IntVar(&plan.Sets, "sets", 0, "number of sets")
+	flags.IntVar(&plan.Weight, "weight", 0, "weight per repetition")
+	if err := c.parse(flags, args, 0); err != nil {
+		return err
+	}
+	if plan.ExerciseName == "" {
+		return fmt.Errorf("%w: %s: -exercise is required", errUsage, name)
+	}
+	apiClient, _, err := c.client(false)
+	if err != nil {
+		return err
+	}
+
+	if update {
+		if err := apiClient.UpdatePlan(ctx, plan); err != nil {
+			return err
+		}
+		c.out.message("Updated the plan of %s", plan.ExerciseName)
+		return nil
+	}
+	if err := apiClient.CreatePlan(ctx, plan); err != nil {
+		return err
+	}
+	c.out.message("Added a plan for %s", plan.ExerciseName)
+	return nil
+}
+
+func (c *cli) deletePlan(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("plans delete", flag.ContinueOnError)
+	if err := c.parse(flags, args, 1); err != nil {
+		return err
+	}
+	apiClient, _, err := c.client(false)
+	if err != nil {
+		return err
+	}
+	if err := apiClient.DeletePlan(ctx, flags.Arg(0)); err != nil {
+		return err
+	}
+	c.out.message("Deleted the plan of %s", flags.Arg(0))
+	return nil
+}
+
+func (c *cli) listSessions(ctx context.Context, args []string) error {
+	if err := c.parse(flag.NewFlagSet("sessions list", flag.ContinueOnError), args, 0); err != nil {
+		return err
+	}
+	apiClient, _, err := c.client(false)
+	if err != nil {
+		return err
+	}
+	sessions, err := apiClient.ListSessions(ctx)
+	if err != nil {
+		return err
+	}
+	return c.out.write(sessionTable(sessions))
+}
+
+func (c *cli) logSession(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("sessions log", flag.ContinueOnError)
+	started := flags.String("started", "", "start as RFC 3339 time, defaults to now")
+	planId := flags.Int("plan", 0, "id of the plan the session followed")
+	notes := flags.String("notes", "", "notes on the session")
+	if err := c.parse(flags, args, -1); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return errUsage
+	}
+
+	session := client.Session{StartedAt: time.Now().Truncate(time.Second), Notes: *notes}
+	if *started != "" {
+		var err error
+		if session.StartedAt, err = time.Parse(time.RFC3339, *started); err != nil {
+			return fmt.Errorf("%w: sessions log: -started: %v", errUsage, err)
+		}
+	}
+	if *planId != 0 {
+		session.PlanId = planId
+	}
+	for _, arg := range flags.Args() {
+		exercise, set, err := parseSet(arg)
+		if err != nil {
+			return err
+		}
+		// Sets of the same exercise in a row belong to one exercise entry
+		if last := len(session.Exercises) - 1; last >= 0 && session.Exercises[last].ExerciseName == exercise {
+			session.Exercises[last].Sets = append(session.Exercises[last].Sets, set)
+		} else {
+			session.Exercises = append(session.Exercises, client.SessionExercise{ExerciseName: exercise, Sets: []client.Set{set}})
+		}
+	}
+
+	apiClient, _, err := c.client(false)
+	if err != nil {
+		return err
+	}
+	logged, err := apiClient.LogSession(ctx, session)
+	if err != nil {
+		return err
+	}
+	t := sessionTable([]client.Session{logged})
+	t.value = logged
+	return c.out.write(t)
+}
+
+// parseSet parses EXERCISE:REPSxWEIGHT.
+func parseSet(arg string) (string, client.Set, error) {
+	exercise, set, ok := strings.Cut(arg, ":")
+	reps, weight, ok2 := strings.Cut(set, "x")
+	if !ok || !ok2 || exercise == "" {
+		return "", client.Set{}, fmt.Errorf("%w: set %q is not EXERCISE:REPSxWEIGHT", errUsage, arg)
+	}
+	r, err := strconv.Atoi(reps)
+	if err != nil {
+		return "", client.Set{}, fmt.Errorf("%w: set %q has invalid reps", errUsage, arg)
+	}
+	w, err := strconv.ParseFloat(weight, 64)
+	if err != nil {
+		return "", client.Set{}, fmt.Errorf("%w: set %q has invalid weight", errUsage, arg)
+	}
+	return exercise, client.Set{Reps: r, Weight: w}, nil
+}
+
+func sessionTable(sessions []client.Session) table {
+	t := table{header: []string{"ID", "STARTED", "EXERCISE", "SETS", "NOTES"}, value: sessions}
+	for _, session := range sessions {
+		for _, exercise := range session.Exercises {
+			sets := make([]string, len(exercise.Sets))
+			for i, set := range exercise.Sets {
+				sets[i] = strconv.Itoa(set.Reps) + "x" + formatFloat(set.Weight)
+			}
+			t.rows = append(t.rows, []string{
+				strconv.Itoa(session.Id), session.StartedAt.Format(time.RFC3339), exercise.ExerciseName, strings.Join(sets, " "), session.Notes,
+			})
+		}
+		if len(session.Exercises) == 0 {
+			t.rows = append(t.rows, []string{strconv.Itoa(session.Id), session.StartedAt.Format(time.RFC3339), "", "", session.Notes})
+		}
+	}
+	return t
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+
+	"github.com/Oriseer/workout_tracker/api"
+	"github.com/Oriseer/workout_tracker/client"
+)
+
+const usage = `Usage: workoutctl [flags] <command> [arguments]
+
+Commands:
+  login USERNAME          log in and save the tokens, reads the password from
+                          WORKOUTCTL_PASSWORD or the first line of stdin
+  logout                  revoke and forget the saved tokens
+  plans list              list workout plans (-exercise, -sort, -order)
+  plans add               add a plan (-exercise, -reps, -sets, -weight)
+  plans update            replace the plan of -exercise (-reps, -sets, -weight)
+  plans delete EXERCISE   delete the plan of an exercise
+  sessions list           list workout sessions
+  sessions log SET...     log a session, SET is EXERCISE:REPSxWEIGHT such as
+                          "bench press:5x80" (-started, -plan, -notes)
+
+Flags:
+  -server URL       API to call, defaults to WORKOUTCTL_SERVER, the server
+                    of the last login or http://localhost:8080
+  -output FORMAT    table, json or csv (default table)
+  -config FILE      file holding the server and tokens, defaults to
+                    WORKOUTCTL_CONFIG or workoutctl/config.json in the user
+                    config directory
+
+Exit codes:
+  0  success
+  1  other errors
+  2  invalid usage
+  3  not logged in, invalid credentials or insufficient permissions
+  4  not found
+  5  invalid input
+  6  too many requests
+  7  server error or unreachable server
+`
+
+// Exit codes by the kind of error, see usage.
+const (
+	exitOK = iota
+	exitError
+	exitUsage
+	exitAuth
+	exitNotFound
+	exitInvalid
+	exitTooManyRequests
+	exitServer
+)
+
+// errUsage is returned for invalid command lines. The usage is printed for
+// errUsage itself, errors wrapping it are printed instead.
+var errUsage = errors.New("invalid usage")
+
+// errNotLoggedIn is returned by commands that need a login when there is no
+// saved one.
+var errNotLoggedIn = errors.New("not logged in, run workoutctl login first")
+
+// authErrors are errors of the API that ask for logging in again, although
+// they reject the input of a login or refresh with 400 Bad Request. The
+// other auth errors come with 401 Unauthorized or 403 Forbidden.
+var authErrors = []error{
+	api.ErrInvalidLoginDetails,
+	api.ErrInvalidRefreshToken,
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	os.Exit(run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs the command line args and returns the exit code.
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("workoutctl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() { fmt.Fprint(stderr, usage) }
+	server := flags.String("server", os.Getenv("WORKOUTCTL_SERVER"), "API to call")
+	format := flags.String("output", formatTable, "table, json or csv")
+	configPath := flags.String("config", os.Getenv("WORKOUTCTL_CONFIG"), "file holding the server and tokens")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() == 0 || !validFormat(*format) {
+		flags.Usage()
+		return exitUsage
+	}
+
+	if *configPath == "" {
+		var err error
+		if *configPath, err = defaultConfigPath(); err != nil {
+			fmt.Fprintln(stderr, "workoutctl:", err)
+			return exitError
+		}
+	}
+	cli := &cli{
+		configPath: *configPath,
+		server:     *server,
+		stdin:      stdin,
+		stderr:     stderr,
+		out:        &output{format: *format, w: stdout},
+	}
+
+	err := cli.run(ctx, flags.Args())
+	if err == errUsage {
+		flags.Usage()
+	} else if err != nil {
+		fmt.Fprintln(stderr, "workoutctl:", err)
+	}
+	return exitCode(err)
+}
+
+// exitCode maps err to the exit codes of usage.
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	if errors.Is(err, errUsage) {
+		return exitUsage
+	}
+	if err == errNotLoggedIn {
+		return exitAuth
+	}
+	for _, authErr := range authErrors {
+		if errors.Is(err, authErr) {
+			return exitAuth
+		}
+	}
+
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) {
+		// The request did not reach the server
+		var netErr interface{ Timeout() bool }
+		if errors.As(err, &netErr) {
+			return exitServer
+		}
+		return exitError
+	}
+	switch {
+	case apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden:
+		return exitAuth
+	case apiErr.StatusCode == http.StatusNotFound:
+		return exitNotFound
+	case apiErr.StatusCode == http.StatusTooManyRequests:
+		return exitTooManyRequests
+	case apiErr.StatusCode >= http.StatusInternalServerError:
+		return exitServer
+	case apiErr.StatusCode >= http.StatusBadRequest:
+		return exitInvalid
+	}
+	return exitError
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Oriseer/workout_tracker/client"
+	"github.com/Oriseer/workout_tracker/config"
+	tracker "github.com/Oriseer/workout_tracker/internal"
+)
+
+func TestWorkoutctl(t *testing.T) {
+	cfg := config.Default()
+	cfg.JWT.Key = "test-key"
+	store := tracker.NewInMemoryStore()
+	store.AddUser(tracker.UserDetails{Username: "alice", Password: "testpass", Email: "alice@example.com"})
+	for _, name := range []string{"pushup", "bench press"} {
+		if _, err := store.AddExercise(tracker.Exercise{Name: name, Category: "strength"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	server := httptest.NewServer(tracker.NewWorkoutServer(store, cfg))
+	defer server.Close()
+
+	configPath := filepath.Join(t.TempDir(), "workoutctl", "config.json")
+	t.Setenv("WORKOUTCTL_SERVER", "")
+	t.Setenv("WORKOUTCTL_PASSWORD", "")
+	workoutctl := func(t *testing.T, expectedCode int, stdin string, args ...string) string {
+		t.Helper()
+		var stdout, stderr bytes.Buffer
+		args = append([]string{"-config", configPath}, args...)
+		code := run(context.Background(), args, strings.NewReader(stdin), &stdout, &stderr)
+		if code != expectedCode {
+			t.Errorf("Expected workoutctl %s to exit with %d, got %d: %s", strings.Join(args[2:], " "), expectedCode, code, stderr.String())
+		}
+		return stdout.String()
+	}
+
+	t.Run("needs a login", func(t *testing.T) {
+		workoutctl(t, exitAuth, "", "plans", "list")
+		workoutctl(t, exitAuth, "wrong\n", "-server", server.URL, "login", "alice")
+	})
+
+	t.Run("saves the login", func(t *testing.T) {
+		workoutctl(t, exitOK, "testpass\n", "-server", server.URL, "login", "alice")
+		info, err := os.Stat(configPath)
+		if err != nil {
+			t.Fatalf("Expected the config file, got %v", err)
+		}
+		if info.Mode().Perm() != 0o600 {
+			t.Errorf("Expected only the owner to read the tokens, got %v", info.Mode().Perm())
+		}
+		saved, err := loadSettings(configPath)
+		if err != nil || saved.Server != server.URL || saved.Tokens.RefreshToken == "" {
+			t.Errorf("Expected the server and tokens to be saved, got %+v, %v", saved, err)
+		}
+	})
+
+	t.Run("manages plans", func(t *testing.T) {
+		workoutctl(t, exitOK, "", "plans", "add", "-exercise", "pushup", "-reps", "10", "-sets", "3", "-weight", "0")
+		workoutctl(t, exitOK, "", "plans", "add", "-exercise", "bench press", "-reps", "5", "-sets", "5", "-weight", "80")
+		workoutctl(t, exitOK, "", "plans", "update", "-exercise", "pushup", "-reps", "12", "-sets", "3")
+		workoutctl(t, exitInvalid, "", "plans", "add", "-exercise", "squat")
+		workoutctl(t, exitUsage, "", "plans", "add", "-reps", "5")
+
+		table := workoutctl(t, exitOK, "", "plans", "list", "-sort", "exercise_name")
+		lines := strings.Split(strings.TrimSpace(table), "\n")
+		if len(lines) != 3 || !strings.HasPrefix(lines[0], "ID") || !strings.Contains(lines[1], "bench press") || !strings.Contains(lines[2], "12") {
+			t.Errorf("Expected a table of both plans, got\n%s", table)
+		}
+
+		var plans []client.WorkoutPlan
+		if err := json.Unmarshal([]byte(workoutctl(t, exitOK, "", "-output", "json", "plans", "list", "-exercise", "bench")), &plans); err != nil || len(plans) != 1 || plans[0].Weight != 80 {
+			t.Errorf("Expected the bench press plan as JSON, got %+v, %v", plans, err)
+		}
+
+		workoutctl(t, exitOK, "", "plans", "delete", "bench press")
+		workoutctl(t, exitNotFound, "", "plans", "delete", "bench press")
+	})
+
+	t.Run("logs sessions", func(t *testing.T) {
+		out := workoutctl(t, exitOK, "", "-output", "csv", "sessions", "log", "-started", "2024-03-04T18:00:00Z", "-notes", "felt strong",
+			"bench press:5x80", "bench press:5x82.5", "pushup:12x0")
+		records, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+		if err != nil || len(records) != 3 {
+			t.Fatalf("Expected a header and a row per exercise, got %q, %v", out, err)
+		}
+		if records[1][2] != "bench press" || records[1][3] != "5x80 5x82.5" || records[1][4] != "felt strong" {
+			t.Errorf("Expected both bench press sets in one row, got %v", records[1])
+		}
+
+		var sessions []client.Session
+		if err := json.Unmarshal([]byte(workoutctl(t, exitOK, "", "-output", "json", "sessions", "list")), &sessions); err != nil || len(sessions) != 1 || len(sessions[0].Exercises) != 2 {
+			t.Errorf("Expected the session as JSON, got %+v, %v", sessions, err)
+		}
+
+		workoutctl(t, exitUsage, "", "sessions", "log", "bench press")
+		workoutctl(t, exitInvalid, "", "sessions", "log", "squat:5x100")
+	})
+
+	t.Run("logs out", func(t *testing.T) {
+		workoutctl(t, exitOK, "", "logout")
+		workoutctl(t, exitAuth, "", "plans", "list")
+	})
+
+	t.Run("reports an unreachable server", func(t *testing.T) {
+		unreachable := httptest.NewServer(nil)
+		unreachable.Close()
+		saveSettings(configPath, settings{Server: unreachable.URL, Tokens: client.Tokens{AccessToken: "token"}})
+		workoutctl(t, exitServer, "", "sessions", "list")
+	})
+
+	t.Run("rejects invalid usage", func(t *testing.T) {
+		workoutctl(t, exitUsage, "")
+		workoutctl(t, exitUsage, "", "plans")
+		workoutctl(t, exitUsage, "", "-output", "xml", "plans", "list")
+		workoutctl(t, exitUsage, "", "plans", "list", "-unknown")
+	})
+}
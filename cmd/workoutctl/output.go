@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Output formats of -output.
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatCSV   = "csv"
+)
+
+func validFormat(format string) bool {
+	return format == formatTable || format == formatJSON || format == formatCSV
+}
+
+// output writes the results of commands in the format of -output.
+type output struct {
+	format string
+	w      io.Writer
+}
+
+// table is a result as rows under header. value is the result as the API
+// returned it, written as is in JSON.
+type table struct {
+	header []string
+	rows   [][]string
+	value  any
+}
+
+func (o *output) write(t table) error {
+	switch o.format {
+	case formatJSON:
+		encoder := json.NewEncoder(o.w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(t.value)
+	case formatCSV:
+		w := csv.NewWriter(o.w)
+		w.Write(t.header)
+		w.WriteAll(t.rows)
+		return w.Error()
+	default:
+		w := tabwriter.NewWriter(o.w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, strings.Join(t.header, "\t"))
+		for _, row := range t.rows {
+			fmt.Fprintln(w, strings.Join(row, "\t"))
+		}
+		return w.Flush()
+	}
+}
+
+// message writes the outcome of a command without a result. JSON and CSV
+// output stay empty so they can be piped.
+func (o *output) message(format string, args ...any) {
+	if o.format == formatTable {
+		fmt.Fprintf(o.w, format+"\n", args...)
+	}
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/Oriseer/workout_tracker/client"
+)
+
+// defaultServer is the API called when neither -server, WORKOUTCTL_SERVER
+// nor a login names one.
+const defaultServer = "http://localhost:8080"
+
+// settings are kept in the config file between runs. The file holds the
+// tokens of the login, so only its owner may read it.
+type settings struct {
+	Server string        `json:"server,omitempty"`
+	Tokens client.Tokens `json:"tokens"`
+}
+
+func defaultConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "workoutctl", "config.json"), nil
+}
+
+// loadSettings reads the config file, which is missing before the first
+// login.
+func loadSettings(path string) (settings, error) {
+	var s settings
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	} else if err != nil {
+		return s, err
+	}
+	return s, json.Unmarshal(data, &s)
+}
+
+// saveSettings replaces the config file at once, so a run that is stopped
+// while saving refreshed tokens does not lose the login.
+func saveSettings(path string, s settings) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".config-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,419 @@
+// Package config loads the settings of the workout tracker. Every setting
+// has a default and can be given in a YAML or TOML file, a .env file, the
+// environment and command line flags, each overriding the ones before.
+//
+// Every flag has an environment variable of the same name in upper case
+// with underscores, -db-user is DB_USER and -jwt-access-ttl is
+// JWT_ACCESS_TTL. The file is named by -config or CONFIG_FILE.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// StorePostgres keeps the data in the Postgres database of Database.
+	StorePostgres = "postgres"
+	// StoreMemory keeps the data in memory until the server stops.
+	StoreMemory = "memory"
+	// SQLiteScheme prefixes the path of a SQLite database file, for
+	// example sqlite://tracker.db.
+	SQLiteScheme = "sqlite://"
+)
+
+var (
+	ErrMissingJWTKey  = errors.New("JWT_KEY must be set")
+	ErrInvalidStore   = errors.New("STORE must be postgres, memory or sqlite://PATH")
+	ErrInvalidPort    = errors.New("PORT must be between 1 and 65535")
+	ErrInvalidTTL     = errors.New("token lifetimes must be positive")
+	ErrInvalidTimeout = errors.New("server timeouts must be positive")
+	ErrInvalidLogin   = errors.New("login limits and lockout thresholds must not be negative and their durations must be positive")
+	ErrInvalidLog     = errors.New("LOG_LEVEL must be debug, info, warn or error and LOG_FORMAT text or json")
+	ErrInvalidMail    = errors.New("MAIL_DRIVER must be smtp, file or log, with SMTP_ADDR or MAIL_FILE set for smtp and file, and mail must be delivered to require email verification")
+	ErrUnknownSetting = errors.New("unknown setting")
+)
+
+// Config holds every setting of the server and the commands.
+type Config struct {
+	Port int `yaml:"port" toml:"port"`
+	// PublicURL is the address users reach the API at, used for the links
+	// in mail.
+	PublicURL string         `yaml:"public_url" toml:"public_url"`
+	Store     string         `yaml:"store" toml:"store"`
+	Server    ServerConfig   `yaml:"server" toml:"server"`
+	Database  DatabaseConfig `yaml:"database" toml:"database"`
+	JWT       JWTConfig      `yaml:"jwt" toml:"jwt"`
+	Auth      AuthConfig     `yaml:"auth" toml:"auth"`
+	Mail      MailConfig     `yaml:"mail" toml:"mail"`
+	Log       LogConfig      `yaml:"log" toml:"log"`
+}
+
+// ServerConfig limits how long the server waits for clients. ReadTimeout
+// covers reading a whole request and WriteTimeout writing the response,
+// IdleTimeout is how long idle keep-alive connections stay open and
+// ShutdownTimeout how long in-flight requests may take to finish once the
+// server is asked to stop.
+type ServerConfig struct {
+	ReadTimeout       time.Duration `yaml:"read_timeout" toml:"read_timeout"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" toml:"read_header_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" toml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" toml:"idle_timeout"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+}
+
+// DatabaseConfig is the Postgres connection. Pending migrations are applied
+// on startup when AutoMigrate is set.
+type DatabaseConfig struct {
+	User        string `yaml:"user" toml:"user"`
+	Name        string `yaml:"name" toml:"name"`
+	Password    string `yaml:"password" toml:"password"`
+	AutoMigrate bool   `yaml:"auto_migrate" toml:"auto_migrate"`
+}
+
+// JWTConfig signs access tokens and sets how long access and refresh tokens
+// are valid.
+type JWTConfig struct {
+	Key        string        `yaml:"key" toml:"key"`
+	AccessTTL  time.Duration `yaml:"access_ttl" toml:"access_ttl"`
+	RefreshTTL time.Duration `yaml:"refresh_ttl" toml:"refresh_ttl"`
+}
+
+// AuthConfig covers password reset, email verification and login limits.
+// RequireEmailVerification stops users from logging in before they verified
+// their email.
+//
+// At most LoginIPLimit logins per client address and LoginUsernameLimit per
+// username are accepted in every LoginLimitWindow, zero turns a limit off.
+// After LockoutThreshold failed logins within LockoutWindow of the first one
+// a username is locked out for LockoutDuration, twice as long with every
+// further failure up to LockoutMaxDuration. A threshold of zero turns the
+// lockout off.
+type AuthConfig struct {
+	PasswordResetTTL         time.Duration `yaml:"password_reset_ttl" toml:"password_reset_ttl"`
+	EmailVerificationTTL     time.Duration `yaml:"email_verification_ttl" toml:"email_verification_ttl"`
+	RequireEmailVerification bool          `yaml:"require_email_verification" toml:"require_email_verification"`
+	LoginIPLimit             int           `yaml:"login_ip_limit" toml:"login_ip_limit"`
+	LoginUsernameLimit       int           `yaml:"login_username_limit" toml:"login_username_limit"`
+	LoginLimitWindow         time.Duration `yaml:"login_limit_window" toml:"login_limit_window"`
+	LockoutThreshold         int           `yaml:"lockout_threshold" toml:"lockout_threshold"`
+	LockoutWindow            time.Duration `yaml:"lockout_window" toml:"lockout_window"`
+	LockoutDuration          time.Duration `yaml:"lockout_duration" toml:"lockout_duration"`
+	LockoutMaxDuration       time.Duration `yaml:"lockout_max_duration" toml:"lockout_max_duration"`
+}
+
+// Mail drivers.
+const (
+	MailSMTP = "smtp"
+	MailFile = "file"
+	// MailLog writes mail, tokens included, to the server log. It is meant
+	// for local development only and is never chosen unless asked for.
+	MailLog = "log"
+)
+
+// MailConfig selects how mail is delivered. Driver is one of the mail
+// drivers, or empty to send through SMTP when SMTPAddr is set and to append
+// to File when that is set. Without either no mail is delivered.
+type MailConfig struct {
+	Driver       string `yaml:"driver" toml:"driver"`
+	SMTPAddr     string `yaml:"smtp_addr" toml:"smtp_addr"`
+	SMTPFrom     string `yaml:"smtp_from" toml:"smtp_from"`
+	SMTPUsername string `yaml:"smtp_username" toml:"smtp_username"`
+	SMTPPassword string `yaml:"smtp_password" toml:"smtp_password"`
+	File         string `yaml:"file" toml:"file"`
+}
+
+// Delivery returns the mail driver in use, or "" when mail is not
+// delivered.
+func (m MailConfig) Delivery() string {
+	switch {
+	case m.Driver != "":
+		return m.Driver
+	case m.SMTPAddr != "":
+		return MailSMTP
+	case m.File != "":
+		return MailFile
+	}
+	return ""
+}
+
+// Log formats.
+const (
+	LogText = "text"
+	LogJSON = "json"
+)
+
+// LogConfig sets the lowest level that is logged, one of debug, info, warn
+// and error, and whether log records are written as text or JSON.
+type LogConfig struct {
+	Level  string `yaml:"level" toml:"level"`
+	Format string `yaml:"format" toml:"format"`
+}
+
+// SlogLevel parses Level.
+func (cfg LogConfig) SlogLevel() (slog.Level, error) {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(cfg.Level))
+	return level, err
+}
+
+// Default returns the settings used when nothing else is given. It has no
+// JWT key, so it does not validate.
+func Default() Config {
+	return Config{
+		Port:      8080,
+		PublicURL: "http://localhost:8080",
+		Store:     StorePostgres,
+		Server: ServerConfig{
+			ReadTimeout:       15 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			ShutdownTimeout:   30 * time.Second,
+		},
+		JWT: JWTConfig{
+			AccessTTL:  15 * time.Minute,
+			RefreshTTL: 30 * 24 * time.Hour,
+		},
+		Auth: AuthConfig{
+			PasswordResetTTL:     time.Hour,
+			EmailVerificationTTL: 48 * time.Hour,
+			LoginIPLimit:         20,
+			LoginUsernameLimit:   10,
+			LoginLimitWindow:     time.Minute,
+			LockoutThreshold:     5,
+			LockoutWindow:        24 * time.Hour,
+			LockoutDuration:      15 * time.Minute,
+			LockoutMaxDuration:   24 * time.Hour,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: LogText,
+		},
+	}
+}
+
+// Load reads the settings from the config file, .env, the environment and
+// the flags in args, in increasing order of precedence. It does not
+// validate them, commands check what they need with Validate.
+func Load(args []string) (Config, error) {
+	flags := flag.NewFlagSet("config", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	return LoadFlags(flags, args)
+}
+
+// LoadFlags is Load for commands with flags of their own: it registers the
+// settings on flags, next to the flags of the command, and parses args with
+// them. The flags of the command are not read from the environment.
+func LoadFlags(flags *flag.FlagSet, args []string) (Config, error) {
+	command := map[string]bool{}
+	flags.VisitAll(func(f *flag.Flag) {
+		command[f.Name] = true
+	})
+
+	cfg := Default()
+	file := flags.String("config", os.Getenv("CONFIG_FILE"), "YAML or TOML file with the settings")
+	cfg.register(flags)
+	if err := flags.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	// Flags were parsed first to find the file, they are set again last
+	explicit := map[string]string{}
+	flags.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = f.Value.String()
+	})
+
+	cfg = Default()
+	if *file != "" {
+		if err := cfg.readFile(*file); err != nil {
+			return Config{}, err
+		}
+	}
+
+	dotenv, err := godotenv.Read()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return Config{}, fmt.Errorf(".env: %w", err)
+	}
+	var setErr error
+	flags.VisitAll(func(f *flag.Flag) {
+		if f.Name == "config" || command[f.Name] || setErr != nil {
+			return
+		}
+		key := envName(f.Name)
+		value, ok := os.LookupEnv(key)
+		if !ok {
+			value, ok = dotenv[key]
+		}
+		if ok {
+			if err := flags.Set(f.Name, value); err != nil {
+				setErr = fmt.Errorf("%s: %w", key, err)
+			}
+		}
+	})
+	if setErr != nil {
+		return Config{}, setErr
+	}
+
+	for name, value := range explicit {
+		if err := flags.Set(name, value); err != nil {
+			return Config{}, fmt.Errorf("-%s: %w", name, err)
+		}
+	}
+	cfg.PublicURL = strings.TrimSuffix(cfg.PublicURL, "/")
+	return cfg, nil
+}
+
+// Usage describes the flags Load accepts.
+func Usage() string {
+	cfg := Default()
+	flags := flag.NewFlagSet("config", flag.ContinueOnError)
+	flags.String("config", "", "YAML or TOML file with the settings")
+	cfg.register(flags)
+
+	var b strings.Builder
+	flags.SetOutput(&b)
+	flags.PrintDefaults()
+	return b.String()
+}
+
+// register binds a flag to every setting of cfg.
+func (cfg *Config) register(flags *flag.FlagSet) {
+	flags.IntVar(&cfg.Port, "port", cfg.Port, "port the server listens on")
+	flags.StringVar(&cfg.PublicURL, "public-url", cfg.PublicURL, "address users reach the API at")
+	flags.StringVar(&cfg.Store, "store", cfg.Store, "postgres, memory or sqlite://PATH")
+	flags.DurationVar(&cfg.Server.ReadTimeout, "read-timeout", cfg.Server.ReadTimeout, "time allowed to read a request")
+	flags.DurationVar(&cfg.Server.ReadHeaderTimeout, "read-header-timeout", cfg.Server.ReadHeaderTimeout, "time allowed to read the headers of a request")
+	flags.DurationVar(&cfg.Server.WriteTimeout, "write-timeout", cfg.Server.WriteTimeout, "time allowed to write a response")
+	flags.DurationVar(&cfg.Server.IdleTimeout, "idle-timeout", cfg.Server.IdleTimeout, "time idle keep-alive connections stay open")
+	flags.DurationVar(&cfg.Server.ShutdownTimeout, "shutdown-timeout", cfg.Server.ShutdownTimeout, "time in-flight requests get to finish on shutdown")
+	flags.StringVar(&cfg.Database.User, "db-user", cfg.Database.User, "Postgres user")
+	flags.StringVar(&cfg.Database.Name, "db-name", cfg.Database.Name, "Postgres database")
+	flags.StringVar(&cfg.Database.Password, "db-password", cfg.Database.Password, "Postgres password")
+	flags.BoolVar(&cfg.Database.AutoMigrate, "db-auto-migrate", cfg.Database.AutoMigrate, "apply pending migrations on startup")
+	flags.StringVar(&cfg.JWT.Key, "jwt-key", cfg.JWT.Key, "secret that signs access tokens")
+	flags.DurationVar(&cfg.JWT.AccessTTL, "jwt-access-ttl", cfg.JWT.AccessTTL, "lifetime of access tokens")
+	flags.DurationVar(&cfg.JWT.RefreshTTL, "jwt-refresh-ttl", cfg.JWT.RefreshTTL, "lifetime of refresh tokens")
+	flags.DurationVar(&cfg.Auth.PasswordResetTTL, "password-reset-ttl", cfg.Auth.PasswordResetTTL, "lifetime of password reset tokens")
+	flags.DurationVar(&cfg.Auth.EmailVerificationTTL, "email-verification-ttl", cfg.Auth.EmailVerificationTTL, "lifetime of email verification links")
+	flags.BoolVar(&cfg.Auth.RequireEmailVerification, "require-email-verification", cfg.Auth.RequireEmailVerification, "refuse logins until the email is verified")
+	flags.IntVar(&cfg.Auth.LoginIPLimit, "login-ip-limit", cfg.Auth.LoginIPLimit, "logins accepted per client address in every window, 0 for no limit")
+	flags.IntVar(&cfg.Auth.LoginUsernameLimit, "login-username-limit", cfg.Auth.LoginUsernameLimit, "logins accepted per username in every window, 0 for no limit")
+	flags.DurationVar(&cfg.Auth.LoginLimitWindow, "login-limit-window", cfg.Auth.LoginLimitWindow, "window of the login limits")
+	flags.IntVar(&cfg.Auth.LockoutThreshold, "lockout-threshold", cfg.Auth.LockoutThreshold, "failed logins within the lockout window that lock a username out, 0 for no lockout")
+	flags.DurationVar(&cfg.Auth.LockoutWindow, "lockout-window", cfg.Auth.LockoutWindow, "time from the first failed login in which failures are counted")
+	flags.DurationVar(&cfg.Auth.LockoutDuration, "lockout-duration", cfg.Auth.LockoutDuration, "first lockout, doubled with every further failure")
+	flags.DurationVar(&cfg.Auth.LockoutMaxDuration, "lockout-max-duration", cfg.Auth.LockoutMaxDuration, "longest lockout")
+	flags.StringVar(&cfg.Mail.Driver, "mail-driver", cfg.Mail.Driver, "smtp, file or log (development only), by default smtp or file when their settings are given")
+	flags.StringVar(&cfg.Mail.SMTPAddr, "smtp-addr", cfg.Mail.SMTPAddr, "SMTP server as host:port")
+	flags.StringVar(&cfg.Mail.SMTPFrom, "smtp-from", cfg.Mail.SMTPFrom, "sender of mail")
+	flags.StringVar(&cfg.Mail.SMTPUsername, "smtp-username", cfg.Mail.SMTPUsername, "SMTP user")
+	flags.StringVar(&cfg.Mail.SMTPPassword, "smtp-password", cfg.Mail.SMTPPassword, "SMTP password")
+	flags.StringVar(&cfg.Mail.File, "mail-file", cfg.Mail.File, "file mail is appended to by the file driver")
+	flags.StringVar(&cfg.Log.Level, "log-level", cfg.Log.Level, "lowest level logged: debug, info, warn or error")
+	flags.StringVar(&cfg.Log.Format, "log-format", cfg.Log.Format, "log format: text or json")
+}
+
+// envName returns the environment variable of a flag, DB_USER for db-user.
+func envName(flagName string) string {
+	return strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// readFile decodes the YAML or TOML file at path over cfg. Unknown keys are
+// an error so that typos do not go unnoticed.
+func (cfg *Config) readFile(path string) error {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(contents))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(cfg); err != nil && err != io.EOF {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	case ".toml":
+		meta, err := toml.Decode(string(contents), cfg)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("%s: %w %s", path, ErrUnknownSetting, undecoded[0])
+		}
+	default:
+		return fmt.Errorf("%s: config file must be .yaml, .yml or .toml", path)
+	}
+	return nil
+}
+
+// SQLitePath returns the database file when Store selects SQLite.
+func (cfg Config) SQLitePath() (string, bool) {
+	return strings.CutPrefix(cfg.Store, SQLiteScheme)
+}
+
+// Addr is the address the server listens on.
+func (cfg Config) Addr() string {
+	return fmt.Sprintf(":%d", cfg.Port)
+}
+
+// Validate checks the settings the server needs to start.
+func (cfg Config) Validate() error {
+	if cfg.JWT.Key == "" {
+		return ErrMissingJWTKey
+	}
+	if cfg.Port < 1 || cfg.Port > 65535 {
+		return ErrInvalidPort
+	}
+	if path, ok := cfg.SQLitePath(); (ok && path == "") || (!ok && cfg.Store != StorePostgres && cfg.Store != StoreMemory) {
+		return ErrInvalidStore
+	}
+	if cfg.JWT.AccessTTL <= 0 || cfg.JWT.RefreshTTL <= 0 || cfg.Auth.PasswordResetTTL <= 0 || cfg.Auth.EmailVerificationTTL <= 0 {
+		return ErrInvalidTTL
+	}
+	auth := cfg.Auth
+	if auth.LoginIPLimit < 0 || auth.LoginUsernameLimit < 0 || auth.LockoutThreshold < 0 ||
+		auth.LoginLimitWindow <= 0 || auth.LockoutWindow <= 0 || auth.LockoutDuration <= 0 || auth.LockoutMaxDuration < auth.LockoutDuration {
+		return ErrInvalidLogin
+	}
+	server := cfg.Server
+	if server.ReadTimeout <= 0 || server.ReadHeaderTimeout <= 0 || server.WriteTimeout <= 0 || server.IdleTimeout <= 0 || server.ShutdownTimeout <= 0 {
+		return ErrInvalidTimeout
+	}
+	if _, err := cfg.Log.SlogLevel(); err != nil || (cfg.Log.Format != LogText && cfg.Log.Format != LogJSON) {
+		return ErrInvalidLog
+	}
+	mail := cfg.Mail
+	switch mail.Delivery() {
+	case MailSMTP:
+		if mail.SMTPAddr == "" {
+			return ErrInvalidMail
+		}
+	case MailFile:
+		if mail.File == "" {
+			return ErrInvalidMail
+		}
+	case MailLog:
+	case "":
+		if cfg.Auth.RequireEmailVerification {
+			return ErrInvalidMail
+		}
+	default:
+		return ErrInvalidMail
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, name, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	// No .env file unless a test writes one
+	t.Chdir(t.TempDir())
+
+	t.Run("defaults", func(t *testing.T) {
+		cfg, err := Load(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg != Default() {
+			t.Errorf("Expected %+v, got %+v", Default(), cfg)
+		}
+	})
+
+	t.Run("the environment overrides the file and flags override both", func(t *testing.T) {
+		path := writeFile(t, "tracker.yaml", "port: 9000\nstore: memory\njwt:\n  key: from-file\n  access_ttl: 5m\ndatabase:\n  user: file-user\n")
+		t.Setenv("JWT_KEY", "from-env")
+		t.Setenv("DB_USER", "env-user")
+
+		cfg, err := Load([]string{"-config", path, "-db-user", "flag-user"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Port != 9000 || cfg.Store != StoreMemory || cfg.JWT.AccessTTL != 5*time.Minute {
+			t.Errorf("Expected the settings of the file, got %+v", cfg)
+		}
+		if cfg.JWT.Key != "from-env" {
+			t.Errorf("Expected the key of the environment, got %q", cfg.JWT.Key)
+		}
+		if cfg.Database.User != "flag-user" {
+			t.Errorf("Expected the user of the flag, got %q", cfg.Database.User)
+		}
+	})
+
+	t.Run("reads TOML files named by CONFIG_FILE", func(t *testing.T) {
+		path := writeFile(t, "tracker.toml", "public_url = \"https://tracker.example.com/\"\n[auth]\nrequire_email_verification = true\npassword_reset_ttl = \"30m\"\n")
+		t.Setenv("CONFIG_FILE", path)
+
+		cfg, err := Load(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.PublicURL != "https://tracker.example.com" || !cfg.Auth.RequireEmailVerification || cfg.Auth.PasswordResetTTL != 30*time.Minute {
+			t.Errorf("Expected the settings of the file, got %+v", cfg)
+		}
+	})
+
+	t.Run("the environment overrides .env", func(t *testing.T) {
+		dir := t.TempDir()
+		t.Chdir(dir)
+		if err := os.WriteFile(filepath.Join(dir, ".env"), []byte("JWT_KEY=from-dotenv\nSMTP_ADDR=localhost:25\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv("SMTP_ADDR", "mail:587")
+
+		cfg, err := Load(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.JWT.Key != "from-dotenv" || cfg.Mail.SMTPAddr != "mail:587" {
+			t.Errorf("Expected the key of .env and the SMTP address of the environment, got %+v", cfg)
+		}
+	})
+
+	t.Run("parses the flags of a command with the settings", func(t *testing.T) {
+		t.Setenv("ROLE", "from-env")
+		flags := flag.NewFlagSet("promote", flag.ContinueOnError)
+		role := flags.String("role", "admin", "role to give the user")
+
+		cfg, err := LoadFlags(flags, []string{"-db-user", "flag-user", "alice"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Database.User != "flag-user" {
+			t.Errorf("Expected the user of the flag, got %q", cfg.Database.User)
+		}
+		if *role != "admin" || flags.Arg(0) != "alice" {
+			t.Errorf("Expected the default role and the username, got %q and %v", *role, flags.Args())
+		}
+	})
+
+	t.Run("rejects invalid values", func(t *testing.T) {
+		t.Setenv("JWT_ACCESS_TTL", "soon")
+		if _, err := Load(nil); err == nil {
+			t.Error("Expected an invalid duration to fail")
+		}
+	})
+
+	t.Run("rejects unknown keys and formats", func(t *testing.T) {
+		for _, path := range []string{
+			writeFile(t, "typo.yaml", "jwt:\n  kee: secret\n"),
+			writeFile(t, "typo.toml", "[jwt]\nkee = \"secret\"\n"),
+			writeFile(t, "tracker.json", "{}"),
+		} {
+			if _, err := Load([]string{"-config", path}); err == nil {
+				t.Errorf("Expected %s to fail", filepath.Base(path))
+			}
+		}
+	})
+}
+
+func TestValidate(t *testing.T) {
+	valid := Default()
+	valid.JWT.Key = "secret"
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("Expected the defaults with a key to be valid, got %v", err)
+	}
+
+	cases := []struct {
+		name     string
+		change   func(cfg *Config)
+		expected error
+	}{
+		{"missing JWT key", func(cfg *Config) { cfg.JWT.Key = "" }, ErrMissingJWTKey},
+		{"port out of range", func(cfg *Config) { cfg.Port = 70000 }, ErrInvalidPort},
+		{"unknown store", func(cfg *Config) { cfg.Store = "mysql" }, ErrInvalidStore},
+		{"sqlite without a path", func(cfg *Config) { cfg.Store = SQLiteScheme }, ErrInvalidStore},
+		{"negative lifetime", func(cfg *Config) { cfg.JWT.RefreshTTL = -time.Hour }, ErrInvalidTTL},
+		{"unknown log level", func(cfg *Config) { cfg.Log.Level = "verbose" }, ErrInvalidLog},
+		{"unknown log format", func(cfg *Config) { cfg.Log.Format = "xml" }, ErrInvalidLog},
+		{"negative login limit", func(cfg *Config) { cfg.Auth.LoginIPLimit = -1 }, ErrInvalidLogin},
+		{"no lockout window", func(cfg *Config) { cfg.Auth.LockoutWindow = 0 }, ErrInvalidLogin},
+		{"lockout longer than its maximum", func(cfg *Config) { cfg.Auth.LockoutDuration = 48 * time.Hour }, ErrInvalidLogin},
+		{"no write timeout", func(cfg *Config) { cfg.Server.WriteTimeout = 0 }, ErrInvalidTimeout},
+		{"unknown mail driver", func(cfg *Config) { cfg.Mail.Driver = "sendmail" }, ErrInvalidMail},
+		{"smtp driver without an address", func(cfg *Config) { cfg.Mail.Driver = MailSMTP }, ErrInvalidMail},
+		{"verification without mail", func(cfg *Config) { cfg.Auth.RequireEmailVerification = true }, ErrInvalidMail},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cfg := valid
+			c.change(&cfg)
+			if err := cfg.Validate(); err != c.expected {
+				t.Errorf("Expected %v, got %v", c.expected, err)
+			}
+		})
+	}
+}
@@ -1,12 +1,13 @@
 package tracker
 
 import (
+	"database/sql"
 	"fmt"
-	"os"
+	"strings"
 
 	"github.com/Oriseer/workout_tracker/api"
+	"github.com/Oriseer/workout_tracker/config"
 	"github.com/jmoiron/sqlx"
-	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -15,52 +16,168 @@ type DB struct {
 	*sqlx.DB
 }
 
-func NewDatabase() *DB {
+// OpenDatabase connects to the Postgres database of cfg without checking the
+// schema version. When the store is sqlite://PATH the SQLite database at
+// PATH is opened instead.
+func OpenDatabase(cfg config.Config) (*DB, error) {
+	if path, ok := cfg.SQLitePath(); ok {
+		return OpenSQLiteDatabase(path)
+	}
+
+	db_user := cfg.Database.User
+	db_name := cfg.Database.Name
+	db_password := cfg.Database.Password
 
-	godotenv.Load()
+	db, err := sqlx.Connect("postgres", fmt.Sprintf("user=%s dbname=%s sslmode=disable password=%s", db_user, db_name, db_password))
+	if err != nil {
+		return nil, err
+	}
+	return &DB{db}, nil
+}
 
-	db_user := os.Getenv("DB_USER")
-	db_name := os.Getenv("DB_NAME")
-	db_password := os.Getenv("DB_PASSWORD")
+// NewDatabase connects to the database and makes sure its schema is at the
+// latest migration version. Pending migrations are applied when the
+// configuration enables auto migration, otherwise ErrSchemaOutdated is
+// returned.
+func NewDatabase(cfg config.Config) (*DB, error) {
+	db, err := OpenDatabase(cfg)
+	if err != nil {
+		return nil, err
+	}
 
-	db := sqlx.MustConnect("postgres", fmt.Sprintf("user=%s dbname=%s sslmode=disable password=%s", db_user, db_name, db_password))
-	return &DB{db}
+	migrator, err := NewMigrator(db.DB)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if cfg.Database.AutoMigrate {
+		err = migrator.Up()
+	} else {
+		err = checkSchemaVersion(migrator)
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
 }
 
-func (db *DB) AddWorkoutPlan(input WorkoutPlan) {
-	db.MustExec("INSERT INTO WORKOUT_PLAN (exercise_name, repetitions, sets, weights) VALUES ($1, $2, $3, $4)", input.ExerciseName, input.Repititions, input.Sets, input.Weight)
+func checkSchemaVersion(migrator *Migrator) error {
+	version, err := migrator.Version()
+	if err != nil {
+		return err
+	}
+	if version < migrator.Latest() {
+		return fmt.Errorf("%w: at version %d, latest is %d", ErrSchemaOutdated, version, migrator.Latest())
+	}
+	return nil
 }
 
-func (db *DB) DeleteWorkoutPlan(name string) error {
+func (db *DB) AddWorkoutPlan(owner string, input WorkoutPlan) error {
+	exercise, err := db.findExercise(input.ExerciseId, input.ExerciseName)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("INSERT INTO WORKOUT_PLAN (owner, exercise_id, exercise_name, repetitions, sets, weights) VALUES ($1, $2, $3, $4, $5, $6)",
+		owner, exercise.Id, exercise.Name, input.Repititions, input.Sets, input.Weight)
+	return err
+}
 
-	workoutPlan := WorkoutPlan{}
-	// Select the workout plan to ensure it exists before deleting
-	err := db.Get(&workoutPlan, "SELECT exercise_name FROM WORKOUT_PLAN WHERE exercise_name = $1", name)
+func (db *DB) DeleteWorkoutPlan(owner, name string) error {
+	result, err := db.Exec("DELETE FROM WORKOUT_PLAN WHERE owner = $1 AND LOWER(exercise_name) = LOWER($2)", owner, name)
+	if err != nil {
+		return err
+	}
+	deleted, err := result.RowsAffected()
 	if err != nil {
 		return err
 	}
-	db.MustExec("DELETE FROM WORKOUT_PLAN WHERE exercise_name = $1", name)
+	if deleted == 0 {
+		return api.ErrWorkoutPlanNotFound
+	}
 	return nil
 }
 
-func (db *DB) GetWorkoutPlanList() ([]WorkoutPlan, error) {
+// GetWorkoutPlanList filters, orders and pages the owner's plans in SQL. Pages
+// continue after the cursor by comparing (sort column, id) with its values.
+func (db *DB) GetWorkoutPlanList(owner string, query WorkoutPlanQuery) ([]WorkoutPlan, error) {
+	statement := "SELECT id, COALESCE(exercise_id, 0) AS exercise_id, exercise_name, repetitions, sets, weights FROM WORKOUT_PLAN WHERE owner = ?"
+	args := []any{owner}
+	if query.ExerciseName != "" {
+		statement += ` AND LOWER(exercise_name) LIKE ? ESCAPE '\'`
+		args = append(args, "%"+likeEscaper.Replace(strings.ToLower(query.ExerciseName))+"%")
+	}
+	for _, bound := range []struct {
+		condition string
+		value     *int
+	}{
+		{"weights >= ?", query.MinWeight},
+		{"weights <= ?", query.MaxWeight},
+		{"repetitions >= ?", query.MinReps},
+		{"repetitions <= ?", query.MaxReps},
+		{"sets >= ?", query.MinSets},
+		{"sets <= ?", query.MaxSets},
+	} {
+		if bound.value != nil {
+			statement += " AND " + bound.condition
+			args = append(args, *bound.value)
+		}
+	}
+
+	column, ok := workoutPlanSortColumns[query.Sort]
+	if !ok {
+		column = "id"
+	}
+	direction, comparison := "ASC", ">"
+	if query.Desc {
+		direction, comparison = "DESC", "<"
+	}
+	if after := query.After; after != nil && column == "id" {
+		statement += " AND id " + comparison + " ?"
+		args = append(args, after.Id)
+	} else if after != nil {
+		var value any = after.Number
+		if query.Sort == "exercise_name" {
+			value = after.Text
+		}
+		statement += " AND (" + column + ", id) " + comparison + " (?, ?)"
+		args = append(args, value, after.Id)
+	}
+	statement += " ORDER BY " + column + " " + direction
+	if column != "id" {
+		statement += ", id " + direction
+	}
+	if query.Limit > 0 {
+		statement += " LIMIT ?"
+		args = append(args, query.Limit)
+	}
+
 	var plans []WorkoutPlan
-	err := db.Select(&plans, "SELECT exercise_name, repetitions, sets, weights FROM WORKOUT_PLAN")
+	err := db.Select(&plans, db.Rebind(statement), args...)
 	if err != nil {
 		return nil, err
 	}
 	return plans, nil
 }
 
-func (db *DB) UpdateWorkoutPlan(input WorkoutPlan) error {
-	workoutPlan := WorkoutPlan{}
-	// Select the workout plan to ensure it exists before deleting
-	err := db.Get(&workoutPlan, "SELECT exercise_name FROM WORKOUT_PLAN WHERE exercise_name = $1", input.ExerciseName)
+// UpdateWorkoutPlan updates the owner's plan for the exercise given by
+// ExerciseId, or by ExerciseName when no id is set.
+func (db *DB) UpdateWorkoutPlan(owner string, input WorkoutPlan) error {
+	result, err := db.Exec(`UPDATE WORKOUT_PLAN SET repetitions = $1, sets = $2, weights = $3
+		WHERE owner = $4 AND (exercise_id = $5 OR ($5 = 0 AND LOWER(exercise_name) = LOWER($6)))`,
+		input.Repititions, input.Sets, input.Weight, owner, input.ExerciseId, input.ExerciseName)
 	if err != nil {
 		return err
 	}
-	db.MustExec("UPDATE WORKOUT_PLAN SET repetitions = $1, sets = $2, weights = $3 WHERE exercise_name = $4",
-		input.Repititions, input.Sets, input.Weight, input.ExerciseName)
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return api.ErrWorkoutPlanNotFound
+	}
 	return nil
 }
 
@@ -88,10 +205,11 @@ func (db *DB) AddUser(userDetails UserDetails) error {
 func (db *DB) UserLogin(loginData LoginData) (LoginData, error) {
 	userDetails := LoginData{}
 	// check if user valid
-	err := db.Get(&userDetails, "SELECT username, password_hash FROM USERS WHERE username = $1", loginData.Username)
+	err := db.Get(&userDetails, "SELECT username, password_hash, email_verified, role FROM USERS WHERE username = $1", loginData.Username)
 
-	if err != nil {
-		// Return sql.ErrNoRow
+	if err == sql.ErrNoRows {
+		return LoginData{}, api.ErrInvalidLoginDetails
+	} else if err != nil {
 		return LoginData{}, err
 	}
 
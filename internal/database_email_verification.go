@@ -0,0 +1,37 @@
+package tracker
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/Oriseer/workout_tracker/api"
+)
+
+func (db *DB) AddEmailVerificationToken(token EmailVerificationToken) error {
+	_, err := db.Exec("INSERT INTO EMAIL_VERIFICATION_TOKENS (token_hash, username, expires_at) VALUES ($1, $2, $3)",
+		token.TokenHash, token.Username, token.ExpiresAt)
+	return err
+}
+
+func (db *DB) VerifyEmail(tokenHash string, now time.Time) (string, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var username string
+	err = tx.Get(&username, "UPDATE EMAIL_VERIFICATION_TOKENS SET used_at = $1 WHERE token_hash = $2 AND used_at IS NULL AND expires_at > $1 RETURNING username",
+		now, tokenHash)
+	if err == sql.ErrNoRows {
+		return "", api.ErrInvalidVerificationToken
+	} else if err != nil {
+		return "", err
+	}
+
+	_, err = tx.Exec("UPDATE USERS SET email_verified = TRUE WHERE username = $1", username)
+	if err != nil {
+		return "", err
+	}
+	return username, tx.Commit()
+}
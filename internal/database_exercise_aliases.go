@@ -0,0 +1,44 @@
+package tracker
+
+import (
+	"github.com/Oriseer/workout_tracker/api"
+)
+
+func (db *DB) GetExerciseAliases(owner string) ([]ExerciseAlias, error) {
+	aliases := []ExerciseAlias{}
+	err := db.Select(&aliases, `SELECT a.alias, a.exercise_id, e.exercise_name FROM EXERCISE_ALIASES a
+		JOIN EXERCISES e ON e.id = a.exercise_id WHERE a.owner = $1 ORDER BY a.alias`, owner)
+	if err != nil {
+		return nil, err
+	}
+	return aliases, nil
+}
+
+func (db *DB) SetExerciseAlias(owner string, alias ExerciseAlias) (ExerciseAlias, error) {
+	exercise, err := db.findExercise(alias.ExerciseId, alias.ExerciseName)
+	if err != nil {
+		return ExerciseAlias{}, err
+	}
+	alias = ExerciseAlias{Alias: normalizeAlias(alias.Alias), ExerciseId: exercise.Id, ExerciseName: exercise.Name}
+	_, err = db.Exec(`INSERT INTO EXERCISE_ALIASES (owner, alias, exercise_id) VALUES ($1, $2, $3)
+		ON CONFLICT (owner, alias) DO UPDATE SET exercise_id = $3`, owner, alias.Alias, alias.ExerciseId)
+	if err != nil {
+		return ExerciseAlias{}, err
+	}
+	return alias, nil
+}
+
+func (db *DB) DeleteExerciseAlias(owner, alias string) error {
+	result, err := db.Exec("DELETE FROM EXERCISE_ALIASES WHERE owner = $1 AND alias = $2", owner, normalizeAlias(alias))
+	if err != nil {
+		return err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return api.ErrExerciseAliasNotFound
+	}
+	return nil
+}
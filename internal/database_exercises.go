@@ -0,0 +1,137 @@
+package tracker
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/Oriseer/workout_tracker/api"
+)
+
+// likeEscaper escapes the wildcards of a LIKE pattern, so that names are
+// matched literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func (db *DB) GetExerciseList(filter ExerciseFilter) ([]Exercise, error) {
+	query := "SELECT id, exercise_name, description, category FROM EXERCISES WHERE 1 = 1"
+	args := []any{}
+	if filter.Category != "" {
+		query += " AND category = ?"
+		args = append(args, filter.Category)
+	}
+	if filter.Name != "" {
+		query += ` AND LOWER(exercise_name) LIKE ? ESCAPE '\'`
+		args = append(args, "%"+likeEscaper.Replace(strings.ToLower(filter.Name))+"%")
+	}
+	query += " ORDER BY exercise_name"
+
+	var exercises []Exercise
+	err := db.Select(&exercises, db.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	return exercises, nil
+}
+
+func (db *DB) GetExercise(id int) (Exercise, error) {
+	exercise := Exercise{}
+	err := db.Get(&exercise, "SELECT id, exercise_name, description, category FROM EXERCISES WHERE id = $1", id)
+	if err == sql.ErrNoRows {
+		return Exercise{}, api.ErrExerciseNotFound
+	} else if err != nil {
+		return Exercise{}, err
+	}
+	return exercise, nil
+}
+
+// findExercise resolves a workout plan's exercise from the catalog, by id
+// when one is given and by name, ignoring case, otherwise.
+func (db *DB) findExercise(id int, name string) (Exercise, error) {
+	if id != 0 {
+		return db.GetExercise(id)
+	}
+	exercise := Exercise{}
+	err := db.Get(&exercise, "SELECT id, exercise_name, description, category FROM EXERCISES WHERE LOWER(exercise_name) = LOWER($1)", name)
+	if err == sql.ErrNoRows {
+		return Exercise{}, api.ErrExerciseNotFound
+	} else if err != nil {
+		return Exercise{}, err
+	}
+	return exercise, nil
+}
+
+// exerciseNameTaken reports whether another exercise than id uses name.
+func (db *DB) exerciseNameTaken(id int, name string) (bool, error) {
+	var count int
+	err := db.Get(&count, "SELECT COUNT(*) FROM EXERCISES WHERE LOWER(exercise_name) = LOWER($1) AND id <> $2", name, id)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (db *DB) AddExercise(exercise Exercise) (Exercise, error) {
+	taken, err := db.exerciseNameTaken(0, exercise.Name)
+	if err != nil {
+		return Exercise{}, err
+	}
+	if taken {
+		return Exercise{}, api.ErrExerciseExists
+	}
+
+	err = db.Get(&exercise.Id, "INSERT INTO EXERCISES (exercise_name, description, category) VALUES ($1, $2, $3) RETURNING id",
+		exercise.Name, exercise.Description, exercise.Category)
+	if err != nil {
+		return Exercise{}, err
+	}
+	return exercise, nil
+}
+
+func (db *DB) UpdateExercise(exercise Exercise) error {
+	if _, err := db.GetExercise(exercise.Id); err != nil {
+		return err
+	}
+	taken, err := db.exerciseNameTaken(exercise.Id, exercise.Name)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return api.ErrExerciseExists
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("UPDATE EXERCISES SET exercise_name = $1, description = $2, category = $3 WHERE id = $4",
+		exercise.Name, exercise.Description, exercise.Category, exercise.Id)
+	if err != nil {
+		return err
+	}
+	// Workout plans keep a copy of the name they are addressed by
+	_, err = tx.Exec("UPDATE WORKOUT_PLAN SET exercise_name = $1 WHERE exercise_id = $2", exercise.Name, exercise.Id)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (db *DB) DeleteExercise(id int) error {
+	if _, err := db.GetExercise(id); err != nil {
+		return err
+	}
+
+	var count int
+	err := db.Get(&count, `SELECT (SELECT COUNT(*) FROM WORKOUT_PLAN WHERE exercise_id = $1)
+		+ (SELECT COUNT(*) FROM SESSION_EXERCISES WHERE exercise_id = $1)`, id)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return api.ErrExerciseInUse
+	}
+
+	_, err = db.Exec("DELETE FROM EXERCISES WHERE id = $1", id)
+	return err
+}
@@ -0,0 +1,129 @@
+package tracker
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/Oriseer/workout_tracker/api"
+	"github.com/jmoiron/sqlx"
+)
+
+func (db *DB) ImportWorkoutData(owner string, plans []WorkoutPlan, sessions []WorkoutSession) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	refs := []exerciseRef{}
+	for i := range plans {
+		refs = append(refs, exerciseRef{&plans[i].ExerciseId, &plans[i].ExerciseName})
+	}
+	for _, session := range sessions {
+		for i := range session.Exercises {
+			refs = append(refs, exerciseRef{&session.Exercises[i].ExerciseId, &session.Exercises[i].ExerciseName})
+		}
+	}
+	if err := resolveExercises(tx, refs); err != nil {
+		return err
+	}
+
+	// The ids of the import are replaced by those the plans are given
+	planIds := map[int]int{}
+	for _, plan := range plans {
+		var id int
+		err := tx.Get(&id, "INSERT INTO WORKOUT_PLAN (owner, exercise_id, exercise_name, repetitions, sets, weights) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id",
+			owner, plan.ExerciseId, plan.ExerciseName, plan.Repititions, plan.Sets, plan.Weight)
+		if err != nil {
+			return err
+		}
+		if plan.Id != 0 {
+			planIds[plan.Id] = id
+		}
+	}
+
+	for _, session := range sessions {
+		var planId *int
+		if session.PlanId != nil {
+			id, ok := planIds[*session.PlanId]
+			if !ok {
+				return api.ErrWorkoutPlanNotFound
+			}
+			planId = &id
+		}
+		var sessionId int
+		err := tx.Get(&sessionId, "INSERT INTO WORKOUT_SESSIONS (owner, plan_id, started_at, ended_at, notes) VALUES ($1, $2, $3, $4, $5) RETURNING id",
+			owner, planId, session.StartedAt, session.EndedAt, session.Notes)
+		if err != nil {
+			return err
+		}
+		if err := insertSessionExercises(tx, sessionId, session.Exercises); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// exerciseRef points at the exercise id and name of a plan or a session
+// exercise.
+type exerciseRef struct {
+	id   *int
+	name *string
+}
+
+// resolveExercises looks the exercises of refs up in the catalog with a
+// single query, by id when one is given and by name, ignoring case,
+// otherwise, and fills in their ids and catalog names.
+func resolveExercises(tx *sqlx.Tx, refs []exerciseRef) error {
+	ids := map[int]bool{}
+	names := map[string]bool{}
+	for _, ref := range refs {
+		if *ref.id != 0 {
+			ids[*ref.id] = true
+		} else {
+			names[strings.ToLower(*ref.name)] = true
+		}
+	}
+
+	conditions := []string{}
+	args := []any{}
+	if len(ids) > 0 {
+		conditions = append(conditions, "id IN (?)")
+		args = append(args, slices.Collect(maps.Keys(ids)))
+	}
+	if len(names) > 0 {
+		conditions = append(conditions, "LOWER(exercise_name) IN (?)")
+		args = append(args, slices.Collect(maps.Keys(names)))
+	}
+	if len(conditions) == 0 {
+		return nil
+	}
+	query, args, err := sqlx.In("SELECT id, exercise_name, description, category FROM EXERCISES WHERE "+strings.Join(conditions, " OR "), args...)
+	if err != nil {
+		return err
+	}
+	var exercises []Exercise
+	if err := tx.Select(&exercises, tx.Rebind(query), args...); err != nil {
+		return err
+	}
+
+	byId := map[int]Exercise{}
+	byName := map[string]Exercise{}
+	for _, exercise := range exercises {
+		byId[exercise.Id] = exercise
+		byName[strings.ToLower(exercise.Name)] = exercise
+	}
+	for _, ref := range refs {
+		exercise, ok := byId[*ref.id]
+		if *ref.id == 0 {
+			exercise, ok = byName[strings.ToLower(*ref.name)]
+		}
+		if !ok {
+			return api.ErrExerciseNotFound
+		}
+		*ref.id = exercise.Id
+		*ref.name = exercise.Name
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package tracker
+
+import (
+	"database/sql"
+	"time"
+)
+
+func (db *DB) GetLoginFailures(username string) (LoginFailures, error) {
+	failures := LoginFailures{}
+	err := db.Get(&failures, "SELECT username, failures, first_failure_at, locked_until FROM LOGIN_FAILURES WHERE username = $1", username)
+	if err == sql.ErrNoRows {
+		return LoginFailures{}, nil
+	}
+	return failures, err
+}
+
+func (db *DB) RecordLoginFailure(username string, now time.Time, window time.Duration) (int, error) {
+	// Counting in a single statement keeps concurrent failures from being
+	// lost. Both assignments read the row as it was before the update.
+	var failures int
+	err := db.Get(&failures, `INSERT INTO LOGIN_FAILURES (username, failures, first_failure_at) VALUES ($1, 1, $2)
+		ON CONFLICT (username) DO UPDATE SET
+			failures = CASE WHEN LOGIN_FAILURES.first_failure_at > $3 THEN LOGIN_FAILURES.failures + 1 ELSE 1 END,
+			first_failure_at = CASE WHEN LOGIN_FAILURES.first_failure_at > $3 THEN LOGIN_FAILURES.first_failure_at ELSE $2 END
+		RETURNING failures`, username, now, now.Add(-window))
+	return failures, err
+}
+
+func (db *DB) LockLogin(username string, until time.Time) error {
+	_, err := db.Exec(`INSERT INTO LOGIN_FAILURES (username, failures, locked_until) VALUES ($1, 0, $2)
+		ON CONFLICT (username) DO UPDATE SET locked_until = $2`, username, until)
+	return err
+}
+
+func (db *DB) ResetLoginFailures(username string) error {
+	_, err := db.Exec("DELETE FROM LOGIN_FAILURES WHERE username = $1", username)
+	return err
+}
+
+func (db *DB) PruneLoginFailures(now time.Time, window time.Duration) (int64, error) {
+	result, err := db.Exec(`DELETE FROM LOGIN_FAILURES
+		WHERE (first_failure_at IS NULL OR first_failure_at <= $1) AND (locked_until IS NULL OR locked_until <= $2)`,
+		now.Add(-window), now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
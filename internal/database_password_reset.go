@@ -0,0 +1,51 @@
+package tracker
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/Oriseer/workout_tracker/api"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func (db *DB) GetUsersByEmail(email string) ([]UserDetails, error) {
+	var users []UserDetails
+	err := db.Select(&users, "SELECT username, email FROM USERS WHERE LOWER(email) = LOWER($1) ORDER BY username", email)
+	return users, err
+}
+
+func (db *DB) AddPasswordResetToken(token PasswordResetToken) error {
+	_, err := db.Exec("INSERT INTO PASSWORD_RESET_TOKENS (token_hash, username, expires_at) VALUES ($1, $2, $3)",
+		token.TokenHash, token.Username, token.ExpiresAt)
+	return err
+}
+
+func (db *DB) ResetPassword(tokenHash, password string, now time.Time) (string, error) {
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	// Consuming the token in a single statement keeps concurrent resets with
+	// the same token from both succeeding.
+	var username string
+	err = tx.Get(&username, "UPDATE PASSWORD_RESET_TOKENS SET used_at = $1 WHERE token_hash = $2 AND used_at IS NULL AND expires_at > $1 RETURNING username",
+		now, tokenHash)
+	if err == sql.ErrNoRows {
+		return "", api.ErrInvalidResetToken
+	} else if err != nil {
+		return "", err
+	}
+
+	_, err = tx.Exec("UPDATE USERS SET password_hash = $1 WHERE username = $2", passwordHash, username)
+	if err != nil {
+		return "", err
+	}
+	return username, tx.Commit()
+}
@@ -0,0 +1,129 @@
+package tracker
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// progressQuery aggregates the owner's logged sets grouped by exercise and
+// by the extra group expression, if any. The best set of each group is the
+// one with the highest estimated one rep max.
+func (db *DB) progressQuery(owner string, filter ProgressFilter, group string) (string, []any) {
+	where := "s.owner = ?"
+	args := []any{owner}
+	if !filter.From.IsZero() {
+		where += " AND s.started_at >= ?"
+		args = append(args, filter.From)
+	}
+	if !filter.To.IsZero() {
+		where += " AND s.started_at < ?"
+		args = append(args, filter.To)
+	}
+	if len(filter.ExerciseIds) > 0 {
+		where += " AND se.exercise_id IN (?" + strings.Repeat(", ?", len(filter.ExerciseIds)-1) + ")"
+		for _, id := range filter.ExerciseIds {
+			args = append(args, id)
+		}
+	}
+
+	groupColumn := "''"
+	if group != "" {
+		groupColumn = group
+	}
+
+	query := `WITH logged AS (
+		SELECT se.exercise_id, e.exercise_name, s.id AS session_id, ` + groupColumn + ` AS grp,
+			ss.reps, ss.weight, ss.weight * (1 + ss.reps / 30.0) AS e1rm
+		FROM SESSION_SETS ss
+		JOIN SESSION_EXERCISES se ON se.id = ss.session_exercise_id
+		JOIN WORKOUT_SESSIONS s ON s.id = se.session_id
+		JOIN EXERCISES e ON e.id = se.exercise_id
+		WHERE ` + where + `
+	), best AS (
+		SELECT exercise_id, grp, reps, weight, e1rm,
+			ROW_NUMBER() OVER (PARTITION BY exercise_id, grp ORDER BY e1rm DESC, weight DESC, reps DESC) AS rank
+		FROM logged
+	)
+	SELECT l.grp AS grp, l.exercise_id, l.exercise_name,
+		COUNT(DISTINCT l.session_id) AS session_count,
+		COUNT(*) AS set_count,
+		SUM(l.reps) AS total_reps,
+		SUM(l.reps * l.weight) AS total_volume,
+		b.reps AS best_set_reps,
+		b.weight AS best_set_weight,
+		b.e1rm AS estimated_one_rep_max
+	FROM logged l
+	JOIN best b ON b.exercise_id = l.exercise_id AND b.grp = l.grp AND b.rank = 1
+	GROUP BY l.grp, l.exercise_id, l.exercise_name, b.reps, b.weight, b.e1rm
+	ORDER BY l.grp, l.exercise_name, l.exercise_id`
+
+	return db.Rebind(query), args
+}
+
+func (db *DB) GetProgressReport(owner string, filter ProgressFilter) ([]ExerciseProgress, error) {
+	query, args := db.progressQuery(owner, filter, "")
+	rows := []struct {
+		Group string `db:"grp"`
+		ExerciseProgress
+	}{}
+	if err := db.Select(&rows, query, args...); err != nil {
+		return nil, err
+	}
+
+	report := make([]ExerciseProgress, 0, len(rows))
+	for _, row := range rows {
+		roundExerciseProgress(&row.ExerciseProgress)
+		report = append(report, row.ExerciseProgress)
+	}
+	return report, nil
+}
+
+func (db *DB) GetWeeklyProgressReport(owner string, filter ProgressFilter) ([]WeeklyProgress, error) {
+	week := "date_trunc('week', s.started_at AT TIME ZONE 'UTC')"
+	if db.isSQLite() {
+		// Sunday of the week, then back to its Monday
+		week = "date(s.started_at, 'weekday 0', '-6 days')"
+	}
+	query, args := db.progressQuery(owner, filter, week)
+	rows := []struct {
+		Group scannedWeek `db:"grp"`
+		ExerciseProgress
+	}{}
+	if err := db.Select(&rows, query, args...); err != nil {
+		return nil, err
+	}
+
+	report := make([]WeeklyProgress, 0, len(rows))
+	for _, row := range rows {
+		roundExerciseProgress(&row.ExerciseProgress)
+		weekStart := time.Date(row.Group.Year(), row.Group.Month(), row.Group.Day(), 0, 0, 0, 0, time.UTC)
+		report = append(report, WeeklyProgress{weekStart, row.ExerciseProgress})
+	}
+	return report, nil
+}
+
+// scannedWeek scans the start of a week, a timestamp in Postgres and a
+// YYYY-MM-DD string in SQLite.
+type scannedWeek struct {
+	time.Time
+}
+
+func (w *scannedWeek) Scan(value any) error {
+	switch v := value.(type) {
+	case time.Time:
+		w.Time = v
+		return nil
+	case string:
+		return w.parse(v)
+	case []byte:
+		return w.parse(string(v))
+	}
+	return fmt.Errorf("cannot scan %T into a week start", value)
+}
+
+func (w *scannedWeek) parse(value string) error {
+	t, err := time.Parse(time.DateOnly, value)
+	w.Time = t
+	return err
+}
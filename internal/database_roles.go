@@ -0,0 +1,37 @@
+package tracker
+
+import (
+	"database/sql"
+
+	"github.com/Oriseer/workout_tracker/api"
+)
+
+func (db *DB) GetUserRole(username string) (string, error) {
+	var role string
+	err := db.Get(&role, "SELECT role FROM USERS WHERE username = $1", username)
+	if err == sql.ErrNoRows {
+		return "", api.ErrUserNotFound
+	}
+	return role, err
+}
+
+func (db *DB) GetUserList() ([]UserAccount, error) {
+	var users []UserAccount
+	err := db.Select(&users, "SELECT username, email, email_verified, role FROM USERS ORDER BY username")
+	return users, err
+}
+
+func (db *DB) SetUserRole(username, role string) error {
+	result, err := db.Exec("UPDATE USERS SET role = $1 WHERE username = $2", role, username)
+	if err != nil {
+		return err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return api.ErrUserNotFound
+	}
+	return nil
+}
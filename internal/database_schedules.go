@@ -0,0 +1,66 @@
+package tracker
+
+import (
+	"github.com/Oriseer/workout_tracker/api"
+)
+
+func (db *DB) AddWorkoutSchedule(owner string, schedule WorkoutSchedule) (WorkoutSchedule, error) {
+	if err := db.checkSessionPlan(owner, &schedule.PlanId); err != nil {
+		return WorkoutSchedule{}, err
+	}
+
+	err := db.Get(&schedule.Id, "INSERT INTO WORKOUT_SCHEDULES (owner, plan_id, starts_at, time_zone, rrule) VALUES ($1, $2, $3, $4, $5) RETURNING id",
+		owner, schedule.PlanId, schedule.StartsAt, schedule.TimeZone, schedule.RRule)
+	if err != nil {
+		return WorkoutSchedule{}, err
+	}
+	err = db.Get(&schedule.ExerciseName, "SELECT exercise_name FROM WORKOUT_PLAN WHERE id = $1", schedule.PlanId)
+	if err != nil {
+		return WorkoutSchedule{}, err
+	}
+	return schedule, nil
+}
+
+func (db *DB) GetWorkoutScheduleList(owner string) ([]WorkoutSchedule, error) {
+	var schedules []WorkoutSchedule
+	err := db.Select(&schedules, `SELECT sc.id, sc.plan_id, p.exercise_name, sc.starts_at, sc.time_zone, sc.rrule
+		FROM WORKOUT_SCHEDULES sc JOIN WORKOUT_PLAN p ON p.id = sc.plan_id
+		WHERE sc.owner = $1 ORDER BY sc.starts_at, sc.id`, owner)
+	if err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+func (db *DB) UpdateWorkoutSchedule(owner string, schedule WorkoutSchedule) error {
+	if err := db.checkScheduleOwner(owner, schedule.Id); err != nil {
+		return err
+	}
+	if err := db.checkSessionPlan(owner, &schedule.PlanId); err != nil {
+		return err
+	}
+
+	_, err := db.Exec("UPDATE WORKOUT_SCHEDULES SET plan_id = $1, starts_at = $2, time_zone = $3, rrule = $4 WHERE id = $5 AND owner = $6",
+		schedule.PlanId, schedule.StartsAt, schedule.TimeZone, schedule.RRule, schedule.Id, owner)
+	return err
+}
+
+func (db *DB) DeleteWorkoutSchedule(owner string, id int) error {
+	if err := db.checkScheduleOwner(owner, id); err != nil {
+		return err
+	}
+	_, err := db.Exec("DELETE FROM WORKOUT_SCHEDULES WHERE id = $1 AND owner = $2", id, owner)
+	return err
+}
+
+func (db *DB) checkScheduleOwner(owner string, id int) error {
+	var count int
+	err := db.Get(&count, "SELECT COUNT(*) FROM WORKOUT_SCHEDULES WHERE id = $1 AND owner = $2", id, owner)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return api.ErrScheduleNotFound
+	}
+	return nil
+}
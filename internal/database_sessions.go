@@ -0,0 +1,282 @@
+package tracker
+
+import (
+	"cmp"
+	"database/sql"
+	"slices"
+
+	"github.com/Oriseer/workout_tracker/api"
+	"github.com/jmoiron/sqlx"
+)
+
+func (db *DB) AddWorkoutSession(owner string, session WorkoutSession) (WorkoutSession, error) {
+	if err := db.checkSessionPlan(owner, session.PlanId); err != nil {
+		return WorkoutSession{}, err
+	}
+	if err := db.resolveSessionExercises(session.Exercises); err != nil {
+		return WorkoutSession{}, err
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return WorkoutSession{}, err
+	}
+	defer tx.Rollback()
+
+	err = tx.Get(&session.Id, "INSERT INTO WORKOUT_SESSIONS (owner, plan_id, started_at, ended_at, notes) VALUES ($1, $2, $3, $4, $5) RETURNING id",
+		owner, session.PlanId, session.StartedAt, session.EndedAt, session.Notes)
+	if err != nil {
+		return WorkoutSession{}, err
+	}
+	if err := insertSessionExercises(tx, session.Id, session.Exercises); err != nil {
+		return WorkoutSession{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return WorkoutSession{}, err
+	}
+
+	return db.GetWorkoutSession(owner, session.Id)
+}
+
+func (db *DB) GetWorkoutSessionList(owner string) ([]WorkoutSession, error) {
+	return db.selectWorkoutSessions("s.owner = $1", owner)
+}
+
+func (db *DB) GetWorkoutSessionPage(owner string, afterId, limit int) ([]WorkoutSession, error) {
+	sessions, err := db.selectWorkoutSessions("s.id IN (SELECT id FROM WORKOUT_SESSIONS WHERE owner = $1 AND id > $2 ORDER BY id LIMIT $3)",
+		owner, afterId, limit)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(sessions, func(a, b WorkoutSession) int {
+		return cmp.Compare(a.Id, b.Id)
+	})
+	return sessions, nil
+}
+
+// GetWorkoutSession returns the session with its exercises, sets and, when
+// linked, the plan it followed.
+func (db *DB) GetWorkoutSession(owner string, id int) (WorkoutSession, error) {
+	sessions, err := db.selectWorkoutSessions("s.owner = $1 AND s.id = $2", owner, id)
+	if err != nil {
+		return WorkoutSession{}, err
+	}
+	if len(sessions) == 0 {
+		return WorkoutSession{}, api.ErrSessionNotFound
+	}
+
+	session := sessions[0]
+	if session.PlanId != nil {
+		plan := WorkoutPlan{}
+		err := db.Get(&plan, "SELECT id, COALESCE(exercise_id, 0) AS exercise_id, exercise_name, repetitions, sets, weights FROM WORKOUT_PLAN WHERE id = $1 AND owner = $2",
+			*session.PlanId, owner)
+		if err == nil {
+			session.Plan = &plan
+		} else if err != sql.ErrNoRows {
+			return WorkoutSession{}, err
+		}
+	}
+	return session, nil
+}
+
+// UpdateWorkoutSession replaces the session, including all of its exercises
+// and sets.
+func (db *DB) UpdateWorkoutSession(owner string, session WorkoutSession) error {
+	if err := db.checkSessionOwner(owner, session.Id); err != nil {
+		return err
+	}
+	if err := db.checkSessionPlan(owner, session.PlanId); err != nil {
+		return err
+	}
+	if err := db.resolveSessionExercises(session.Exercises); err != nil {
+		return err
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("UPDATE WORKOUT_SESSIONS SET plan_id = $1, started_at = $2, ended_at = $3, notes = $4 WHERE id = $5 AND owner = $6",
+		session.PlanId, session.StartedAt, session.EndedAt, session.Notes, session.Id, owner)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM SESSION_EXERCISES WHERE session_id = $1", session.Id)
+	if err != nil {
+		return err
+	}
+	if err := insertSessionExercises(tx, session.Id, session.Exercises); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (db *DB) DeleteWorkoutSession(owner string, id int) error {
+	if err := db.checkSessionOwner(owner, id); err != nil {
+		return err
+	}
+	_, err := db.Exec("DELETE FROM WORKOUT_SESSIONS WHERE id = $1 AND owner = $2", id, owner)
+	return err
+}
+
+// AddLoggedSet appends a set to the last entry of the exercise in the
+// session, adding the exercise to the session when it is not there yet.
+func (db *DB) AddLoggedSet(owner string, sessionId int, input LoggedSetInput) (LoggedSet, error) {
+	if err := db.checkSessionOwner(owner, sessionId); err != nil {
+		return LoggedSet{}, err
+	}
+	exercise, err := db.findExercise(input.ExerciseId, input.ExerciseName)
+	if err != nil {
+		return LoggedSet{}, err
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return LoggedSet{}, err
+	}
+	defer tx.Rollback()
+
+	var sessionExerciseId int
+	err = tx.Get(&sessionExerciseId, "SELECT id FROM SESSION_EXERCISES WHERE session_id = $1 AND exercise_id = $2 ORDER BY position DESC LIMIT 1",
+		sessionId, exercise.Id)
+	if err == sql.ErrNoRows {
+		err = tx.Get(&sessionExerciseId, `INSERT INTO SESSION_EXERCISES (session_id, exercise_id, position)
+			VALUES ($1, $2, (SELECT COALESCE(MAX(position), 0) + 1 FROM SESSION_EXERCISES WHERE session_id = $1)) RETURNING id`,
+			sessionId, exercise.Id)
+	}
+	if err != nil {
+		return LoggedSet{}, err
+	}
+
+	set := input.LoggedSet
+	set.SessionExerciseId = sessionExerciseId
+	err = tx.Get(&set.Id, `INSERT INTO SESSION_SETS (session_exercise_id, position, reps, weight, rpe, notes)
+		VALUES ($1, (SELECT COALESCE(MAX(position), 0) + 1 FROM SESSION_SETS WHERE session_exercise_id = $1), $2, $3, $4, $5) RETURNING id`,
+		sessionExerciseId, set.Reps, set.Weight, set.RPE, set.Notes)
+	if err != nil {
+		return LoggedSet{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return LoggedSet{}, err
+	}
+	return set, nil
+}
+
+func (db *DB) checkSessionOwner(owner string, id int) error {
+	var count int
+	err := db.Get(&count, "SELECT COUNT(*) FROM WORKOUT_SESSIONS WHERE id = $1 AND owner = $2", id, owner)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return api.ErrSessionNotFound
+	}
+	return nil
+}
+
+// checkSessionPlan makes sure a session or schedule only links to a plan of
+// its owner.
+func (db *DB) checkSessionPlan(owner string, planId *int) error {
+	if planId == nil {
+		return nil
+	}
+	var count int
+	err := db.Get(&count, "SELECT COUNT(*) FROM WORKOUT_PLAN WHERE id = $1 AND owner = $2", *planId, owner)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return api.ErrWorkoutPlanNotFound
+	}
+	return nil
+}
+
+// resolveSessionExercises fills in the catalog id and name of every exercise.
+func (db *DB) resolveSessionExercises(exercises []SessionExercise) error {
+	for i := range exercises {
+		exercise, err := db.findExercise(exercises[i].ExerciseId, exercises[i].ExerciseName)
+		if err != nil {
+			return err
+		}
+		exercises[i].ExerciseId = exercise.Id
+		exercises[i].ExerciseName = exercise.Name
+	}
+	return nil
+}
+
+func insertSessionExercises(tx *sqlx.Tx, sessionId int, exercises []SessionExercise) error {
+	for i, exercise := range exercises {
+		var sessionExerciseId int
+		err := tx.Get(&sessionExerciseId, "INSERT INTO SESSION_EXERCISES (session_id, exercise_id, position) VALUES ($1, $2, $3) RETURNING id",
+			sessionId, exercise.ExerciseId, i+1)
+		if err != nil {
+			return err
+		}
+		for j, set := range exercise.Sets {
+			_, err := tx.Exec("INSERT INTO SESSION_SETS (session_exercise_id, position, reps, weight, rpe, notes) VALUES ($1, $2, $3, $4, $5, $6)",
+				sessionExerciseId, j+1, set.Reps, set.Weight, set.RPE, set.Notes)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// selectWorkoutSessions loads the sessions matching where, a condition on
+// WORKOUT_SESSIONS aliased as s, newest first.
+func (db *DB) selectWorkoutSessions(where string, args ...any) ([]WorkoutSession, error) {
+	var sessions []WorkoutSession
+	err := db.Select(&sessions, "SELECT s.id, s.plan_id, s.started_at, s.ended_at, s.notes FROM WORKOUT_SESSIONS s WHERE "+where+
+		" ORDER BY s.started_at DESC, s.id DESC", args...)
+	if err != nil || len(sessions) == 0 {
+		return sessions, err
+	}
+
+	var exercises []SessionExercise
+	err = db.Select(&exercises, `SELECT se.id, se.session_id, se.exercise_id, e.exercise_name FROM SESSION_EXERCISES se
+		JOIN EXERCISES e ON e.id = se.exercise_id
+		JOIN WORKOUT_SESSIONS s ON s.id = se.session_id
+		WHERE `+where+" ORDER BY se.session_id, se.position", args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var sets []LoggedSet
+	err = db.Select(&sets, `SELECT ss.id, ss.session_exercise_id, ss.reps, ss.weight, ss.rpe, ss.notes FROM SESSION_SETS ss
+		JOIN SESSION_EXERCISES se ON se.id = ss.session_exercise_id
+		JOIN WORKOUT_SESSIONS s ON s.id = se.session_id
+		WHERE `+where+" ORDER BY ss.session_exercise_id, ss.position", args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return assembleWorkoutSessions(sessions, exercises, sets), nil
+}
+
+// assembleWorkoutSessions nests ordered exercise and set rows into their sessions.
+func assembleWorkoutSessions(sessions []WorkoutSession, exercises []SessionExercise, sets []LoggedSet) []WorkoutSession {
+	setsByExercise := map[int][]LoggedSet{}
+	for _, set := range sets {
+		setsByExercise[set.SessionExerciseId] = append(setsByExercise[set.SessionExerciseId], set)
+	}
+
+	exercisesBySession := map[int][]SessionExercise{}
+	for _, exercise := range exercises {
+		exercise.Sets = setsByExercise[exercise.Id]
+		if exercise.Sets == nil {
+			exercise.Sets = []LoggedSet{}
+		}
+		exercisesBySession[exercise.SessionId] = append(exercisesBySession[exercise.SessionId], exercise)
+	}
+
+	for i := range sessions {
+		sessions[i].Exercises = exercisesBySession[sessions[i].Id]
+		if sessions[i].Exercises == nil {
+			sessions[i].Exercises = []SessionExercise{}
+		}
+	}
+	return sessions
+}
@@ -0,0 +1,110 @@
+package tracker
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/Oriseer/workout_tracker/api"
+	"github.com/Oriseer/workout_tracker/middleware"
+)
+
+func (db *DB) AddRefreshToken(token RefreshToken) error {
+	_, err := db.Exec("INSERT INTO REFRESH_TOKENS (token_hash, family_id, username, expires_at) VALUES ($1, $2, $3, $4)",
+		token.TokenHash, token.FamilyId, token.Username, token.ExpiresAt)
+	return err
+}
+
+func (db *DB) UseRefreshToken(tokenHash string) (RefreshToken, error) {
+	// Marking the token used in a single statement makes concurrent refreshes
+	// with the same token count as reuse.
+	result, err := db.Exec("UPDATE REFRESH_TOKENS SET used_at = $1 WHERE token_hash = $2 AND used_at IS NULL", time.Now(), tokenHash)
+	if err != nil {
+		return RefreshToken{}, err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return RefreshToken{}, err
+	}
+
+	token := RefreshToken{}
+	err = db.Get(&token, "SELECT token_hash, family_id, username, expires_at, used_at, revoked_at FROM REFRESH_TOKENS WHERE token_hash = $1", tokenHash)
+	if err == sql.ErrNoRows {
+		return RefreshToken{}, api.ErrInvalidRefreshToken
+	} else if err != nil {
+		return RefreshToken{}, err
+	}
+
+	if updated == 0 {
+		return token, api.ErrRefreshTokenReused
+	}
+	return token, nil
+}
+
+func (db *DB) RevokeRefreshTokenFamily(familyId string) error {
+	_, err := db.Exec("UPDATE REFRESH_TOKENS SET revoked_at = $1 WHERE family_id = $2 AND revoked_at IS NULL", time.Now(), familyId)
+	return err
+}
+
+func (db *DB) RevokeToken(jti string, expiresAt time.Time) error {
+	_, err := db.Exec("INSERT INTO REVOKED_TOKENS (jti, expires_at) VALUES ($1, $2) ON CONFLICT (jti) DO NOTHING", jti, expiresAt)
+	return err
+}
+
+func (db *DB) RevokeUserTokens(username string, before time.Time) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before = before.Truncate(middleware.TokenTimePrecision)
+	_, err = tx.Exec("UPDATE USERS SET tokens_revoked_before = $1 WHERE username = $2", before, username)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE REFRESH_TOKENS SET revoked_at = $1 WHERE username = $2 AND revoked_at IS NULL", time.Now(), username)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (db *DB) RevokeUserRefreshToken(username, tokenHash string) error {
+	_, err := db.Exec(`UPDATE REFRESH_TOKENS SET revoked_at = $1
+		WHERE family_id IN (SELECT family_id FROM REFRESH_TOKENS WHERE token_hash = $2 AND username = $3) AND revoked_at IS NULL`,
+		time.Now(), tokenHash, username)
+	return err
+}
+
+func (db *DB) IsTokenRevoked(jti, username string, issuedAt time.Time) (bool, error) {
+	var count int
+	err := db.Get(&count, `SELECT (SELECT COUNT(*) FROM REVOKED_TOKENS WHERE jti = $1)
+		+ (SELECT COUNT(*) FROM USERS WHERE username = $2 AND tokens_revoked_before > $3)`,
+		jti, username, issuedAt)
+	return count > 0, err
+}
+
+func (db *DB) PruneRevokedTokens(now time.Time) (int64, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var pruned int64
+	for _, query := range []string{
+		"DELETE FROM REVOKED_TOKENS WHERE expires_at < $1",
+		"DELETE FROM REFRESH_TOKENS WHERE expires_at < $1",
+	} {
+		result, err := tx.Exec(query, now)
+		if err != nil {
+			return 0, err
+		}
+		deleted, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		pruned += deleted
+	}
+	return pruned, tx.Commit()
+}
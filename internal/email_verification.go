@@ -0,0 +1,94 @@
+package tracker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Oriseer/workout_tracker/api"
+	"github.com/Oriseer/workout_tracker/middleware"
+)
+
+// EmailVerificationToken is the server side record of the token in a
+// verification link. Only its hash is stored and it can be used once.
+type EmailVerificationToken struct {
+	TokenHash string     `db:"token_hash"`
+	Username  string     `db:"username"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	UsedAt    *time.Time `db:"used_at"`
+}
+
+// EmailVerificationStore persists email verification tokens.
+type EmailVerificationStore interface {
+	AddEmailVerificationToken(token EmailVerificationToken) error
+	// VerifyEmail marks the token used and the email of its user verified,
+	// returning the username. Unknown, used and expired tokens yield
+	// api.ErrInvalidVerificationToken.
+	VerifyEmail(tokenHash string, now time.Time) (string, error)
+}
+
+type EmailVerification struct {
+	Username      string `json:"username"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+// sendVerificationMail mails a verification link for the newly registered
+// user. Delivery failures are logged, the account exists either way.
+// Without a mailer no link is made, the account stays unverified.
+func (ws *WorkoutServer) sendVerificationMail(ctx context.Context, user UserDetails) error {
+	if ws.mailer == nil {
+		return nil
+	}
+	token, tokenHash, err := newOpaqueToken()
+	if err != nil {
+		return err
+	}
+	err = ws.store.AddEmailVerificationToken(EmailVerificationToken{
+		TokenHash: tokenHash,
+		Username:  user.Username,
+		ExpiresAt: time.Now().Add(ws.config.Auth.EmailVerificationTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := ws.mailer.Send(ws.verificationMail(user, token)); err != nil {
+		middleware.Logger(ctx).Error("sending verification mail", "to", user.Username, "error", err)
+	}
+	return nil
+}
+
+// verifyEmailHandler activates the account of the verification link token.
+func (ws *WorkoutServer) verifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		api.StatusBadRequestServerError(w, api.ErrInvalidVerificationToken)
+		return
+	}
+
+	username, err := ws.store.VerifyEmail(hashToken(token), time.Now())
+	if err == api.ErrInvalidVerificationToken {
+		api.StatusBadRequestServerError(w, err)
+		return
+	} else if err != nil {
+		api.DatabaseError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(EmailVerification{Username: username, EmailVerified: true})
+}
+
+func (ws *WorkoutServer) verificationMail(user UserDetails, token string) Mail {
+	link := ws.config.PublicURL + "/auth/verify?token=" + url.QueryEscape(token)
+	return Mail{
+		To:      user.Email,
+		Subject: "Verify your Workout Tracker email",
+		Body: fmt.Sprintf("Hello %s,\n\n"+
+			"Open the link below to verify your email. It expires in %s.\n\n%s\n",
+			user.Username, ws.config.Auth.EmailVerificationTTL, link),
+	}
+}
@@ -0,0 +1,91 @@
+package tracker
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/Oriseer/workout_tracker/api"
+	"github.com/Oriseer/workout_tracker/middleware"
+)
+
+// maxAliasLength is the longest alias the EXERCISE_ALIASES table holds.
+const maxAliasLength = 255
+
+// ExerciseAlias is another name of a catalog exercise, such as the name an
+// exercise has in the exports of another app.
+type ExerciseAlias struct {
+	Alias        string `json:"alias" db:"alias"`
+	ExerciseId   int    `json:"exercise_id,omitempty" db:"exercise_id"`
+	ExerciseName string `json:"exercise_name" db:"exercise_name"`
+}
+
+// ExerciseAliasStore keeps the aliases of every owner. Aliases are stored
+// lower case, so they are unique per owner ignoring case, and are removed
+// with their exercise.
+type ExerciseAliasStore interface {
+	// GetExerciseAliases returns the aliases of the owner sorted by alias.
+	GetExerciseAliases(owner string) ([]ExerciseAlias, error)
+	// SetExerciseAlias adds or replaces the alias for the exercise with
+	// ExerciseId, or ExerciseName when no id is given, and returns it as
+	// stored.
+	SetExerciseAlias(owner string, alias ExerciseAlias) (ExerciseAlias, error)
+	DeleteExerciseAlias(owner, alias string) error
+}
+
+// normalizeAlias is the form aliases are stored and looked up in.
+func normalizeAlias(alias string) string {
+	return strings.ToLower(strings.TrimSpace(alias))
+}
+
+func (ws *WorkoutServer) getExerciseAliasListHandler(w http.ResponseWriter, r *http.Request) {
+	owner, _ := middleware.Username(r.Context())
+	aliases, err := ws.store.GetExerciseAliases(owner)
+	if err != nil {
+		api.DatabaseError(w, err)
+		return
+	}
+	if aliases == nil {
+		aliases = []ExerciseAlias{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(aliases)
+}
+
+func (ws *WorkoutServer) setExerciseAliasHandler(w http.ResponseWriter, r *http.Request) {
+	owner, _ := middleware.Username(r.Context())
+	alias := ExerciseAlias{}
+	if err := ws.jsonDecode(r, &alias); err != nil {
+		api.RequestBodyError(w, err)
+		return
+	}
+	alias.Alias = normalizeAlias(r.PathValue("alias"))
+	if alias.Alias == "" || len(alias.Alias) > maxAliasLength || (alias.ExerciseId == 0 && alias.ExerciseName == "") {
+		api.StatusBadRequestServerError(w, api.ErrInvalidExerciseAlias)
+		return
+	}
+
+	alias, err := ws.store.SetExerciseAlias(owner, alias)
+	if err == api.ErrExerciseNotFound {
+		api.StatusBadRequestServerError(w, err)
+		return
+	} else if err != nil {
+		api.DatabaseError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(alias)
+}
+
+func (ws *WorkoutServer) deleteExerciseAliasHandler(w http.ResponseWriter, r *http.Request) {
+	owner, _ := middleware.Username(r.Context())
+	err := ws.store.DeleteExerciseAlias(owner, normalizeAlias(r.PathValue("alias")))
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case api.ErrExerciseAliasNotFound:
+		api.NotFoundError(w, err)
+	default:
+		api.DatabaseError(w, err)
+	}
+}
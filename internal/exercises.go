@@ -0,0 +1,160 @@
+package tracker
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Oriseer/workout_tracker/api"
+)
+
+// DemoExercises seed an empty catalog, for the in-memory store and by the
+// dataseeder command.
+var DemoExercises = []Exercise{
+	{Name: "pushup", Description: "bodyweight exercise", Category: "strength"},
+	{Name: "pullup", Description: "bodyweight exercise", Category: "strength"},
+	{Name: "curlup", Description: "bodyweight exercise", Category: "strength"},
+	{Name: "bench press", Description: "barbell", Category: "strength"},
+}
+
+type Exercise struct {
+	Id          int    `json:"id" db:"id"`
+	Name        string `json:"name" db:"exercise_name"`
+	Description string `json:"description" db:"description"`
+	Category    string `json:"category" db:"category"`
+}
+
+// ExerciseFilter narrows the exercise catalog. Empty fields match everything,
+// Name matches any exercise whose name contains it, ignoring case.
+type ExerciseFilter struct {
+	Category string
+	Name     string
+}
+
+// ExerciseStore manages the exercise catalog shared by all users. Exercise
+// names are unique ignoring case.
+type ExerciseStore interface {
+	GetExerciseList(filter ExerciseFilter) ([]Exercise, error)
+	GetExercise(id int) (Exercise, error)
+	AddExercise(exercise Exercise) (Exercise, error)
+	UpdateExercise(exercise Exercise) error
+	DeleteExercise(id int) error
+}
+
+func (ws *WorkoutServer) getExerciseListHandler(w http.ResponseWriter, r *http.Request) {
+	filter := ExerciseFilter{
+		Category: r.URL.Query().Get("category"),
+		Name:     r.URL.Query().Get("name"),
+	}
+	list, err := ws.store.GetExerciseList(filter)
+	if err != nil {
+		api.DatabaseError(w, err)
+		return
+	}
+	if list == nil {
+		list = []Exercise{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+func (ws *WorkoutServer) getExerciseHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		api.StatusBadRequestServerError(w, api.ErrInvalidID)
+		return
+	}
+	exercise, err := ws.store.GetExercise(id)
+	if err == api.ErrExerciseNotFound {
+		api.NotFoundError(w, err)
+		return
+	} else if err != nil {
+		api.DatabaseError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(exercise)
+}
+
+func (ws *WorkoutServer) addExerciseHandler(w http.ResponseWriter, r *http.Request) {
+	exercise := Exercise{}
+	if err := ws.jsonDecode(r, &exercise); err != nil {
+		api.RequestBodyError(w, err)
+		return
+	}
+	if err := validateExercise(exercise); err != nil {
+		api.StatusBadRequestServerError(w, err)
+		return
+	}
+
+	exercise, err := ws.store.AddExercise(exercise)
+	if err == api.ErrExerciseExists {
+		api.StatusBadRequestServerError(w, err)
+		return
+	} else if err != nil {
+		api.DatabaseError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(exercise)
+}
+
+func (ws *WorkoutServer) updateExerciseHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		api.StatusBadRequestServerError(w, api.ErrInvalidID)
+		return
+	}
+	exercise := Exercise{}
+	if err := ws.jsonDecode(r, &exercise); err != nil {
+		api.RequestBodyError(w, err)
+		return
+	}
+	if err := validateExercise(exercise); err != nil {
+		api.StatusBadRequestServerError(w, err)
+		return
+	}
+	exercise.Id = id
+
+	err = ws.store.UpdateExercise(exercise)
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case api.ErrExerciseNotFound:
+		api.NotFoundError(w, err)
+	case api.ErrExerciseExists:
+		api.StatusBadRequestServerError(w, err)
+	default:
+		api.DatabaseError(w, err)
+	}
+}
+
+func (ws *WorkoutServer) deleteExerciseHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		api.StatusBadRequestServerError(w, api.ErrInvalidID)
+		return
+	}
+
+	err = ws.store.DeleteExercise(id)
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case api.ErrExerciseNotFound:
+		api.NotFoundError(w, err)
+	case api.ErrExerciseInUse:
+		api.StatusBadRequestServerError(w, err)
+	default:
+		api.DatabaseError(w, err)
+	}
+}
+
+func validateExercise(exercise Exercise) error {
+	if strings.TrimSpace(exercise.Name) == "" {
+		return api.ErrInvalidExercise
+	}
+	return nil
+}
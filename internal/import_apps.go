@@ -0,0 +1,472 @@
+package tracker
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Oriseer/workout_tracker/api"
+)
+
+// Formats of the CSV exports of other apps.
+const (
+	formatStrong = "strong"
+	formatHevy   = "hevy"
+)
+
+// Units of weights.
+const (
+	unitKg = "kg"
+	unitLb = "lb"
+)
+
+const (
+	kgPerLb = 0.45359237
+	kmPerMi = 1.609344
+)
+
+// strongTimeLayout is the Date column of Strong exports, in local time.
+const strongTimeLayout = "2006-01-02 15:04:05"
+
+// hevyTimeLayouts are the start_time and end_time columns of Hevy exports,
+// in local time. Older exports wrote dates like Strong does.
+var hevyTimeLayouts = []string{"2 Jan 2006, 15:04", strongTimeLayout}
+
+// appExerciseAliases map names other apps use onto the names of the demo
+// catalog, both in the form exerciseKey makes of them. Names that only
+// differ in case, spaces, punctuation or the equipment in parentheses are
+// found without an alias.
+var appExerciseAliases = map[string]string{
+	"barbellbenchpress": "bench press",
+	"flatbenchpress":    "bench press",
+	"pressup":           "pushup",
+	"situp":             "curlup",
+	"crunch":            "curlup",
+}
+
+// UnmappedExercise is an exercise of a Strong or Hevy import that is not in
+// the catalog and has no alias. Row is the first row of the exercise,
+// counting the header as row 1.
+type UnmappedExercise struct {
+	Name string `json:"name"`
+	Sets int    `json:"sets"`
+	Row  int    `json:"row"`
+}
+
+// appImport holds the options of a Strong or Hevy import.
+type appImport struct {
+	// unit is the unit weights are imported in.
+	unit string
+	// sourceUnit is the unit of the weights of exports that do not say.
+	sourceUnit string
+	// location is the time zone of the local times of the export.
+	location     *time.Location
+	skipUnmapped bool
+}
+
+// appImportOptions reads the query parameters of a Strong or Hevy import:
+// unit, source_unit, time_zone and skip_unmapped.
+func appImportOptions(query url.Values) (appImport, error) {
+	options := appImport{unit: unitKg, location: time.UTC}
+	var ok bool
+	if value := query.Get("unit"); value != "" {
+		if options.unit, ok = parseWeightUnit(value); !ok {
+			return appImport{}, api.ErrInvalidQuery
+		}
+	}
+	options.sourceUnit = options.unit
+	if value := query.Get("source_unit"); value != "" {
+		if options.sourceUnit, ok = parseWeightUnit(value); !ok {
+			return appImport{}, api.ErrInvalidQuery
+		}
+	}
+	if value := query.Get("time_zone"); value != "" {
+		location, err := time.LoadLocation(value)
+		if err != nil {
+			return appImport{}, api.ErrInvalidTimeZone
+		}
+		options.location = location
+	}
+	if value := query.Get("skip_unmapped"); value != "" {
+		var err error
+		if options.skipUnmapped, err = strconv.ParseBool(value); err != nil {
+			return appImport{}, api.ErrInvalidQuery
+		}
+	}
+	return options, nil
+}
+
+func parseWeightUnit(value string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "kg", "kgs":
+		return unitKg, true
+	case "lb", "lbs":
+		return unitLb, true
+	}
+	return "", false
+}
+
+// convertWeight converts a weight between units, rounded to hundredths.
+func convertWeight(weight float64, from, to string) float64 {
+	if from == to {
+		return weight
+	}
+	if from == unitLb {
+		weight *= kgPerLb
+	} else {
+		weight /= kgPerLb
+	}
+	return math.Round(weight*100) / 100
+}
+
+func writeUnmappedExercises(w http.ResponseWriter, unmapped []UnmappedExercise) {
+	if recorder, ok := w.(api.ErrorRecorder); ok {
+		recorder.RecordError(fmt.Errorf("%w: %d exercises", api.ErrUnmappedExercises, len(unmapped)))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(ImportErrorWriter{
+		ErrorMessage: "Bad Request: " + api.ErrUnmappedExercises.Error(),
+		Code:         http.StatusBadRequest,
+		Errors:       []ImportRowError{},
+		Unmapped:     unmapped,
+	})
+}
+
+// exerciseKey is the form names are compared in when mapping the exercises
+// of other apps: lower case letters and digits only.
+func exerciseKey(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, name)
+}
+
+// useAliases adds the aliases of the owner to the names mapExercise finds.
+func (i *importer) useAliases(aliases []ExerciseAlias) {
+	for _, alias := range aliases {
+		i.aliases[normalizeAlias(alias.Alias)] = alias.ExerciseId
+	}
+}
+
+// mapExercise finds the catalog exercise an app means by name: an alias of
+// the owner, the exercise of that name, an exercise of appExerciseAliases,
+// and last the same without the equipment Strong and Hevy put in
+// parentheses, as in "Squat (Barbell)".
+func (i *importer) mapExercise(name string) (Exercise, bool) {
+	if id, ok := i.aliases[normalizeAlias(name)]; ok {
+		if exercise, ok := i.exercisesById[id]; ok {
+			return exercise, true
+		}
+	}
+	names := []string{name}
+	if base, _, ok := strings.Cut(name, "("); ok {
+		names = append(names, base)
+	}
+	for _, name := range names {
+		key := exerciseKey(name)
+		if exercise, ok := i.exercisesByKey[key]; ok {
+			return exercise, true
+		}
+		if alias, ok := appExerciseAliases[key]; ok {
+			if exercise, ok := i.exercisesByKey[exerciseKey(alias)]; ok {
+				return exercise, true
+			}
+		}
+	}
+	return Exercise{}, false
+}
+
+// unmap counts a set of an exercise mapExercise did not find.
+func (i *importer) unmap(row importRow, name string) {
+	for n := range i.unmapped {
+		if i.unmapped[n].Name == name {
+			i.unmapped[n].Sets++
+			return
+		}
+	}
+	i.unmapped = append(i.unmapped, UnmappedExercise{Name: name, Sets: 1, Row: row.row})
+}
+
+// appRow is a row of a Strong or Hevy export: a set of a workout, or a
+// workout row without a set.
+type appRow struct {
+	// workout tells the workouts of an export apart.
+	workout  string
+	session  WorkoutSession
+	exercise string
+	set      *LoggedSet
+}
+
+// appColumns reads the fields of the rows of an app export by column name.
+type appColumns struct {
+	columns map[string]int
+	fields  []string
+}
+
+func (c *appColumns) has(name string) bool {
+	_, ok := c.columns[name]
+	return ok
+}
+
+func (c *appColumns) field(name string) string {
+	if n, ok := c.columns[name]; ok && n < len(c.fields) {
+		return strings.TrimSpace(c.fields[n])
+	}
+	return ""
+}
+
+// readApp reads the CSV export of Strong or Hevy into sessions, one for
+// every workout, mapping the exercises onto the catalog. Sets of exercises
+// that are not found are left out and counted in unmapped, and so are
+// workouts left without sets.
+func (i *importer) readApp(body io.Reader, format string, options appImport) error {
+	reader, err := appCSVReader(body)
+	if err != nil {
+		return err
+	}
+	header, err := reader.Read()
+	if err == io.EOF {
+		return errors.New("missing CSV header")
+	} else if err != nil {
+		return err
+	}
+	columns := &appColumns{columns: map[string]int{}}
+	for n, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		columns.columns[name] = n
+	}
+
+	required := []string{"date", "workout name", "exercise name", "reps"}
+	parse := readStrongRow
+	if format == formatHevy {
+		required = []string{"title", "start_time", "exercise_title", "reps"}
+		parse = readHevyRow
+	}
+	for _, name := range required {
+		if !columns.has(name) {
+			return fmt.Errorf("missing CSV column %q, is this a %s export?", name, format)
+		}
+	}
+
+	workouts := map[string]int{}
+	for {
+		columns.fields, err = reader.Read()
+		if err == io.EOF {
+			i.dropEmptySessions()
+			return nil
+		} else if err != nil {
+			return err
+		}
+		line, _ := reader.FieldPos(0)
+		row := importRow{line, format}
+		values := csvValues{field: columns.field}
+		app := parse(columns, &values, options)
+		if values.err != nil {
+			i.fail(row, values.err)
+			continue
+		}
+
+		if app.set != nil {
+			exercise, ok := i.mapExercise(app.exercise)
+			if ok {
+				app.session.Exercises = []SessionExercise{{ExerciseId: exercise.Id, ExerciseName: exercise.Name, Sets: []LoggedSet{*app.set}}}
+			} else {
+				i.unmap(row, app.exercise)
+			}
+		}
+		n, ok := workouts[app.workout]
+		if ok {
+			i.addSessionRow(n, app.session)
+		} else {
+			n = len(i.sessions)
+			workouts[app.workout] = n
+			i.sessions = append(i.sessions, app.session)
+			i.sessionRows = append(i.sessionRows, nil)
+		}
+		// Only the rows of kept sets are recorded, check finds the row of a
+		// set by its position in the session
+		if len(app.session.Exercises) > 0 {
+			i.sessionRows[n] = append(i.sessionRows[n], row)
+		}
+	}
+}
+
+// dropEmptySessions leaves out the workouts without a kept set, such as
+// those of only unmapped exercises and rest timers.
+func (i *importer) dropEmptySessions() {
+	kept := 0
+	for n, session := range i.sessions {
+		if len(session.Exercises) > 0 {
+			i.sessions[kept], i.sessionRows[kept] = session, i.sessionRows[n]
+			kept++
+		}
+	}
+	i.sessions, i.sessionRows = i.sessions[:kept], i.sessionRows[:kept]
+}
+
+// appCSVReader reads CSV separated by commas or, as some Strong versions
+// write, by semicolons, whichever the header has more of.
+func appCSVReader(body io.Reader) (*csv.Reader, error) {
+	buffered := bufio.NewReader(body)
+	header, err := buffered.Peek(4096)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+	if end := strings.IndexByte(string(header), '\n'); end >= 0 {
+		header = header[:end]
+	}
+	reader := csv.NewReader(buffered)
+	reader.FieldsPerRecord = -1
+	if strings.Count(string(header), ";") > strings.Count(string(header), ",") {
+		reader.Comma = ';'
+	}
+	return reader, nil
+}
+
+// readStrongRow reads a row of a Strong export. Weights are in the unit of
+// the Weight Unit column, which only older exports have, or else in the
+// source unit. Rest timers are rows without a set.
+func readStrongRow(c *appColumns, values *csvValues, options appImport) appRow {
+	startedAt := values.localTime("date", options.location, strongTimeLayout)
+	duration := c.field("duration")
+	if duration == "" {
+		duration = c.field("workout duration")
+	}
+	session := WorkoutSession{StartedAt: startedAt, Notes: joinNotes("\n", c.field("workout name"), c.field("workout notes")), Exercises: []SessionExercise{}}
+	if duration != "" {
+		// Durations are written like "1h 5m", or in seconds by older exports
+		d, err := time.ParseDuration(strings.ReplaceAll(duration, " ", ""))
+		if seconds, atoiErr := strconv.Atoi(duration); atoiErr == nil {
+			d, err = time.Duration(seconds)*time.Second, nil
+		}
+		if err != nil && values.err == nil {
+			values.err = fmt.Errorf("invalid duration %q", duration)
+		}
+		if d > 0 {
+			endedAt := startedAt.Add(d)
+			session.EndedAt = &endedAt
+		}
+	}
+	app := appRow{workout: c.field("date") + "\x00" + c.field("workout name"), session: session, exercise: c.field("exercise name")}
+
+	var kind string
+	switch order := c.field("set order"); strings.ToUpper(order) {
+	case "REST TIMER":
+		return app
+	case "W":
+		kind = "warm-up"
+	case "D":
+		kind = "drop set"
+	case "F":
+		kind = "failure"
+	default:
+		if _, err := strconv.Atoi(order); err != nil && order != "" && values.err == nil {
+			values.err = fmt.Errorf("invalid set order %q", order)
+		}
+	}
+
+	unit := options.sourceUnit
+	if value := c.field("weight unit"); value != "" {
+		var ok bool
+		if unit, ok = parseWeightUnit(value); !ok && values.err == nil {
+			values.err = fmt.Errorf("invalid weight unit %q", value)
+		}
+	}
+	distance := values.float("distance")
+	if strings.HasPrefix(strings.ToLower(c.field("distance unit")), "mi") {
+		distance *= kmPerMi
+	}
+	app.set = &LoggedSet{
+		// Strong writes reps like "8.0"
+		Reps:   int(math.Round(values.float("reps"))),
+		Weight: convertWeight(values.float("weight"), unit, options.unit),
+		RPE:    values.optionalFloat("rpe"),
+		Notes:  setNotes(kind, distance, values.float("seconds"), c.field("notes")),
+	}
+	return app
+}
+
+// readHevyRow reads a row of a Hevy export. Weights and distances are in the
+// unit the weight_kg or weight_lbs and distance_km or distance_miles columns
+// name.
+func readHevyRow(c *appColumns, values *csvValues, options appImport) appRow {
+	session := WorkoutSession{
+		StartedAt: values.localTime("start_time", options.location, hevyTimeLayouts...),
+		Notes:     joinNotes("\n", c.field("title"), c.field("description")),
+		Exercises: []SessionExercise{},
+	}
+	if endedAt := values.localTime("end_time", options.location, hevyTimeLayouts...); !endedAt.IsZero() {
+		session.EndedAt = &endedAt
+	}
+	app := appRow{workout: c.field("start_time") + "\x00" + c.field("title"), session: session, exercise: c.field("exercise_title")}
+
+	weight := convertWeight(values.float("weight_kg"), unitKg, options.unit)
+	if c.has("weight_lbs") {
+		weight = convertWeight(values.float("weight_lbs"), unitLb, options.unit)
+	}
+	distance := values.float("distance_km")
+	if c.has("distance_miles") {
+		distance = values.float("distance_miles") * kmPerMi
+	}
+	kind := c.field("set_type")
+	if kind == "normal" {
+		kind = ""
+	}
+	app.set = &LoggedSet{
+		Reps:   values.int("reps"),
+		Weight: weight,
+		RPE:    values.optionalFloat("rpe"),
+		Notes:  setNotes(kind, distance, values.float("duration_seconds"), c.field("exercise_notes")),
+	}
+	return app
+}
+
+// setNotes describes what a set of another app has that a LoggedSet has no
+// field for.
+func setNotes(kind string, distanceKm, seconds float64, notes string) string {
+	var distance, duration string
+	if distanceKm > 0 {
+		distance = strconv.FormatFloat(math.Round(distanceKm*100)/100, 'f', -1, 64) + " km"
+	}
+	if seconds > 0 {
+		duration = (time.Duration(seconds) * time.Second).String()
+	}
+	return joinNotes(", ", kind, distance, duration, notes)
+}
+
+// joinNotes joins the notes that are not empty.
+func joinNotes(separator string, notes ...string) string {
+	var kept []string
+	for _, note := range notes {
+		if note != "" {
+			kept = append(kept, note)
+		}
+	}
+	return strings.Join(kept, separator)
+}
+
+// localTime parses a time in one of layouts in location.
+func (v *csvValues) localTime(name string, location *time.Location, layouts ...string) (t time.Time) {
+	v.parse(name, func(value string) (err error) {
+		for _, layout := range layouts {
+			if t, err = time.ParseInLocation(layout, value, location); err == nil {
+				return nil
+			}
+		}
+		return err
+	})
+	return t
+}
@@ -0,0 +1,211 @@
+package tracker
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Oriseer/workout_tracker/api"
+)
+
+func TestAppImports(t *testing.T) {
+	store := NewInMemoryStore()
+	for _, name := range []string{"bench press", "pushup", "curlup", "lat pulldown"} {
+		store.AddExercise(Exercise{Name: name, Category: "strength"})
+	}
+	server := newTestServer(store)
+
+	imported := func(t *testing.T, response *httptest.ResponseRecorder) ImportResult {
+		t.Helper()
+		AssertResponseStatus(t, http.StatusCreated, response.Code)
+		result := ImportResult{}
+		json.NewDecoder(response.Body).Decode(&result)
+		return result
+	}
+	sessions := func(t *testing.T, username string) []WorkoutSession {
+		t.Helper()
+		list, _ := store.GetWorkoutSessionList(username)
+		for n := range list {
+			list[n], _ = store.GetWorkoutSession(username, list[n].Id)
+		}
+		return list
+	}
+
+	strong := strings.Join([]string{
+		"Date,Workout Name,Duration,Exercise Name,Set Order,Weight,Reps,Distance,Seconds,Notes,Workout Notes,RPE",
+		`2025-06-02 07:00:00,Push Day,1h 5m,Bench Press (Barbell),W,95,10.0,0,0,,"felt strong",`,
+		"2025-06-02 07:00:00,Push Day,1h 5m,Bench Press (Barbell),1,135,8.0,0,0,,,8",
+		"2025-06-02 07:00:00,Push Day,1h 5m,Bench Press (Barbell),Rest Timer,0,0,0,90,,,",
+		"2025-06-02 07:00:00,Push Day,1h 5m,Push Up,1,0,20.0,0,0,,,",
+		"2025-06-02 07:00:00,Push Day,1h 5m,Running,1,0,0,5,1800,easy,,",
+		"2025-06-04 18:30:00,Pull Day,45m,Lat Pulldown (Cable),1,100,12.0,0,0,,,",
+		"2025-06-04 18:30:00,Pull Day,45m,Face Pull (Cable),1,30,15.0,0,0,,,",
+		"2025-06-04 18:30:00,Pull Day,45m,Face Pull (Cable),2,30,15.0,0,0,,,",
+	}, "\n")
+
+	t.Run("reports unmapped exercises and imports none", func(t *testing.T) {
+		alice := server.register(t, "alice")
+		response := server.send(http.MethodPost, "/import?format=strong&source_unit=lb", alice, strong)
+		AssertResponseStatus(t, http.StatusBadRequest, response.Code)
+		body := ImportErrorWriter{}
+		json.NewDecoder(response.Body).Decode(&body)
+		if body.ErrorMessage != "Bad Request: "+api.ErrUnmappedExercises.Error() {
+			t.Errorf("Expected an api.ErrUnmappedExercises message, got %q", body.ErrorMessage)
+		}
+		expected := []UnmappedExercise{{Name: "Running", Sets: 1, Row: 6}, {Name: "Face Pull (Cable)", Sets: 2, Row: 8}}
+		if len(body.Unmapped) != 2 || body.Unmapped[0] != expected[0] || body.Unmapped[1] != expected[1] {
+			t.Errorf("Expected %+v, got %+v", expected, body.Unmapped)
+		}
+		if list := sessions(t, "alice"); len(list) != 0 {
+			t.Errorf("Expected nothing to be imported, got %+v", list)
+		}
+	})
+
+	t.Run("imports Strong exports once the exercises are mapped", func(t *testing.T) {
+		bob := server.register(t, "bob")
+		AssertResponseStatus(t, http.StatusOK, server.send(http.MethodPut, "/exercise-aliases/"+url.PathEscape("Face Pull (Cable)"), bob, `{"exercise_name": "lat pulldown"}`).Code)
+
+		result := imported(t, server.send(http.MethodPost, "/import?format=strong&source_unit=lb&skip_unmapped=true", bob, strong))
+		if result.Sessions != 2 || result.Sets != 6 || len(result.Unmapped) != 1 || result.Unmapped[0].Name != "Running" {
+			t.Errorf("Expected 2 sessions and 6 sets without the run, got %+v", result)
+		}
+		list := sessions(t, "bob")
+		if len(list) != 2 {
+			t.Fatalf("Expected 2 sessions, got %+v", list)
+		}
+		// Sessions are listed newest first
+		push := list[1]
+		if !push.StartedAt.Equal(time.Date(2025, 6, 2, 7, 0, 0, 0, time.UTC)) || push.EndedAt == nil || push.EndedAt.Sub(push.StartedAt) != 65*time.Minute || push.Notes != "Push Day\nfelt strong" {
+			t.Errorf("Expected the push day of an hour and 5 minutes, got %+v", push)
+		}
+		if len(push.Exercises) != 2 || push.Exercises[0].ExerciseName != "bench press" || push.Exercises[1].ExerciseName != "pushup" {
+			t.Fatalf("Expected bench press and pushup, got %+v", push.Exercises)
+		}
+		sets := push.Exercises[0].Sets
+		if len(sets) != 2 || sets[0].Weight != 43.09 || sets[0].Reps != 10 || sets[0].Notes != "warm-up" || sets[1].Weight != 61.23 || *sets[1].RPE != 8 {
+			t.Errorf("Expected the bench press sets in kg, got %+v", sets)
+		}
+		if pull := list[0]; len(pull.Exercises) != 1 || len(pull.Exercises[0].Sets) != 3 {
+			t.Errorf("Expected the face pulls to be lat pulldowns, got %+v", pull.Exercises)
+		}
+	})
+
+	t.Run("leaves out workouts without mapped sets", func(t *testing.T) {
+		grace := server.register(t, "grace")
+		body := strong + "\n" + strings.Join([]string{
+			"2025-06-05 06:00:00,Cardio,30m,Running,1,0,0,5,1800,,,",
+			"2025-06-05 06:00:00,Cardio,30m,Running,Rest Timer,0,0,0,90,,,",
+		}, "\n")
+		result := imported(t, server.send(http.MethodPost, "/import?format=strong&source_unit=lb&skip_unmapped=true", grace, body))
+		if result.Sessions != 2 || result.Sets != 4 || len(result.Unmapped) != 2 || result.Unmapped[0].Sets != 2 {
+			t.Errorf("Expected the push and pull days without the runs and face pulls, got %+v", result)
+		}
+		if list := sessions(t, "grace"); len(list) != 2 {
+			t.Errorf("Expected 2 sessions, got %+v", list)
+		}
+	})
+
+	t.Run("records the rows of kept sets", func(t *testing.T) {
+		catalog, _ := store.GetExerciseList(ExerciseFilter{})
+		i := newImporter(catalog)
+		if err := i.readApp(strings.NewReader(strong), formatStrong, appImport{unit: "kg", sourceUnit: "lb", location: time.UTC}); err != nil {
+			t.Fatal(err)
+		}
+		expected := [][]int{{2, 3, 5}, {7}}
+		for n, session := range i.sessions {
+			rows := []int{}
+			for _, row := range i.sessionRows[n] {
+				rows = append(rows, row.row)
+			}
+			if !slices.Equal(rows, expected[n]) || len(session.Exercises) == 0 {
+				t.Errorf("Expected session %d to have the rows %v, got %v", n, expected[n], rows)
+			}
+		}
+	})
+
+	t.Run("imports Hevy exports", func(t *testing.T) {
+		carol := server.register(t, "carol")
+		hevy := strings.Join([]string{
+			`"title","start_time","end_time","description","exercise_title","superset_id","exercise_notes","set_index","set_type","weight_lbs","reps","distance_miles","duration_seconds","rpe"`,
+			`"Morning","2 Jun 2025, 07:00","2 Jun 2025, 08:00","","Bench Press (Barbell)",,"grip wide",0,"warmup",100,10,,,`,
+			`"Morning","2 Jun 2025, 07:00","2 Jun 2025, 08:00","","Bench Press (Barbell)",,"grip wide",1,"normal",200,5,,,9`,
+			`"Morning","2 Jun 2025, 07:00","2 Jun 2025, 08:00","","Sit Up",,"",0,"normal",,25,,,`,
+			`"Evening","2 Jun 2025, 19:00","2 Jun 2025, 19:30","","Push-Up",,"",0,"failure",,30,,,`,
+		}, "\n")
+		result := imported(t, server.send(http.MethodPost, "/import?format=hevy&unit=lb&time_zone=Europe/Berlin", carol, hevy))
+		if result.Sessions != 2 || result.Sets != 4 || len(result.Unmapped) != 0 {
+			t.Errorf("Expected 2 sessions and 4 sets, got %+v", result)
+		}
+		list := sessions(t, "carol")
+		if len(list) != 2 || !list[1].StartedAt.Equal(time.Date(2025, 6, 2, 5, 0, 0, 0, time.UTC)) || list[1].Notes != "Morning" {
+			t.Fatalf("Expected the morning in Berlin, got %+v", list)
+		}
+		exercises := list[1].Exercises
+		if len(exercises) != 2 || exercises[1].ExerciseName != "curlup" || list[0].Exercises[0].Sets[0].Notes != "failure" {
+			t.Fatalf("Expected sit ups to be curlups, got %+v", exercises)
+		}
+		if sets := exercises[0].Sets; sets[0].Weight != 100 || sets[0].Notes != "warmup, grip wide" || sets[1].Weight != 200 {
+			t.Errorf("Expected the weights in lb as they were, got %+v", sets)
+		}
+	})
+
+	t.Run("reads semicolons and reports invalid rows", func(t *testing.T) {
+		dave := server.register(t, "dave")
+		body := strings.Join([]string{
+			"Date;Workout Name;Exercise Name;Set Order;Weight;Weight Unit;Reps",
+			"2025-06-02 07:00:00;Push;Push Up;1;0;kg;20",
+			"2025-06-02;Push;Push Up;2;0;kg;20",
+			"2025-06-02 07:00:00;Push;Push Up;X;0;kg;20",
+			"2025-06-02 07:00:00;Push;Push Up;3;0;stone;20",
+		}, "\n")
+		response := server.send(http.MethodPost, "/import?format=strong&dry_run=true", dave, body)
+		AssertResponseStatus(t, http.StatusBadRequest, response.Code)
+		errors := ImportErrorWriter{}
+		json.NewDecoder(response.Body).Decode(&errors)
+		expected := map[int]string{3: "invalid date", 4: "invalid set order", 5: "invalid weight unit"}
+		if len(errors.Errors) != len(expected) {
+			t.Errorf("Expected %d invalid rows, got %+v", len(expected), errors.Errors)
+		}
+		for _, row := range errors.Errors {
+			if !strings.Contains(row.Error, expected[row.Row]) || row.Record != formatStrong {
+				t.Errorf("Expected row %d to fail with %q, got %+v", row.Row, expected[row.Row], row)
+			}
+		}
+	})
+
+	t.Run("manages exercise aliases", func(t *testing.T) {
+		erin := server.register(t, "erin")
+		response := server.send(http.MethodPut, "/exercise-aliases/Chest%20Fly", erin, `{"exercise_name": "Bench Press"}`)
+		AssertResponseStatus(t, http.StatusOK, response.Code)
+		alias := ExerciseAlias{}
+		json.NewDecoder(response.Body).Decode(&alias)
+		if alias.Alias != "chest fly" || alias.ExerciseName != "bench press" {
+			t.Errorf("Expected the alias chest fly of bench press, got %+v", alias)
+		}
+
+		response = server.send(http.MethodGet, "/exercise-aliases", erin, "")
+		aliases := []ExerciseAlias{}
+		json.NewDecoder(response.Body).Decode(&aliases)
+		if len(aliases) != 1 || aliases[0] != alias {
+			t.Errorf("Expected %+v, got %+v", alias, aliases)
+		}
+
+		AssertResponseStatus(t, http.StatusBadRequest, server.send(http.MethodPut, "/exercise-aliases/fly", erin, `{"exercise_name": "unknown"}`).Code)
+		AssertResponseStatus(t, http.StatusBadRequest, server.send(http.MethodPut, "/exercise-aliases/fly", erin, `{}`).Code)
+		AssertResponseStatus(t, http.StatusNoContent, server.send(http.MethodDelete, "/exercise-aliases/CHEST%20FLY", erin, "").Code)
+		AssertResponseStatus(t, http.StatusNotFound, server.send(http.MethodDelete, "/exercise-aliases/chest%20fly", erin, "").Code)
+	})
+
+	t.Run("rejects invalid options", func(t *testing.T) {
+		frank := server.register(t, "frank")
+		for _, query := range []string{"unit=stone", "source_unit=g", "time_zone=Mars/Olympus", "skip_unmapped=maybe"} {
+			AssertResponseStatus(t, http.StatusBadRequest, server.send(http.MethodPost, "/import?format=strong&"+query, frank, strong).Code)
+		}
+		AssertResponseStatus(t, http.StatusBadRequest, server.send(http.MethodPost, "/import?format=hevy", frank, strong).Code)
+	})
+}
@@ -0,0 +1,693 @@
+package tracker
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Oriseer/workout_tracker/api"
+	"github.com/Oriseer/workout_tracker/middleware"
+)
+
+// maxImportSize bounds the body of an import.
+const maxImportSize = 10 << 20
+
+// Formats of exports and imports.
+const (
+	formatJSON = "json"
+	formatCSV  = "csv"
+)
+
+// Kinds of CSV records, the value of the record column.
+const (
+	recordPlan    = "plan"
+	recordSession = "session"
+)
+
+// csvColumns are the columns of CSV exports. A plan record fills id,
+// exercise, reps, sets and weight. A session record is one set of a session:
+// id is the session, rows with the same id belong to the same session and
+// consecutive sets of an exercise to the same exercise entry. The session
+// columns are read from the first row of a session and a session without
+// sets is a single row without exercise.
+var csvColumns = []string{
+	"record", "id", "exercise", "reps", "sets", "weight", "rpe", "set_notes",
+	"started_at", "ended_at", "plan_id", "session_notes",
+}
+
+// WorkoutData is the JSON form of exports and imports. The plan_id of a
+// session refers to the Id of one of the plans.
+type WorkoutData struct {
+	WorkoutPlans []WorkoutPlan    `json:"workout_plans"`
+	Sessions     []WorkoutSession `json:"sessions"`
+}
+
+// ImportStore adds imported data all at once.
+type ImportStore interface {
+	// ImportWorkoutData adds the plans and sessions for the owner, all of
+	// them or, when it fails, none. The PlanId of a session refers to the Id
+	// of one of plans, api.ErrWorkoutPlanNotFound is returned when there is
+	// none, and is replaced by the id that plan is given.
+	ImportWorkoutData(owner string, plans []WorkoutPlan, sessions []WorkoutSession) error
+}
+
+// ImportResult counts what an import added or, in a dry run, would add.
+type ImportResult struct {
+	DryRun       bool `json:"dry_run"`
+	WorkoutPlans int  `json:"workout_plans"`
+	Sessions     int  `json:"sessions"`
+	Sets         int  `json:"sets"`
+	// Unmapped lists the exercises of a Strong or Hevy import that were
+	// skipped.
+	Unmapped []UnmappedExercise `json:"unmapped,omitempty"`
+}
+
+// ImportRowError is an invalid row of an import: the line of a CSV import,
+// counting the header as line 1, or the position in workout_plans or
+// sessions of a JSON import, counting from 1.
+type ImportRowError struct {
+	Row    int    `json:"row"`
+	Record string `json:"record"`
+	Error  string `json:"error"`
+}
+
+// ImportErrorWriter is an api.ErrorWriter listing the invalid rows of a
+// rejected import, or the exercises a Strong or Hevy import could not map.
+type ImportErrorWriter struct {
+	ErrorMessage string
+	Code         int
+	Errors       []ImportRowError   `json:"errors"`
+	Unmapped     []UnmappedExercise `json:"unmapped,omitempty"`
+}
+
+func (ws *WorkoutServer) exportHandler(w http.ResponseWriter, r *http.Request) {
+	owner, _ := middleware.Username(r.Context())
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = formatJSON
+	}
+	if format != formatJSON && format != formatCSV {
+		api.StatusBadRequestServerError(w, api.ErrInvalidQuery)
+		return
+	}
+
+	out := &exportWriter{ResponseWriter: w, format: format}
+	plans := func(yield func(WorkoutPlan) error) error { return ws.exportPlans(owner, yield) }
+	sessions := func(yield func(WorkoutSession) error) error { return ws.exportSessions(owner, yield) }
+	var err error
+	if format == formatCSV {
+		err = writeCSVExport(out, plans, sessions)
+	} else {
+		err = writeJSONExport(out, plans, sessions)
+	}
+	if err != nil && !out.started {
+		api.DatabaseError(w, err)
+		return
+	}
+	// The status is sent, all that is left is to tell the log
+	if err != nil {
+		middleware.Logger(r.Context()).Warn("writing export", "error", err)
+	}
+}
+
+// exportPageSize is how many plans or sessions an export reads at a time, so
+// that exports are written without holding all of them in memory.
+const exportPageSize = 100
+
+// exportWriter sends the headers of an export with its first byte, so that
+// an export failing before that is still answered with an error.
+type exportWriter struct {
+	http.ResponseWriter
+	format  string
+	started bool
+}
+
+func (e *exportWriter) Write(p []byte) (int, error) {
+	if !e.started {
+		e.started = true
+		e.Header().Set("Content-Disposition", `attachment; filename="workouts.`+e.format+`"`)
+		if e.format == formatCSV {
+			e.Header().Set("Content-Type", "text/csv")
+		} else {
+			e.Header().Set("Content-Type", "application/json")
+		}
+	}
+	return e.ResponseWriter.Write(p)
+}
+
+// exportPlans passes the owner's plans to yield in id order, reading them a
+// page at a time.
+func (ws *WorkoutServer) exportPlans(owner string, yield func(WorkoutPlan) error) error {
+	query := WorkoutPlanQuery{Sort: "id", Limit: exportPageSize}
+	for {
+		plans, err := ws.store.GetWorkoutPlanList(owner, query)
+		if err != nil {
+			return err
+		}
+		for _, plan := range plans {
+			if err := yield(plan); err != nil {
+				return err
+			}
+		}
+		if len(plans) < exportPageSize {
+			return nil
+		}
+		after := newWorkoutPlanCursor(query, plans[len(plans)-1])
+		query.After = &after
+	}
+}
+
+// exportSessions passes the owner's sessions to yield in id order, reading
+// them a page at a time.
+func (ws *WorkoutServer) exportSessions(owner string, yield func(WorkoutSession) error) error {
+	afterId := 0
+	for {
+		sessions, err := ws.store.GetWorkoutSessionPage(owner, afterId, exportPageSize)
+		if err != nil {
+			return err
+		}
+		for _, session := range sessions {
+			if err := yield(session); err != nil {
+				return err
+			}
+		}
+		if len(sessions) < exportPageSize {
+			return nil
+		}
+		afterId = sessions[len(sessions)-1].Id
+	}
+}
+
+// writeJSONExport writes a WorkoutData document one plan and session at a
+// time.
+func writeJSONExport(w io.Writer, plans func(func(WorkoutPlan) error) error, sessions func(func(WorkoutSession) error) error) error {
+	write := func(s string) error {
+		_, err := io.WriteString(w, s)
+		return err
+	}
+	// item writes a value of a list, after a comma unless it is the first
+	item := func(first *bool, value any) error {
+		if !*first {
+			if err := write(","); err != nil {
+				return err
+			}
+		}
+		*first = false
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+
+	if err := write(`{"workout_plans":[`); err != nil {
+		return err
+	}
+	first := true
+	err := plans(func(plan WorkoutPlan) error {
+		return item(&first, plan)
+	})
+	if err != nil {
+		return err
+	}
+	if err := write(`],"sessions":[`); err != nil {
+		return err
+	}
+	first = true
+	err = sessions(func(session WorkoutSession) error {
+		session.Plan = nil
+		return item(&first, session)
+	})
+	if err != nil {
+		return err
+	}
+	return write("]}\n")
+}
+
+func writeCSVExport(w io.Writer, plans func(func(WorkoutPlan) error) error, sessions func(func(WorkoutSession) error) error) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvColumns); err != nil {
+		return err
+	}
+	err := plans(func(plan WorkoutPlan) error {
+		return writer.Write([]string{
+			recordPlan, strconv.Itoa(plan.Id), plan.ExerciseName, strconv.Itoa(plan.Repititions), strconv.Itoa(plan.Sets), strconv.Itoa(plan.Weight),
+			"", "", "", "", "", "",
+		})
+	})
+	if err != nil {
+		return err
+	}
+	err = sessions(func(session WorkoutSession) error {
+		row := func(exercise string, set *LoggedSet) []string {
+			record := []string{recordSession, strconv.Itoa(session.Id), exercise, "", "", "", "", "", session.StartedAt.Format(time.RFC3339Nano), "", "", session.Notes}
+			if set != nil {
+				record[3] = strconv.Itoa(set.Reps)
+				record[5] = strconv.FormatFloat(set.Weight, 'f', -1, 64)
+				if set.RPE != nil {
+					record[6] = strconv.FormatFloat(*set.RPE, 'f', -1, 64)
+				}
+				record[7] = set.Notes
+			}
+			if session.EndedAt != nil {
+				record[9] = session.EndedAt.Format(time.RFC3339Nano)
+			}
+			if session.PlanId != nil {
+				record[10] = strconv.Itoa(*session.PlanId)
+			}
+			return record
+		}
+
+		written := false
+		for _, exercise := range session.Exercises {
+			for _, set := range exercise.Sets {
+				if err := writer.Write(row(exercise.ExerciseName, &set)); err != nil {
+					return err
+				}
+				written = true
+			}
+		}
+		if !written {
+			if err := writer.Write(row("", nil)); err != nil {
+				return err
+			}
+		}
+		// Send each session as it is written
+		writer.Flush()
+		return writer.Error()
+	})
+	if err != nil {
+		return err
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// importHandler adds the plans and sessions of a JSON or CSV body, given by
+// the format query parameter or else the Content-Type, unless dry_run is
+// true. Imports with invalid rows are rejected as a whole, listing the rows.
+// The strong and hevy formats read the CSV exports of those apps, and are
+// rejected listing the exercises they could not map unless skip_unmapped
+// is true.
+func (ws *WorkoutServer) importHandler(w http.ResponseWriter, r *http.Request) {
+	owner, _ := middleware.Username(r.Context())
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = formatJSON
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "text/csv" {
+			format = formatCSV
+		}
+	}
+	if format != formatJSON && format != formatCSV && format != formatStrong && format != formatHevy {
+		api.StatusBadRequestServerError(w, api.ErrInvalidQuery)
+		return
+	}
+	dryRun := false
+	if value := query.Get("dry_run"); value != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			api.StatusBadRequestServerError(w, api.ErrInvalidQuery)
+			return
+		}
+	}
+	var options appImport
+	if format == formatStrong || format == formatHevy {
+		var err error
+		if options, err = appImportOptions(query); err != nil {
+			api.StatusBadRequestServerError(w, err)
+			return
+		}
+	}
+
+	catalog, err := ws.store.GetExerciseList(ExerciseFilter{})
+	if err != nil {
+		api.DatabaseError(w, err)
+		return
+	}
+	importer := newImporter(catalog)
+	body := http.MaxBytesReader(w, r.Body, maxImportSize)
+	switch format {
+	case formatCSV:
+		err = importer.readCSV(body)
+	case formatJSON:
+		err = importer.readJSON(body)
+	default:
+		var aliases []ExerciseAlias
+		if aliases, err = ws.store.GetExerciseAliases(owner); err != nil {
+			api.DatabaseError(w, err)
+			return
+		}
+		importer.useAliases(aliases)
+		err = importer.readApp(body, format, options)
+	}
+	if err != nil {
+		api.RequestBodyError(w, err)
+		return
+	}
+	importer.check()
+	if len(importer.errors) > 0 {
+		writeImportErrors(w, importer.errors)
+		return
+	}
+	if len(importer.unmapped) > 0 && !options.skipUnmapped {
+		writeUnmappedExercises(w, importer.unmapped)
+		return
+	}
+
+	result := importer.result()
+	result.DryRun = dryRun
+	status := http.StatusOK
+	if !dryRun {
+		err := ws.store.ImportWorkoutData(owner, importer.plans, importer.sessions)
+		switch err {
+		case nil:
+		case api.ErrExerciseNotFound, api.ErrWorkoutPlanNotFound:
+			// An exercise was deleted since the import was checked
+			api.StatusBadRequestServerError(w, err)
+			return
+		default:
+			api.DatabaseError(w, err)
+			return
+		}
+		ws.metrics.plansCreated.Add(float64(result.WorkoutPlans))
+		ws.metrics.sessionsLogged.Add(float64(result.Sessions))
+		ws.metrics.setsLogged.Add(float64(result.Sets))
+		status = http.StatusCreated
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(result)
+}
+
+func writeImportErrors(w http.ResponseWriter, rows []ImportRowError) {
+	if recorder, ok := w.(api.ErrorRecorder); ok {
+		recorder.RecordError(fmt.Errorf("%w: %d invalid rows", api.ErrInvalidImport, len(rows)))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(ImportErrorWriter{
+		ErrorMessage: "Bad Request: " + api.ErrInvalidImport.Error(),
+		Code:         http.StatusBadRequest,
+		Errors:       rows,
+	})
+}
+
+// importRow is where a plan or session of an import came from, for errors.
+type importRow struct {
+	row    int
+	record string
+}
+
+// importer reads an import into plans and sessions, resolving their
+// exercises in the catalog, and collects the errors of invalid rows.
+type importer struct {
+	exercisesById   map[int]Exercise
+	exercisesByName map[string]Exercise
+	exercisesByKey  map[string]Exercise // by exerciseKey, for the names of other apps
+	aliases         map[string]int      // the exercise ids of the aliases of the owner
+
+	plans       []WorkoutPlan
+	planRows    []importRow
+	sessions    []WorkoutSession
+	sessionRows [][]importRow // the rows of every set, or of the session without sets
+	errors      []ImportRowError
+	unmapped    []UnmappedExercise
+}
+
+func newImporter(catalog []Exercise) *importer {
+	i := &importer{
+		exercisesById:   map[int]Exercise{},
+		exercisesByName: map[string]Exercise{},
+		exercisesByKey:  map[string]Exercise{},
+		aliases:         map[string]int{},
+	}
+	for _, exercise := range catalog {
+		i.exercisesById[exercise.Id] = exercise
+		i.exercisesByName[strings.ToLower(exercise.Name)] = exercise
+		// The catalog is sorted by name, so the first of names with the
+		// same key wins
+		if _, ok := i.exercisesByKey[exerciseKey(exercise.Name)]; !ok {
+			i.exercisesByKey[exerciseKey(exercise.Name)] = exercise
+		}
+	}
+	return i
+}
+
+func (i *importer) fail(row importRow, err error) {
+	i.errors = append(i.errors, ImportRowError{Row: row.row, Record: row.record, Error: err.Error()})
+}
+
+func (i *importer) readJSON(body io.Reader) error {
+	data := WorkoutData{}
+	if err := json.NewDecoder(body).Decode(&data); err != nil {
+		return err
+	}
+	i.plans = data.WorkoutPlans
+	for n := range data.WorkoutPlans {
+		i.planRows = append(i.planRows, importRow{n + 1, recordPlan})
+	}
+	for n, session := range data.Sessions {
+		i.sessions = append(i.sessions, session)
+		i.sessionRows = append(i.sessionRows, []importRow{{n + 1, recordSession}})
+	}
+	return nil
+}
+
+// readCSV reads the records of csvColumns. The columns may come in any
+// order and those a record does not use may be left out.
+func (i *importer) readCSV(body io.Reader) error {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err == io.EOF {
+		return errors.New("missing CSV header")
+	} else if err != nil {
+		return err
+	}
+	columns := map[string]int{}
+	for n, name := range header {
+		name = strings.TrimSpace(strings.ToLower(name))
+		if !slices.Contains(csvColumns, name) {
+			return fmt.Errorf("unknown CSV column %q", name)
+		}
+		columns[name] = n
+	}
+	if _, ok := columns["record"]; !ok {
+		return errors.New("missing CSV column record")
+	}
+
+	sessionsById := map[string]int{}
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		// Quoted fields may span lines, so the row is the line it starts on
+		line, _ := reader.FieldPos(0)
+		field := func(name string) string {
+			if n, ok := columns[name]; ok && n < len(fields) {
+				return strings.TrimSpace(fields[n])
+			}
+			return ""
+		}
+
+		row := importRow{line, field("record")}
+		values := csvValues{field: field}
+		switch row.record {
+		case recordPlan:
+			plan := WorkoutPlan{
+				Id:           values.int("id"),
+				ExerciseName: field("exercise"),
+				Repititions:  values.int("reps"),
+				Sets:         values.int("sets"),
+				Weight:       values.int("weight"),
+			}
+			i.plans = append(i.plans, plan)
+			i.planRows = append(i.planRows, row)
+		case recordSession:
+			session := WorkoutSession{
+				StartedAt: values.time("started_at"),
+				Notes:     field("session_notes"),
+				PlanId:    values.optionalInt("plan_id"),
+				Exercises: []SessionExercise{},
+			}
+			if endedAt := values.time("ended_at"); !endedAt.IsZero() {
+				session.EndedAt = &endedAt
+			}
+			if exercise := field("exercise"); exercise != "" {
+				set := LoggedSet{Reps: values.int("reps"), Weight: values.float("weight"), RPE: values.optionalFloat("rpe"), Notes: field("set_notes")}
+				session.Exercises = append(session.Exercises, SessionExercise{ExerciseName: exercise, Sets: []LoggedSet{set}})
+			}
+			if values.err != nil {
+				break
+			}
+
+			id := field("id")
+			if n, ok := sessionsById[id]; ok && id != "" {
+				i.addSessionRow(n, session)
+				i.sessionRows[n] = append(i.sessionRows[n], row)
+			} else {
+				sessionsById[id] = len(i.sessions)
+				i.sessions = append(i.sessions, session)
+				i.sessionRows = append(i.sessionRows, []importRow{row})
+			}
+		default:
+			values.err = fmt.Errorf("record must be %s or %s", recordPlan, recordSession)
+		}
+		if values.err != nil {
+			i.fail(row, values.err)
+		}
+	}
+}
+
+// addSessionRow adds the set of a further CSV row of the session n.
+func (i *importer) addSessionRow(n int, row WorkoutSession) {
+	if len(row.Exercises) == 0 {
+		return
+	}
+	session := &i.sessions[n]
+	exercise := row.Exercises[0]
+	if last := len(session.Exercises) - 1; last >= 0 && strings.EqualFold(session.Exercises[last].ExerciseName, exercise.ExerciseName) {
+		session.Exercises[last].Sets = append(session.Exercises[last].Sets, exercise.Sets...)
+		return
+	}
+	session.Exercises = append(session.Exercises, exercise)
+}
+
+// csvValues parses the fields of a CSV row, keeping the first error.
+type csvValues struct {
+	field func(name string) string
+	err   error
+}
+
+func (v *csvValues) parse(name string, parse func(value string) error) {
+	value := v.field(name)
+	if value == "" || v.err != nil {
+		return
+	}
+	if err := parse(value); err != nil {
+		v.err = fmt.Errorf("invalid %s %q", name, value)
+	}
+}
+
+func (v *csvValues) int(name string) (n int) {
+	v.parse(name, func(value string) (err error) {
+		n, err = strconv.Atoi(value)
+		return err
+	})
+	return n
+}
+
+func (v *csvValues) optionalInt(name string) *int {
+	if v.field(name) == "" {
+		return nil
+	}
+	n := v.int(name)
+	return &n
+}
+
+func (v *csvValues) float(name string) (f float64) {
+	v.parse(name, func(value string) (err error) {
+		f, err = strconv.ParseFloat(value, 64)
+		return err
+	})
+	return f
+}
+
+func (v *csvValues) optionalFloat(name string) *float64 {
+	if v.field(name) == "" {
+		return nil
+	}
+	f := v.float(name)
+	return &f
+}
+
+func (v *csvValues) time(name string) (t time.Time) {
+	v.parse(name, func(value string) (err error) {
+		t, err = parseTimeParam(value)
+		return err
+	})
+	return t
+}
+
+// check validates the plans and sessions like the handlers adding them one
+// at a time do and resolves their exercises.
+func (i *importer) check() {
+	planIds := map[int]bool{}
+	for n := range i.plans {
+		plan := &i.plans[n]
+		row := i.planRows[n]
+		if plan.Id != 0 && planIds[plan.Id] {
+			i.fail(row, fmt.Errorf("duplicate plan id %d", plan.Id))
+		}
+		planIds[plan.Id] = true
+		if plan.Repititions < 0 || plan.Sets < 0 || plan.Weight < 0 {
+			i.fail(row, errors.New("reps, sets and weight must not be negative"))
+		}
+		if exercise, err := i.findExercise(plan.ExerciseId, plan.ExerciseName); err != nil {
+			i.fail(row, err)
+		} else {
+			plan.ExerciseId, plan.ExerciseName = exercise.Id, exercise.Name
+		}
+	}
+
+	for n := range i.sessions {
+		session := &i.sessions[n]
+		rows := i.sessionRows[n]
+		if err := validateWorkoutSession(*session); err != nil {
+			i.fail(rows[0], err)
+		}
+		if session.PlanId != nil && (*session.PlanId == 0 || !planIds[*session.PlanId]) {
+			i.fail(rows[0], fmt.Errorf("%w: plan_id %d is not a plan of the import", api.ErrWorkoutPlanNotFound, *session.PlanId))
+		}
+		set := 0
+		for e := range session.Exercises {
+			exercise := &session.Exercises[e]
+			// CSV rows hold one set, JSON sessions are one row
+			row := rows[min(set, len(rows)-1)]
+			set += len(exercise.Sets)
+			if found, err := i.findExercise(exercise.ExerciseId, exercise.ExerciseName); err != nil {
+				i.fail(row, err)
+			} else {
+				exercise.ExerciseId, exercise.ExerciseName = found.Id, found.Name
+			}
+		}
+	}
+}
+
+// findExercise looks the exercise up by id or, when there is no id, by name.
+// The error names the exercise.
+func (i *importer) findExercise(id int, name string) (Exercise, error) {
+	if id != 0 {
+		if exercise, ok := i.exercisesById[id]; ok {
+			return exercise, nil
+		}
+		return Exercise{}, fmt.Errorf("%w: %d", api.ErrExerciseNotFound, id)
+	}
+	if exercise, ok := i.exercisesByName[strings.ToLower(name)]; ok {
+		return exercise, nil
+	}
+	return Exercise{}, fmt.Errorf("%w: %q", api.ErrExerciseNotFound, name)
+}
+
+func (i *importer) result() ImportResult {
+	result := ImportResult{WorkoutPlans: len(i.plans), Sessions: len(i.sessions), Unmapped: i.unmapped}
+	for _, session := range i.sessions {
+		for _, exercise := range session.Exercises {
+			result.Sets += len(exercise.Sets)
+		}
+	}
+	return result
+}
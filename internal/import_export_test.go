@@ -0,0 +1,211 @@
+package tracker
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Oriseer/workout_tracker/api"
+)
+
+func TestImportExport(t *testing.T) {
+	store := NewInMemoryStore()
+	store.AddExercise(Exercise{Name: "bench press", Category: "strength"})
+	store.AddExercise(Exercise{Name: "pushup", Category: "strength"})
+	server := newTestServer(store)
+
+	export := func(t *testing.T, token string) WorkoutData {
+		t.Helper()
+		response := server.send(http.MethodGet, "/export", token, "")
+		AssertResponseStatus(t, http.StatusOK, response.Code)
+		data := WorkoutData{}
+		if err := json.NewDecoder(response.Body).Decode(&data); err != nil {
+			t.Fatalf("Expected a JSON export, got %v", err)
+		}
+		return data
+	}
+	importErrors := func(t *testing.T, response *httptest.ResponseRecorder) []ImportRowError {
+		t.Helper()
+		AssertResponseStatus(t, http.StatusBadRequest, response.Code)
+		body := ImportErrorWriter{}
+		json.NewDecoder(response.Body).Decode(&body)
+		if body.ErrorMessage != "Bad Request: "+api.ErrInvalidImport.Error() {
+			t.Errorf("Expected an api.ErrInvalidImport message, got %q", body.ErrorMessage)
+		}
+		return body.Errors
+	}
+
+	alice := server.register(t, "alice")
+	AssertResponseStatus(t, http.StatusCreated, server.send(http.MethodPost, "/workout-plans/", alice, `{"ExerciseName": "bench press", "Repetitions": 5, "Sets": 5, "Weight": 80}`).Code)
+	plan := export(t, alice).WorkoutPlans[0]
+	session := `{"plan_id": ` + strconv.Itoa(plan.Id) + `, "started_at": "2025-06-02T07:00:00Z", "ended_at": "2025-06-02T08:00:00Z", "notes": "heavy, but fine",
+		"exercises": [{"exercise_name": "bench press", "sets": [{"reps": 5, "weight": 80}, {"reps": 5, "weight": 82.5, "rpe": 9, "notes": "last"}]},
+			{"exercise_name": "pushup", "sets": [{"reps": 20}]}]}`
+	AssertResponseStatus(t, http.StatusCreated, server.send(http.MethodPost, "/sessions", alice, session).Code)
+	AssertResponseStatus(t, http.StatusCreated, server.send(http.MethodPost, "/sessions", alice, `{"started_at": "2025-06-03T07:00:00Z", "notes": "rest day"}`).Code)
+
+	t.Run("exports JSON", func(t *testing.T) {
+		response := server.send(http.MethodGet, "/export?format=json", alice, "")
+		if disposition := response.Header().Get("Content-Disposition"); !strings.Contains(disposition, "workouts.json") {
+			t.Errorf("Expected an attachment, got %q", disposition)
+		}
+		// Sessions are exported in the order they were added
+		data := export(t, alice)
+		if len(data.WorkoutPlans) != 1 || len(data.Sessions) != 2 || *data.Sessions[0].PlanId != plan.Id || len(data.Sessions[0].Exercises) != 2 {
+			t.Errorf("Expected the plan and both sessions, got %+v", data)
+		}
+	})
+
+	csvExport := server.send(http.MethodGet, "/export?format=csv", alice, "")
+	t.Run("exports CSV", func(t *testing.T) {
+		AssertResponseStatus(t, http.StatusOK, csvExport.Code)
+		records, err := csv.NewReader(strings.NewReader(csvExport.Body.String())).ReadAll()
+		if err != nil {
+			t.Fatalf("Expected CSV, got %v", err)
+		}
+		// The header, the plan, a row per set and one for the session without sets
+		if len(records) != 6 || strings.Join(records[0], ",") != strings.Join(csvColumns, ",") || records[1][0] != recordPlan {
+			t.Errorf("Expected 6 records, got %q", records)
+		}
+	})
+
+	bob := server.register(t, "bob")
+	t.Run("dry runs report without importing", func(t *testing.T) {
+		response := server.sendContent(http.MethodPost, "/import?dry_run=true", bob, "text/csv", csvExport.Body.String())
+		AssertResponseStatus(t, http.StatusOK, response.Code)
+		result := ImportResult{}
+		json.NewDecoder(response.Body).Decode(&result)
+		if !reflect.DeepEqual(result, ImportResult{DryRun: true, WorkoutPlans: 1, Sessions: 2, Sets: 3}) {
+			t.Errorf("Expected 1 plan, 2 sessions and 3 sets, got %+v", result)
+		}
+		if data := export(t, bob); len(data.WorkoutPlans) != 0 || len(data.Sessions) != 0 {
+			t.Errorf("Expected nothing to be imported, got %+v", data)
+		}
+	})
+
+	t.Run("imports an export", func(t *testing.T) {
+		AssertResponseStatus(t, http.StatusCreated, server.sendContent(http.MethodPost, "/import", bob, "text/csv", csvExport.Body.String()).Code)
+		want, got := export(t, alice), export(t, bob)
+		if len(got.WorkoutPlans) != 1 || len(got.Sessions) != 2 || got.Sessions[0].PlanId == nil || *got.Sessions[0].PlanId != got.WorkoutPlans[0].Id {
+			t.Fatalf("Expected the plan and sessions of alice, got %+v", got)
+		}
+		imported, original := got.Sessions[0], want.Sessions[0]
+		if !imported.StartedAt.Equal(original.StartedAt) || !imported.EndedAt.Equal(*original.EndedAt) || imported.Notes != original.Notes {
+			t.Errorf("Expected %+v, got %+v", original, imported)
+		}
+		sets := imported.Exercises[0].Sets
+		if len(sets) != 2 || sets[1].Weight != 82.5 || sets[1].RPE == nil || *sets[1].RPE != 9 || sets[1].Notes != "last" || imported.Exercises[1].ExerciseName != "pushup" {
+			t.Errorf("Expected the sets of alice, got %+v", imported.Exercises)
+		}
+
+		carol := server.register(t, "carol")
+		body, _ := json.Marshal(want)
+		AssertResponseStatus(t, http.StatusCreated, server.send(http.MethodPost, "/import", carol, string(body)).Code)
+		if data := export(t, carol); len(data.WorkoutPlans) != 1 || len(data.Sessions) != 2 {
+			t.Errorf("Expected the JSON export to be imported, got %+v", data)
+		}
+	})
+
+	t.Run("reports invalid rows and imports none", func(t *testing.T) {
+		dave := server.register(t, "dave")
+		body := strings.Join([]string{
+			"record,id,exercise,reps,sets,weight,started_at,plan_id",
+			"plan,1,bench press,5,5,80,,",
+			"plan,2,squat,5,5,100,,",
+			"session,1,bench press,five,,80,2025-06-02T07:00:00Z,",
+			"session,2,pushup,10,,0,,",
+			"session,3,pushup,10,,0,2025-06-02,7",
+			"workout,1,,,,,,",
+		}, "\n")
+		rows := importErrors(t, server.send(http.MethodPost, "/import?format=csv&dry_run=true", dave, body))
+		expected := map[int]string{3: "exercise not found", 4: "invalid reps", 5: "invalid workout session", 6: "plan_id 7", 7: "record must be"}
+		if len(rows) != len(expected) {
+			t.Errorf("Expected %d invalid rows, got %+v", len(expected), rows)
+		}
+		for _, row := range rows {
+			if !strings.Contains(row.Error, expected[row.Row]) {
+				t.Errorf("Expected row %d to fail with %q, got %+v", row.Row, expected[row.Row], row)
+			}
+		}
+
+		rows = importErrors(t, server.sendContent(http.MethodPost, "/import", dave, "application/json", `{"workout_plans": [{"ExerciseName": "bench press", "Sets": -1}], "sessions": [{"started_at": "2025-06-02T07:00:00Z"}, {"started_at": "2025-06-02T07:00:00Z", "exercises": [{"exercise_id": 99}]}]}`))
+		if len(rows) != 2 || rows[0] != (ImportRowError{1, recordPlan, "reps, sets and weight must not be negative"}) || rows[1].Row != 2 || rows[1].Record != recordSession {
+			t.Errorf("Expected plan 1 and session 2 to be invalid, got %+v", rows)
+		}
+		if data := export(t, dave); len(data.WorkoutPlans) != 0 || len(data.Sessions) != 0 {
+			t.Errorf("Expected nothing to be imported, got %+v", data)
+		}
+	})
+
+	t.Run("exports more than a page", func(t *testing.T) {
+		erin := server.register(t, "erin")
+		startedAt := time.Date(2025, 6, 2, 7, 0, 0, 0, time.UTC)
+		for i := range exportPageSize + 1 {
+			store.AddWorkoutPlan("erin", WorkoutPlan{ExerciseName: "pushup", Repititions: 10, Sets: i + 1})
+			store.AddWorkoutSession("erin", WorkoutSession{StartedAt: startedAt.Add(-time.Duration(i) * time.Hour)})
+		}
+		data := export(t, erin)
+		if len(data.WorkoutPlans) != exportPageSize+1 || len(data.Sessions) != exportPageSize+1 {
+			t.Fatalf("Expected %d plans and sessions, got %d and %d", exportPageSize+1, len(data.WorkoutPlans), len(data.Sessions))
+		}
+		for i := 1; i <= exportPageSize; i++ {
+			if data.WorkoutPlans[i].Id <= data.WorkoutPlans[i-1].Id || data.Sessions[i].Id <= data.Sessions[i-1].Id {
+				t.Fatalf("Expected plans and sessions in id order, got %+v", data)
+			}
+		}
+	})
+
+	t.Run("rejects invalid requests", func(t *testing.T) {
+		AssertResponseStatus(t, http.StatusBadRequest, server.send(http.MethodGet, "/export?format=xml", alice, "").Code)
+		AssertResponseStatus(t, http.StatusBadRequest, server.send(http.MethodPost, "/import?dry_run=maybe", alice, "{}").Code)
+		AssertResponseStatus(t, http.StatusBadRequest, server.send(http.MethodPost, "/import", alice, "not json").Code)
+		AssertResponseStatus(t, http.StatusBadRequest, server.send(http.MethodPost, "/import?format=csv", alice, "record,unknown\n").Code)
+	})
+}
+
+// failingWriter fails only the write that reaches byte n, so that an
+// unchecked write goes unnoticed.
+type failingWriter struct {
+	n, written int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	reaches := w.written <= w.n && w.written+len(p) > w.n
+	w.written += len(p)
+	if reaches {
+		return 0, io.ErrShortWrite
+	}
+	return len(p), nil
+}
+
+func TestWriteJSONExportErrors(t *testing.T) {
+	plans := func(yield func(WorkoutPlan) error) error {
+		for id := 1; id <= 2; id++ {
+			if err := yield(WorkoutPlan{Id: id}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	sessions := func(yield func(WorkoutSession) error) error {
+		return yield(WorkoutSession{Id: 1})
+	}
+
+	full := &strings.Builder{}
+	if err := writeJSONExport(full, plans, sessions); err != nil {
+		t.Fatal(err)
+	}
+	// Every write fails at some length, including the separators
+	for n := range full.Len() {
+		if err := writeJSONExport(&failingWriter{n: n}, plans, sessions); err == nil {
+			t.Errorf("Expected the failing write at byte %d to be returned", n)
+		}
+	}
+}
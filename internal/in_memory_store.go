@@ -1,41 +1,1051 @@
 package tracker
 
-import "slices"
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/Oriseer/workout_tracker/api"
+	"github.com/Oriseer/workout_tracker/middleware"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// InMemoryStore is a WorkoutPlanStore that keeps everything in memory, for
+// demos, frontend development and tests that do not need a database. It
+// follows the semantics of DB, including cascades when plans are deleted.
+// All methods are safe for concurrent use, and values handed in or out are
+// copies, so callers cannot change the stored data behind the lock.
 type InMemoryStore struct {
-	store []WorkoutPlan
+	mu sync.RWMutex
+
+	users              map[string]*memoryUser
+	plans              []memoryPlan
+	exercises          map[int]Exercise
+	sessions           map[string][]WorkoutSession
+	schedules          []memorySchedule
+	refreshTokens      map[string]RefreshToken
+	revokedTokens      map[string]time.Time
+	resetTokens        map[string]PasswordResetToken
+	verificationTokens map[string]EmailVerificationToken
+	loginFailures      map[string]LoginFailures
+	aliases            map[string]map[string]int // owner to alias to exercise id
+
+	// nextId is the last id handed out, shared by all kinds of records.
+	nextId int
+}
+
+var _ WorkoutPlanStore = (*InMemoryStore)(nil)
+
+type memoryUser struct {
+	UserAccount
+	passwordHash        []byte
+	tokensRevokedBefore *time.Time
+}
+
+type memoryPlan struct {
+	owner string
+	WorkoutPlan
+}
+
+type memorySchedule struct {
+	owner string
+	WorkoutSchedule
 }
 
 func NewInMemoryStore() *InMemoryStore {
-	return &InMemoryStore{[]WorkoutPlan{}}
+	return &InMemoryStore{
+		users:              map[string]*memoryUser{},
+		exercises:          map[int]Exercise{},
+		sessions:           map[string][]WorkoutSession{},
+		refreshTokens:      map[string]RefreshToken{},
+		revokedTokens:      map[string]time.Time{},
+		resetTokens:        map[string]PasswordResetToken{},
+		verificationTokens: map[string]EmailVerificationToken{},
+		loginFailures:      map[string]LoginFailures{},
+		aliases:            map[string]map[string]int{},
+	}
+}
+
+func (s *InMemoryStore) newId() int {
+	s.nextId++
+	return s.nextId
+}
+
+func (s *InMemoryStore) AddWorkoutPlan(owner string, input WorkoutPlan) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	exercise, err := s.findExercise(input.ExerciseId, input.ExerciseName)
+	if err != nil {
+		return err
+	}
+	input.Id = s.newId()
+	input.ExerciseId = exercise.Id
+	input.ExerciseName = exercise.Name
+	s.plans = append(s.plans, memoryPlan{owner: owner, WorkoutPlan: input})
+	return nil
+}
+
+// DeleteWorkoutPlan deletes the owner's plans for the exercise. Sessions
+// following them are unlinked and their schedules deleted.
+func (s *InMemoryStore) DeleteWorkoutPlan(owner, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := map[int]bool{}
+	s.plans = slices.DeleteFunc(s.plans, func(plan memoryPlan) bool {
+		if plan.owner == owner && strings.EqualFold(plan.ExerciseName, name) {
+			deleted[plan.Id] = true
+		}
+		return deleted[plan.Id]
+	})
+	if len(deleted) == 0 {
+		return api.ErrWorkoutPlanNotFound
+	}
+
+	for i := range s.sessions[owner] {
+		if planId := s.sessions[owner][i].PlanId; planId != nil && deleted[*planId] {
+			s.sessions[owner][i].PlanId = nil
+		}
+	}
+	s.schedules = slices.DeleteFunc(s.schedules, func(schedule memorySchedule) bool {
+		return deleted[schedule.PlanId]
+	})
+	return nil
+}
+
+// UpdateWorkoutPlan updates the owner's plan for the exercise given by
+// ExerciseId, or by ExerciseName when no id is set.
+func (s *InMemoryStore) UpdateWorkoutPlan(owner string, input WorkoutPlan) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	updated := false
+	for i, plan := range s.plans {
+		if plan.owner != owner {
+			continue
+		}
+		if plan.ExerciseId == input.ExerciseId || (input.ExerciseId == 0 && strings.EqualFold(plan.ExerciseName, input.ExerciseName)) {
+			s.plans[i].Repititions = input.Repititions
+			s.plans[i].Sets = input.Sets
+			s.plans[i].Weight = input.Weight
+			updated = true
+		}
+	}
+	if !updated {
+		return api.ErrWorkoutPlanNotFound
+	}
+	return nil
+}
+
+func (s *InMemoryStore) GetWorkoutPlanList(owner string, query WorkoutPlanQuery) ([]WorkoutPlan, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	plans := []WorkoutPlan{}
+	for _, plan := range s.plans {
+		if plan.owner == owner && matchesWorkoutPlanQuery(plan.WorkoutPlan, query) {
+			plans = append(plans, plan.WorkoutPlan)
+		}
+	}
+	slices.SortFunc(plans, func(a, b WorkoutPlan) int {
+		return compareWorkoutPlans(query, newWorkoutPlanCursor(query, a), newWorkoutPlanCursor(query, b))
+	})
+	if query.Limit > 0 && len(plans) > query.Limit {
+		plans = plans[:query.Limit]
+	}
+	return plans, nil
+}
+
+// matchesWorkoutPlanQuery applies the filters and the cursor of query.
+func matchesWorkoutPlanQuery(plan WorkoutPlan, query WorkoutPlanQuery) bool {
+	if query.ExerciseName != "" && !strings.Contains(strings.ToLower(plan.ExerciseName), strings.ToLower(query.ExerciseName)) {
+		return false
+	}
+	for _, bound := range []struct {
+		value    int
+		min, max *int
+	}{
+		{plan.Weight, query.MinWeight, query.MaxWeight},
+		{plan.Repititions, query.MinReps, query.MaxReps},
+		{plan.Sets, query.MinSets, query.MaxSets},
+	} {
+		if (bound.min != nil && bound.value < *bound.min) || (bound.max != nil && bound.value > *bound.max) {
+			return false
+		}
+	}
+	return query.After == nil || compareWorkoutPlans(query, newWorkoutPlanCursor(query, plan), *query.After) > 0
+}
+
+// compareWorkoutPlans orders two plan positions by the sort field of query
+// and then by id, reversed for descending queries.
+func compareWorkoutPlans(query WorkoutPlanQuery, a, b WorkoutPlanCursor) int {
+	c := cmp.Or(cmp.Compare(a.Text, b.Text), cmp.Compare(a.Number, b.Number), cmp.Compare(a.Id, b.Id))
+	if query.Desc {
+		return -c
+	}
+	return c
+}
+
+func (s *InMemoryStore) AddUser(userDetails UserDetails) error {
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(userDetails.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.users[userDetails.Username]; exists {
+		return api.ErrUserName
+	}
+	s.users[userDetails.Username] = &memoryUser{
+		UserAccount: UserAccount{
+			Username: userDetails.Username,
+			Email:    userDetails.Email,
+			Role:     middleware.RoleUser,
+		},
+		passwordHash: passwordHash,
+	}
+	return nil
+}
+
+func (s *InMemoryStore) UserLogin(loginData LoginData) (LoginData, error) {
+	s.mu.RLock()
+	user, ok := s.users[loginData.Username]
+	var passwordHash []byte
+	var login LoginData
+	if ok {
+		passwordHash = user.passwordHash
+		login = LoginData{
+			Username:      user.Username,
+			Password:      string(user.passwordHash),
+			EmailVerified: user.EmailVerified,
+			Role:          user.Role,
+		}
+	}
+	s.mu.RUnlock()
+
+	// bcrypt is slow, so the password is compared outside the lock
+	if !ok || bcrypt.CompareHashAndPassword(passwordHash, []byte(loginData.Password)) != nil {
+		return LoginData{}, api.ErrInvalidLoginDetails
+	}
+	return login, nil
+}
+
+func (s *InMemoryStore) GetUserRole(username string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[username]
+	if !ok {
+		return "", api.ErrUserNotFound
+	}
+	return user.Role, nil
+}
+
+func (s *InMemoryStore) GetUserList() ([]UserAccount, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make([]UserAccount, 0, len(s.users))
+	for _, user := range s.users {
+		users = append(users, user.UserAccount)
+	}
+	slices.SortFunc(users, func(a, b UserAccount) int {
+		return cmp.Compare(a.Username, b.Username)
+	})
+	return users, nil
+}
+
+func (s *InMemoryStore) SetUserRole(username, role string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[username]
+	if !ok {
+		return api.ErrUserNotFound
+	}
+	user.Role = role
+	return nil
+}
+
+func (s *InMemoryStore) GetExerciseList(filter ExerciseFilter) ([]Exercise, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	exercises := []Exercise{}
+	for _, exercise := range s.exercises {
+		if filter.Category != "" && exercise.Category != filter.Category {
+			continue
+		}
+		if filter.Name != "" && !strings.Contains(strings.ToLower(exercise.Name), strings.ToLower(filter.Name)) {
+			continue
+		}
+		exercises = append(exercises, exercise)
+	}
+	slices.SortFunc(exercises, func(a, b Exercise) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+	return exercises, nil
+}
+
+func (s *InMemoryStore) GetExercise(id int) (Exercise, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	exercise, ok := s.exercises[id]
+	if !ok {
+		return Exercise{}, api.ErrExerciseNotFound
+	}
+	return exercise, nil
+}
+
+func (s *InMemoryStore) AddExercise(exercise Exercise) (Exercise, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.exerciseNameTaken(0, exercise.Name) {
+		return Exercise{}, api.ErrExerciseExists
+	}
+	exercise.Id = s.newId()
+	s.exercises[exercise.Id] = exercise
+	return exercise, nil
+}
+
+func (s *InMemoryStore) UpdateExercise(exercise Exercise) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.exercises[exercise.Id]; !ok {
+		return api.ErrExerciseNotFound
+	}
+	if s.exerciseNameTaken(exercise.Id, exercise.Name) {
+		return api.ErrExerciseExists
+	}
+	s.exercises[exercise.Id] = exercise
+	// Workout plans keep a copy of the name they are addressed by
+	for i := range s.plans {
+		if s.plans[i].ExerciseId == exercise.Id {
+			s.plans[i].ExerciseName = exercise.Name
+		}
+	}
+	return nil
+}
+
+func (s *InMemoryStore) DeleteExercise(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.exercises[id]; !ok {
+		return api.ErrExerciseNotFound
+	}
+	for _, plan := range s.plans {
+		if plan.ExerciseId == id {
+			return api.ErrExerciseInUse
+		}
+	}
+	for _, sessions := range s.sessions {
+		for _, session := range sessions {
+			for _, exercise := range session.Exercises {
+				if exercise.ExerciseId == id {
+					return api.ErrExerciseInUse
+				}
+			}
+		}
+	}
+	delete(s.exercises, id)
+	for _, aliases := range s.aliases {
+		for alias, exerciseId := range aliases {
+			if exerciseId == id {
+				delete(aliases, alias)
+			}
+		}
+	}
+	return nil
+}
+
+// findExercise resolves an exercise by id when one is given and by name,
+// ignoring case, otherwise. The caller must hold the lock.
+func (s *InMemoryStore) findExercise(id int, name string) (Exercise, error) {
+	if id != 0 {
+		exercise, ok := s.exercises[id]
+		if !ok {
+			return Exercise{}, api.ErrExerciseNotFound
+		}
+		return exercise, nil
+	}
+	for _, exercise := range s.exercises {
+		if strings.EqualFold(exercise.Name, name) {
+			return exercise, nil
+		}
+	}
+	return Exercise{}, api.ErrExerciseNotFound
+}
+
+func (s *InMemoryStore) exerciseNameTaken(id int, name string) bool {
+	for _, exercise := range s.exercises {
+		if exercise.Id != id && strings.EqualFold(exercise.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *InMemoryStore) AddWorkoutSession(owner string, session WorkoutSession) (WorkoutSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkSessionPlan(owner, session.PlanId); err != nil {
+		return WorkoutSession{}, err
+	}
+	exercises, err := s.newSessionExercises(session.Exercises)
+	if err != nil {
+		return WorkoutSession{}, err
+	}
+	session.Id = s.newId()
+	session.Plan = nil
+	session.PlanId = copyPointer(session.PlanId)
+	session.EndedAt = copyPointer(session.EndedAt)
+	session.Exercises = exercises
+	s.sessions[owner] = append(s.sessions[owner], session)
+	return s.getWorkoutSession(owner, session.Id)
 }
 
-func (s *InMemoryStore) AddWorkoutPlan(input WorkoutPlan) {
-	s.store = append(s.store, input)
+func (s *InMemoryStore) GetWorkoutSessionList(owner string) ([]WorkoutSession, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.sortedSessions(owner), nil
 }
 
-func (s *InMemoryStore) DeleteWorkoutPlan(name string) {
-	newSlice := make([]WorkoutPlan, len(s.store))
-	for i, value := range s.store {
-		if name == value.ExerciseName {
-			newSlice = slices.Delete(s.store, i, 1)
+func (s *InMemoryStore) GetWorkoutSessionPage(owner string, afterId, limit int) ([]WorkoutSession, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sessions := []WorkoutSession{}
+	for _, session := range s.sortedSessions(owner) {
+		if session.Id > afterId {
+			sessions = append(sessions, session)
 		}
 	}
+	slices.SortFunc(sessions, func(a, b WorkoutSession) int {
+		return cmp.Compare(a.Id, b.Id)
+	})
+	return sessions[:min(limit, len(sessions))], nil
+}
+
+func (s *InMemoryStore) GetWorkoutSession(owner string, id int) (WorkoutSession, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-	s.store = newSlice
+	return s.getWorkoutSession(owner, id)
 }
 
-func (s *InMemoryStore) UpdateWorkoutPlan(name string) {
-	newSlice := make([]WorkoutPlan, len(s.store))
-	for i, value := range s.store {
-		if name == value.ExerciseName {
-			newSlice = slices.Replace(s.store, i, 1)
+// UpdateWorkoutSession replaces the session, including all of its exercises
+// and sets.
+func (s *InMemoryStore) UpdateWorkoutSession(owner string, session WorkoutSession) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.sessionIndex(owner, session.Id)
+	if i < 0 {
+		return api.ErrSessionNotFound
+	}
+	if err := s.checkSessionPlan(owner, session.PlanId); err != nil {
+		return err
+	}
+	exercises, err := s.newSessionExercises(session.Exercises)
+	if err != nil {
+		return err
+	}
+	session.Plan = nil
+	session.PlanId = copyPointer(session.PlanId)
+	session.EndedAt = copyPointer(session.EndedAt)
+	session.Exercises = exercises
+	s.sessions[owner][i] = session
+	return nil
+}
+
+func (s *InMemoryStore) DeleteWorkoutSession(owner string, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.sessionIndex(owner, id)
+	if i < 0 {
+		return api.ErrSessionNotFound
+	}
+	s.sessions[owner] = slices.Delete(s.sessions[owner], i, i+1)
+	return nil
+}
+
+// AddLoggedSet appends a set to the last entry of the exercise in the
+// session, adding the exercise to the session when it is not there yet.
+func (s *InMemoryStore) AddLoggedSet(owner string, sessionId int, input LoggedSetInput) (LoggedSet, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.sessionIndex(owner, sessionId)
+	if i < 0 {
+		return LoggedSet{}, api.ErrSessionNotFound
+	}
+	exercise, err := s.findExercise(input.ExerciseId, input.ExerciseName)
+	if err != nil {
+		return LoggedSet{}, err
+	}
+
+	session := &s.sessions[owner][i]
+	j := -1
+	for k, sessionExercise := range session.Exercises {
+		if sessionExercise.ExerciseId == exercise.Id {
+			j = k
+		}
+	}
+	if j < 0 {
+		session.Exercises = append(session.Exercises, SessionExercise{
+			Id:         s.newId(),
+			SessionId:  sessionId,
+			ExerciseId: exercise.Id,
+			Sets:       []LoggedSet{},
+		})
+		j = len(session.Exercises) - 1
+	}
+
+	set := input.LoggedSet
+	set.Id = s.newId()
+	set.SessionExerciseId = session.Exercises[j].Id
+	set.RPE = copyPointer(set.RPE)
+	session.Exercises[j].Sets = append(session.Exercises[j].Sets, set)
+	return set, nil
+}
+
+// newSessionExercises resolves the exercises and gives them and their sets
+// new ids. The caller must hold the lock.
+func (s *InMemoryStore) newSessionExercises(input []SessionExercise) ([]SessionExercise, error) {
+	exercises := make([]SessionExercise, 0, len(input))
+	for _, exercise := range input {
+		catalogExercise, err := s.findExercise(exercise.ExerciseId, exercise.ExerciseName)
+		if err != nil {
+			return nil, err
+		}
+		sessionExercise := SessionExercise{
+			Id:         s.newId(),
+			ExerciseId: catalogExercise.Id,
+			Sets:       make([]LoggedSet, 0, len(exercise.Sets)),
 		}
+		for _, set := range exercise.Sets {
+			set.Id = s.newId()
+			set.SessionExerciseId = sessionExercise.Id
+			set.RPE = copyPointer(set.RPE)
+			sessionExercise.Sets = append(sessionExercise.Sets, set)
+		}
+		exercises = append(exercises, sessionExercise)
 	}
+	return exercises, nil
+}
 
-	s.store = newSlice
+func (s *InMemoryStore) sessionIndex(owner string, id int) int {
+	return slices.IndexFunc(s.sessions[owner], func(session WorkoutSession) bool {
+		return session.Id == id
+	})
 }
 
-func (s *InMemoryStore) GetWorkoutPlanList() []WorkoutPlan {
-	return s.store
+// getWorkoutSession returns a copy of the session with its plan. The caller
+// must hold the lock.
+func (s *InMemoryStore) getWorkoutSession(owner string, id int) (WorkoutSession, error) {
+	i := s.sessionIndex(owner, id)
+	if i < 0 {
+		return WorkoutSession{}, api.ErrSessionNotFound
+	}
+	session := s.copySession(s.sessions[owner][i])
+	if session.PlanId != nil {
+		if plan, ok := s.findPlan(owner, *session.PlanId); ok {
+			session.Plan = &plan
+		}
+	}
+	return session, nil
+}
+
+// sortedSessions returns copies of the owner's sessions, newest first.
+func (s *InMemoryStore) sortedSessions(owner string) []WorkoutSession {
+	sessions := make([]WorkoutSession, 0, len(s.sessions[owner]))
+	for _, session := range s.sessions[owner] {
+		sessions = append(sessions, s.copySession(session))
+	}
+	slices.SortFunc(sessions, func(a, b WorkoutSession) int {
+		return cmp.Or(b.StartedAt.Compare(a.StartedAt), cmp.Compare(b.Id, a.Id))
+	})
+	return sessions
+}
+
+// copySession deep copies a stored session and fills in the current
+// catalog names of its exercises.
+func (s *InMemoryStore) copySession(session WorkoutSession) WorkoutSession {
+	session.PlanId = copyPointer(session.PlanId)
+	session.EndedAt = copyPointer(session.EndedAt)
+	exercises := make([]SessionExercise, 0, len(session.Exercises))
+	for _, exercise := range session.Exercises {
+		exercise.SessionId = session.Id
+		exercise.ExerciseName = s.exercises[exercise.ExerciseId].Name
+		sets := make([]LoggedSet, 0, len(exercise.Sets))
+		for _, set := range exercise.Sets {
+			set.RPE = copyPointer(set.RPE)
+			sets = append(sets, set)
+		}
+		exercise.Sets = sets
+		exercises = append(exercises, exercise)
+	}
+	session.Exercises = exercises
+	return session
+}
+
+func (s *InMemoryStore) findPlan(owner string, id int) (WorkoutPlan, bool) {
+	for _, plan := range s.plans {
+		if plan.owner == owner && plan.Id == id {
+			return plan.WorkoutPlan, true
+		}
+	}
+	return WorkoutPlan{}, false
+}
+
+// checkSessionPlan makes sure a session or schedule only links to a plan of
+// its owner.
+func (s *InMemoryStore) checkSessionPlan(owner string, planId *int) error {
+	if planId == nil {
+		return nil
+	}
+	if _, ok := s.findPlan(owner, *planId); !ok {
+		return api.ErrWorkoutPlanNotFound
+	}
+	return nil
+}
+
+func (s *InMemoryStore) AddWorkoutSchedule(owner string, schedule WorkoutSchedule) (WorkoutSchedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	plan, ok := s.findPlan(owner, schedule.PlanId)
+	if !ok {
+		return WorkoutSchedule{}, api.ErrWorkoutPlanNotFound
+	}
+	schedule.Id = s.newId()
+	schedule.ExerciseName = plan.ExerciseName
+	s.schedules = append(s.schedules, memorySchedule{owner: owner, WorkoutSchedule: schedule})
+	return schedule, nil
+}
+
+func (s *InMemoryStore) GetWorkoutScheduleList(owner string) ([]WorkoutSchedule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	schedules := []WorkoutSchedule{}
+	for _, schedule := range s.schedules {
+		if schedule.owner != owner {
+			continue
+		}
+		plan, _ := s.findPlan(owner, schedule.PlanId)
+		schedule.ExerciseName = plan.ExerciseName
+		schedules = append(schedules, schedule.WorkoutSchedule)
+	}
+	slices.SortFunc(schedules, func(a, b WorkoutSchedule) int {
+		return cmp.Or(a.StartsAt.Compare(b.StartsAt), cmp.Compare(a.Id, b.Id))
+	})
+	return schedules, nil
+}
+
+func (s *InMemoryStore) UpdateWorkoutSchedule(owner string, schedule WorkoutSchedule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.scheduleIndex(owner, schedule.Id)
+	if i < 0 {
+		return api.ErrScheduleNotFound
+	}
+	if _, ok := s.findPlan(owner, schedule.PlanId); !ok {
+		return api.ErrWorkoutPlanNotFound
+	}
+	s.schedules[i].WorkoutSchedule = schedule
+	return nil
+}
+
+func (s *InMemoryStore) DeleteWorkoutSchedule(owner string, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.scheduleIndex(owner, id)
+	if i < 0 {
+		return api.ErrScheduleNotFound
+	}
+	s.schedules = slices.Delete(s.schedules, i, i+1)
+	return nil
+}
+
+func (s *InMemoryStore) scheduleIndex(owner string, id int) int {
+	return slices.IndexFunc(s.schedules, func(schedule memorySchedule) bool {
+		return schedule.owner == owner && schedule.Id == id
+	})
+}
+
+func (s *InMemoryStore) GetProgressReport(owner string, filter ProgressFilter) ([]ExerciseProgress, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	weekly := computeProgress(s.sortedSessions(owner), filter, false)
+	report := make([]ExerciseProgress, 0, len(weekly))
+	for _, progress := range weekly {
+		report = append(report, progress.ExerciseProgress)
+	}
+	return report, nil
+}
+
+func (s *InMemoryStore) GetWeeklyProgressReport(owner string, filter ProgressFilter) ([]WeeklyProgress, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return computeProgress(s.sortedSessions(owner), filter, true), nil
+}
+
+func (s *InMemoryStore) AddRefreshToken(token RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token.UsedAt = nil
+	token.RevokedAt = nil
+	s.refreshTokens[token.TokenHash] = token
+	return nil
+}
+
+func (s *InMemoryStore) UseRefreshToken(tokenHash string) (RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.refreshTokens[tokenHash]
+	if !ok {
+		return RefreshToken{}, api.ErrInvalidRefreshToken
+	}
+	if token.UsedAt != nil {
+		return token, api.ErrRefreshTokenReused
+	}
+	now := time.Now()
+	token.UsedAt = &now
+	s.refreshTokens[tokenHash] = token
+	return token, nil
+}
+
+func (s *InMemoryStore) RevokeRefreshTokenFamily(familyId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.revokeRefreshTokens(func(token RefreshToken) bool {
+		return token.FamilyId == familyId
+	})
+	return nil
+}
+
+// revokeRefreshTokens revokes the tokens matching revoke that are not
+// revoked yet. The caller must hold the lock.
+func (s *InMemoryStore) revokeRefreshTokens(revoke func(RefreshToken) bool) {
+	now := time.Now()
+	for hash, token := range s.refreshTokens {
+		if token.RevokedAt == nil && revoke(token) {
+			token.RevokedAt = &now
+			s.refreshTokens[hash] = token
+		}
+	}
+}
+
+func (s *InMemoryStore) RevokeToken(jti string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.revokedTokens[jti]; !ok {
+		s.revokedTokens[jti] = expiresAt
+	}
+	return nil
+}
+
+func (s *InMemoryStore) RevokeUserTokens(username string, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if user, ok := s.users[username]; ok {
+		before = before.Truncate(middleware.TokenTimePrecision)
+		user.tokensRevokedBefore = &before
+	}
+	s.revokeRefreshTokens(func(token RefreshToken) bool {
+		return token.Username == username
+	})
+	return nil
+}
+
+func (s *InMemoryStore) RevokeUserRefreshToken(username, tokenHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.refreshTokens[tokenHash]
+	if !ok || token.Username != username {
+		return nil
+	}
+	s.revokeRefreshTokens(func(other RefreshToken) bool {
+		return other.FamilyId == token.FamilyId
+	})
+	return nil
+}
+
+func (s *InMemoryStore) IsTokenRevoked(jti, username string, issuedAt time.Time) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.revokedTokens[jti]; ok {
+		return true, nil
+	}
+	user, ok := s.users[username]
+	return ok && user.tokensRevokedBefore != nil && issuedAt.Before(*user.tokensRevokedBefore), nil
+}
+
+func (s *InMemoryStore) PruneRevokedTokens(now time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var pruned int64
+	for jti, expiresAt := range s.revokedTokens {
+		if expiresAt.Before(now) {
+			delete(s.revokedTokens, jti)
+			pruned++
+		}
+	}
+	for hash, token := range s.refreshTokens {
+		if token.ExpiresAt.Before(now) {
+			delete(s.refreshTokens, hash)
+			pruned++
+		}
+	}
+	return pruned, nil
+}
+
+func (s *InMemoryStore) GetUsersByEmail(email string) ([]UserDetails, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := []UserDetails{}
+	for _, user := range s.users {
+		if strings.EqualFold(user.Email, email) {
+			users = append(users, UserDetails{Username: user.Username, Email: user.Email})
+		}
+	}
+	slices.SortFunc(users, func(a, b UserDetails) int {
+		return cmp.Compare(a.Username, b.Username)
+	})
+	return users, nil
+}
+
+func (s *InMemoryStore) AddPasswordResetToken(token PasswordResetToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token.UsedAt = nil
+	s.resetTokens[token.TokenHash] = token
+	return nil
+}
+
+func (s *InMemoryStore) ResetPassword(tokenHash, password string, now time.Time) (string, error) {
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.resetTokens[tokenHash]
+	if !ok || token.UsedAt != nil || !token.ExpiresAt.After(now) {
+		return "", api.ErrInvalidResetToken
+	}
+	token.UsedAt = &now
+	s.resetTokens[tokenHash] = token
+	if user, ok := s.users[token.Username]; ok {
+		user.passwordHash = passwordHash
+	}
+	return token.Username, nil
+}
+
+func (s *InMemoryStore) AddEmailVerificationToken(token EmailVerificationToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token.UsedAt = nil
+	s.verificationTokens[token.TokenHash] = token
+	return nil
+}
+
+func (s *InMemoryStore) VerifyEmail(tokenHash string, now time.Time) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.verificationTokens[tokenHash]
+	if !ok || token.UsedAt != nil || !token.ExpiresAt.After(now) {
+		return "", api.ErrInvalidVerificationToken
+	}
+	token.UsedAt = &now
+	s.verificationTokens[tokenHash] = token
+	if user, ok := s.users[token.Username]; ok {
+		user.EmailVerified = true
+	}
+	return token.Username, nil
+}
+
+func copyPointer[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	v := *p
+	return &v
+}
+
+func (s *InMemoryStore) GetLoginFailures(username string) (LoginFailures, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.loginFailures[username], nil
+}
+
+func (s *InMemoryStore) RecordLoginFailure(username string, now time.Time, window time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	failures := s.loginFailures[username]
+	failures.Username = username
+	if failures.FirstFailureAt == nil || !failures.FirstFailureAt.After(now.Add(-window)) {
+		failures.Failures = 0
+		failures.FirstFailureAt = &now
+	}
+	failures.Failures++
+	s.loginFailures[username] = failures
+	return failures.Failures, nil
+}
+
+func (s *InMemoryStore) LockLogin(username string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	failures := s.loginFailures[username]
+	failures.Username = username
+	failures.LockedUntil = &until
+	s.loginFailures[username] = failures
+	return nil
+}
+
+func (s *InMemoryStore) ResetLoginFailures(username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.loginFailures, username)
+	return nil
+}
+
+func (s *InMemoryStore) PruneLoginFailures(now time.Time, window time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var pruned int64
+	for username, failures := range s.loginFailures {
+		windowEnded := failures.FirstFailureAt == nil || !failures.FirstFailureAt.After(now.Add(-window))
+		if windowEnded && (failures.LockedUntil == nil || !failures.LockedUntil.After(now)) {
+			delete(s.loginFailures, username)
+			pruned++
+		}
+	}
+	return pruned, nil
+}
+
+// ImportWorkoutData builds all plans and sessions before adding any, so a
+// failing import leaves the store as it was.
+func (s *InMemoryStore) ImportWorkoutData(owner string, plans []WorkoutPlan, sessions []WorkoutSession) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	planIds := map[int]int{}
+	newPlans := make([]memoryPlan, 0, len(plans))
+	for _, plan := range plans {
+		exercise, err := s.findExercise(plan.ExerciseId, plan.ExerciseName)
+		if err != nil {
+			return err
+		}
+		id := s.newId()
+		if plan.Id != 0 {
+			planIds[plan.Id] = id
+		}
+		plan.Id = id
+		plan.ExerciseId = exercise.Id
+		plan.ExerciseName = exercise.Name
+		newPlans = append(newPlans, memoryPlan{owner: owner, WorkoutPlan: plan})
+	}
+
+	newSessions := make([]WorkoutSession, 0, len(sessions))
+	for _, session := range sessions {
+		if session.PlanId != nil {
+			id, ok := planIds[*session.PlanId]
+			if !ok {
+				return api.ErrWorkoutPlanNotFound
+			}
+			session.PlanId = &id
+		}
+		exercises, err := s.newSessionExercises(session.Exercises)
+		if err != nil {
+			return err
+		}
+		session.Id = s.newId()
+		session.Plan = nil
+		session.EndedAt = copyPointer(session.EndedAt)
+		session.Exercises = exercises
+		newSessions = append(newSessions, session)
+	}
+
+	s.plans = append(s.plans, newPlans...)
+	s.sessions[owner] = append(s.sessions[owner], newSessions...)
+	return nil
+}
+
+func (s *InMemoryStore) GetExerciseAliases(owner string) ([]ExerciseAlias, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	aliases := []ExerciseAlias{}
+	for alias, id := range s.aliases[owner] {
+		aliases = append(aliases, ExerciseAlias{Alias: alias, ExerciseId: id, ExerciseName: s.exercises[id].Name})
+	}
+	slices.SortFunc(aliases, func(a, b ExerciseAlias) int {
+		return cmp.Compare(a.Alias, b.Alias)
+	})
+	return aliases, nil
+}
+
+func (s *InMemoryStore) SetExerciseAlias(owner string, alias ExerciseAlias) (ExerciseAlias, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	exercise, err := s.findExercise(alias.ExerciseId, alias.ExerciseName)
+	if err != nil {
+		return ExerciseAlias{}, err
+	}
+	alias = ExerciseAlias{Alias: normalizeAlias(alias.Alias), ExerciseId: exercise.Id, ExerciseName: exercise.Name}
+	if s.aliases[owner] == nil {
+		s.aliases[owner] = map[string]int{}
+	}
+	s.aliases[owner][alias.Alias] = exercise.Id
+	return alias, nil
+}
+
+func (s *InMemoryStore) DeleteExerciseAlias(owner, alias string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	alias = normalizeAlias(alias)
+	if _, ok := s.aliases[owner][alias]; !ok {
+		return api.ErrExerciseAliasNotFound
+	}
+	delete(s.aliases[owner], alias)
+	return nil
 }
@@ -0,0 +1,160 @@
+package tracker
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Oriseer/workout_tracker/api"
+)
+
+func TestInMemoryStore(t *testing.T) {
+	store := NewInMemoryStore()
+	pushup, _ := store.AddExercise(Exercise{Name: "pushup", Category: "strength"})
+	store.AddExercise(Exercise{Name: "pullup", Category: "strength"})
+	server := newTestServer(store)
+
+	alice := server.register(t, "alice")
+	bob := server.register(t, "bob")
+
+	t.Run("users log in with their bcrypt password only", func(t *testing.T) {
+		if alice == "" {
+			t.Fatal("Expected alice to log in")
+		}
+		response := server.send(http.MethodPost, "/auth/login", "", `{"username": "alice", "password": "wrong"}`)
+		AssertResponseStatus(t, http.StatusBadRequest, response.Code)
+		response = server.send(http.MethodPost, "/auth/register", "", `{"username": "alice", "password": "testpass", "email": "alice@example.com"}`)
+		AssertResponseStatus(t, http.StatusBadRequest, response.Code)
+	})
+
+	t.Run("workout plans are created, updated, listed and deleted per owner", func(t *testing.T) {
+		AssertResponseStatus(t, http.StatusCreated, server.send(http.MethodPost, "/workout-plans/", alice, `{"ExerciseName": "PUSHUP", "Repetitions": 10, "Sets": 3, "Weight": 0}`).Code)
+		AssertResponseStatus(t, http.StatusCreated, server.send(http.MethodPost, "/workout-plans/", alice, `{"ExerciseName": "pullup", "Repetitions": 5, "Sets": 3, "Weight": 10}`).Code)
+		AssertResponseStatus(t, http.StatusBadRequest, server.send(http.MethodPost, "/workout-plans/", alice, `{"ExerciseName": "squat", "Repetitions": 5, "Sets": 3}`).Code)
+		AssertResponseStatus(t, http.StatusNoContent, server.send(http.MethodPut, "/workout-plans/", alice, `{"ExerciseName": "pushup", "Repetitions": 12, "Sets": 4, "Weight": 0}`).Code)
+
+		page := WorkoutPlanPage{}
+		json.NewDecoder(server.send(http.MethodGet, "/workouts?sort=weight&order=desc", alice, "").Body).Decode(&page)
+		if len(page.WorkoutPlans) != 2 || page.WorkoutPlans[0].ExerciseName != "pullup" || page.WorkoutPlans[1].Repititions != 12 {
+			t.Errorf("Expected pullup then the updated pushup plan, got %+v", page.WorkoutPlans)
+		}
+		if page.WorkoutPlans[1].ExerciseName != "pushup" || page.WorkoutPlans[1].ExerciseId != pushup.Id {
+			t.Errorf("Expected the plan to use the catalog name and id, got %+v", page.WorkoutPlans[1])
+		}
+
+		page = WorkoutPlanPage{}
+		json.NewDecoder(server.send(http.MethodGet, "/workouts", bob, "").Body).Decode(&page)
+		if len(page.WorkoutPlans) != 0 {
+			t.Errorf("Expected bob to see no plans, got %+v", page.WorkoutPlans)
+		}
+		AssertResponseStatus(t, http.StatusNotFound, server.send(http.MethodDelete, "/workout-plans/pullup", bob, "").Code)
+	})
+
+	t.Run("workout plans are paged with cursors", func(t *testing.T) {
+		names := []string{}
+		cursor := ""
+		for pages := 0; pages < 5; pages++ {
+			page := WorkoutPlanPage{}
+			json.NewDecoder(server.send(http.MethodGet, "/workouts?sort=exercise_name&limit=1&cursor="+cursor, alice, "").Body).Decode(&page)
+			for _, plan := range page.WorkoutPlans {
+				names = append(names, plan.ExerciseName)
+			}
+			if cursor = page.NextCursor; cursor == "" {
+				break
+			}
+		}
+		if fmt.Sprint(names) != "[pullup pushup]" {
+			t.Errorf("Expected [pullup pushup], got %v", names)
+		}
+	})
+
+	t.Run("sessions log sets and link plans of the owner", func(t *testing.T) {
+		page := WorkoutPlanPage{}
+		json.NewDecoder(server.send(http.MethodGet, "/workouts?exercise_name=push", alice, "").Body).Decode(&page)
+		planId := page.WorkoutPlans[0].Id
+
+		body := fmt.Sprintf(`{"plan_id": %d, "started_at": "2025-01-06T10:00:00Z", "exercises": [{"exercise_name": "pushup", "sets": [{"reps": 10, "weight": 0}]}]}`, planId)
+		AssertResponseStatus(t, http.StatusBadRequest, server.send(http.MethodPost, "/sessions", bob, body).Code)
+		response := server.send(http.MethodPost, "/sessions", alice, body)
+		AssertResponseStatus(t, http.StatusCreated, response.Code)
+		session := WorkoutSession{}
+		json.NewDecoder(response.Body).Decode(&session)
+
+		path := fmt.Sprintf("/sessions/%d", session.Id)
+		AssertResponseStatus(t, http.StatusCreated, server.send(http.MethodPost, path+"/sets", alice, `{"exercise_name": "pushup", "reps": 8, "weight": 10}`).Code)
+		AssertResponseStatus(t, http.StatusNotFound, server.send(http.MethodGet, path, bob, "").Code)
+
+		session = WorkoutSession{}
+		json.NewDecoder(server.send(http.MethodGet, path, alice, "").Body).Decode(&session)
+		if session.Plan == nil || len(session.Exercises) != 1 || len(session.Exercises[0].Sets) != 2 {
+			t.Fatalf("Expected the plan and two pushup sets, got %+v", session)
+		}
+
+		report := []ExerciseProgress{}
+		json.NewDecoder(server.send(http.MethodGet, "/reports/progress", alice, "").Body).Decode(&report)
+		if len(report) != 1 || report[0].SetCount != 2 || report[0].TotalVolume != 80 {
+			t.Errorf("Expected two pushup sets with volume 80, got %+v", report)
+		}
+	})
+
+	t.Run("deleting a plan unlinks sessions and deletes schedules", func(t *testing.T) {
+		page := WorkoutPlanPage{}
+		json.NewDecoder(server.send(http.MethodGet, "/workouts?exercise_name=push", alice, "").Body).Decode(&page)
+		planId := page.WorkoutPlans[0].Id
+
+		body := fmt.Sprintf(`{"plan_id": %d, "starts_at": "2025-01-06T10:00:00Z", "rrule": "FREQ=WEEKLY"}`, planId)
+		AssertResponseStatus(t, http.StatusCreated, server.send(http.MethodPost, "/schedules", alice, body).Code)
+		AssertResponseStatus(t, http.StatusNoContent, server.send(http.MethodDelete, "/workout-plans/pushup", alice, "").Code)
+
+		schedules := []WorkoutSchedule{}
+		json.NewDecoder(server.send(http.MethodGet, "/schedules", alice, "").Body).Decode(&schedules)
+		sessions := []WorkoutSession{}
+		json.NewDecoder(server.send(http.MethodGet, "/sessions", alice, "").Body).Decode(&sessions)
+		if len(schedules) != 0 || len(sessions) != 1 || sessions[0].PlanId != nil {
+			t.Errorf("Expected no schedules and an unlinked session, got %+v and %+v", schedules, sessions)
+		}
+	})
+
+	t.Run("logout revokes tokens", func(t *testing.T) {
+		token := server.register(t, "carol")
+		AssertResponseStatus(t, http.StatusNoContent, server.send(http.MethodPost, "/auth/logout-all", token, "").Code)
+		AssertResponseStatus(t, http.StatusUnauthorized, server.send(http.MethodGet, "/sessions", token, "").Code)
+	})
+
+	t.Run("returned values are copies", func(t *testing.T) {
+		sessions, _ := store.GetWorkoutSessionList("alice")
+		sessions[0].Exercises[0].Sets[0].Reps = 1000
+		again, _ := store.GetWorkoutSessionList("alice")
+		if again[0].Exercises[0].Sets[0].Reps == 1000 {
+			t.Error("Expected changes to a returned session not to reach the store")
+		}
+	})
+
+	t.Run("is safe for concurrent use", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				store.AddWorkoutSession("dave", WorkoutSession{StartedAt: time.Now(), Exercises: []SessionExercise{{ExerciseId: pushup.Id}}})
+				store.GetWorkoutSessionList("dave")
+				store.GetProgressReport("dave", ProgressFilter{})
+			}()
+		}
+		wg.Wait()
+
+		sessions, _ := store.GetWorkoutSessionList("dave")
+		if len(sessions) != 20 {
+			t.Errorf("Expected 20 sessions, got %d", len(sessions))
+		}
+	})
+
+	t.Run("exercises in use cannot be deleted", func(t *testing.T) {
+		if err := store.DeleteExercise(pushup.Id); err != api.ErrExerciseInUse {
+			t.Errorf("Expected %v, got %v", api.ErrExerciseInUse, err)
+		}
+	})
+}
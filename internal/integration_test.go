@@ -6,17 +6,59 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/Oriseer/workout_tracker/api"
+	"github.com/Oriseer/workout_tracker/config"
 	tracker "github.com/Oriseer/workout_tracker/internal"
 )
 
 func TestIntegration(t *testing.T) {
-	db := tracker.NewDatabase()
+	cfg := integrationConfig(t)
+	db, err := tracker.NewDatabase(cfg)
+	if err != nil {
+		t.Skipf("skipping integration test, database unavailable: %v", err)
+	}
+	defer db.Close()
+
+	testIntegration(t, db, cfg)
+}
+
+func TestSQLiteIntegration(t *testing.T) {
+	cfg := integrationConfig(t)
+	cfg.Store = config.SQLiteScheme + filepath.Join(t.TempDir(), "tracker.db")
+	db, err := tracker.NewDatabase(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
 
-	server := tracker.NewWorkoutServer(db)
+	testIntegration(t, db, cfg)
+}
+
+// integrationConfig reads the database settings from the environment and
+// migrates the schema on connect.
+func integrationConfig(t *testing.T) config.Config {
+	t.Helper()
+	cfg, err := config.Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Database.AutoMigrate = true
+	cfg.JWT.Key = "integration-test-key"
+	return cfg
+}
+
+func testIntegration(t *testing.T, db *tracker.DB, cfg config.Config) {
+	db.MustExec("INSERT INTO EXERCISES (exercise_name, description, category) VALUES ('pushup', 'bodyweight exercise', 'strength'), ('pullup', 'bodyweight exercise', 'strength') ON CONFLICT DO NOTHING")
+	var pushupId int
+	db.Get(&pushupId, "SELECT id FROM EXERCISES WHERE exercise_name = 'pushup'")
+
+	server := tracker.NewWorkoutServer(db, cfg)
 
 	reqBody := []byte(`{"username": "testuser", "password": "testpass", "email": "test@gmail.com"}`)
 	request, _ := http.NewRequest(http.MethodPost, "/auth/register", bytes.NewBuffer(reqBody))
@@ -41,6 +83,26 @@ func TestIntegration(t *testing.T) {
 
 	})
 
+	t.Run("Refresh token rotation and reuse detection", func(t *testing.T) {
+		refresh := func(refreshToken string) (*httptest.ResponseRecorder, tracker.Token) {
+			req, _ := http.NewRequest(http.MethodPost, "/auth/refresh", strings.NewReader(`{"refresh_token": "`+refreshToken+`"}`))
+			response := httptest.NewRecorder()
+			server.ServeHTTP(response, req)
+			rotated := tracker.Token{}
+			json.NewDecoder(response.Body).Decode(&rotated)
+			return response, rotated
+		}
+
+		response, rotated := refresh(token.RefreshToken)
+		tracker.AssertResponseStatus(t, http.StatusOK, response.Code)
+
+		response, _ = refresh(token.RefreshToken)
+		tracker.AssertResponseStatus(t, http.StatusUnauthorized, response.Code)
+
+		response, _ = refresh(rotated.RefreshToken)
+		tracker.AssertResponseStatus(t, http.StatusUnauthorized, response.Code)
+	})
+
 	t.Run("add workout plan with correct token", func(t *testing.T) {
 		reqBody := []byte(`{"exerciseName": "pushup", "repetitions": 11, "sets": 2, "weight": 20}`)
 		req, _ := http.NewRequest(http.MethodPost, "/workout-plans/", bytes.NewBuffer(reqBody))
@@ -54,6 +116,19 @@ func TestIntegration(t *testing.T) {
 
 		tracker.AssertResponseStatus(t, http.StatusCreated, response.Code)
 	})
+	t.Run("add workout plan for an exercise missing from the catalog", func(t *testing.T) {
+		reqBody := []byte(`{"exerciseName": "pushups", "repetitions": 11, "sets": 2, "weight": 20}`)
+		req, _ := http.NewRequest(http.MethodPost, "/workout-plans/", bytes.NewBuffer(reqBody))
+		req.Header.Set("Authorization", "Bearer "+token.Token)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, req)
+
+		body := api.ErrorWriter{}
+		json.NewDecoder(response.Body).Decode(&body)
+		assertErrorMessage(t, body, "Bad Request: "+api.ErrExerciseNotFound.Error())
+
+		tracker.AssertResponseStatus(t, http.StatusBadRequest, response.Code)
+	})
 	t.Run("add workout plan with incorrect token", func(t *testing.T) {
 		reqBody := []byte(`{"exerciseName": "pushup", "repetitions": 11, "sets": 2, "weight": 20}`)
 		req, _ := http.NewRequest(http.MethodPost, "/workout-plans/", bytes.NewBuffer(reqBody))
@@ -65,13 +140,13 @@ func TestIntegration(t *testing.T) {
 
 		json.NewDecoder(response.Body).Decode(&body)
 
-		expectedError := "Bad Request: " + api.ErrInvalidToken.Error()
+		expectedError := "Unauthorized: " + api.ErrInvalidToken.Error()
 
 		if expectedError != body.ErrorMessage {
 			t.Errorf("Expected error message %q, got %q", expectedError, body.ErrorMessage)
 		}
 
-		tracker.AssertResponseStatus(t, http.StatusBadRequest, response.Code)
+		tracker.AssertResponseStatus(t, http.StatusUnauthorized, response.Code)
 	})
 
 	t.Run("Get Workout Plan List with correct token", func(t *testing.T) {
@@ -82,7 +157,9 @@ func TestIntegration(t *testing.T) {
 		server.ServeHTTP(response, req)
 
 		responseBody := response.Body.String()
-		requiredResponse := `[{"ExerciseName":"pushup","Repetitions":11,"Sets":2,"Weight":20}]`
+		var planId int
+		db.Get(&planId, "SELECT id FROM WORKOUT_PLAN WHERE owner = 'testuser' AND exercise_name = 'pushup'")
+		requiredResponse := fmt.Sprintf(`{"workout_plans":[{"Id":%d,"ExerciseId":%d,"ExerciseName":"pushup","Repetitions":11,"Sets":2,"Weight":20}]}`, planId, pushupId)
 		tracker.AssertResponseStatus(t, http.StatusOK, response.Code)
 
 		if responseBody == "" {
@@ -93,6 +170,42 @@ func TestIntegration(t *testing.T) {
 			t.Errorf("Expected response body '%s', got '%s'", requiredResponse, responseBody)
 		}
 	})
+	t.Run("Get Workout Plan List filtered, sorted and paged in SQL", func(t *testing.T) {
+		reqBody := []byte(`{"exerciseName": "pullup", "repetitions": 8, "sets": 3, "weight": 5}`)
+		req, _ := http.NewRequest(http.MethodPost, "/workout-plans/", bytes.NewBuffer(reqBody))
+		req.Header.Set("Authorization", "Bearer "+token.Token)
+		server.ServeHTTP(httptest.NewRecorder(), req)
+
+		list := func(query string) tracker.WorkoutPlanPage {
+			req, _ := http.NewRequest(http.MethodGet, "/workouts"+query, nil)
+			req.Header.Set("Authorization", "Bearer "+token.Token)
+			response := httptest.NewRecorder()
+			server.ServeHTTP(response, req)
+			tracker.AssertResponseStatus(t, http.StatusOK, response.Code)
+			page := tracker.WorkoutPlanPage{}
+			json.NewDecoder(response.Body).Decode(&page)
+			return page
+		}
+
+		page := list("?sort=weight&order=desc&limit=1")
+		if len(page.WorkoutPlans) != 1 || page.WorkoutPlans[0].ExerciseName != "pushup" || page.NextCursor == "" {
+			t.Fatalf("Expected the heaviest plan and a cursor, got %+v", page)
+		}
+		page = list("?sort=weight&order=desc&limit=1&cursor=" + page.NextCursor)
+		if len(page.WorkoutPlans) != 1 || page.WorkoutPlans[0].ExerciseName != "pullup" || page.NextCursor != "" {
+			t.Errorf("Expected the lighter plan on the last page, got %+v", page)
+		}
+
+		page = list("?min_reps=9&exercise_name=PUSH")
+		if len(page.WorkoutPlans) != 1 || page.WorkoutPlans[0].ExerciseName != "pushup" {
+			t.Errorf("Expected only the pushup plan, got %+v", page)
+		}
+
+		req, _ = http.NewRequest(http.MethodDelete, "/workout-plans/pullup", nil)
+		req.Header.Set("Authorization", "Bearer "+token.Token)
+		server.ServeHTTP(httptest.NewRecorder(), req)
+	})
+
 	t.Run("Get Workout Plan List with incorrect token", func(t *testing.T) {
 		req, _ := http.NewRequest(http.MethodGet, "/workouts", nil)
 		req.Header.Set("Authorization", "dummy")
@@ -104,12 +217,12 @@ func TestIntegration(t *testing.T) {
 
 		json.NewDecoder(response.Body).Decode(&body)
 
-		expectedError := "Bad Request: " + api.ErrInvalidToken.Error()
+		expectedError := "Unauthorized: " + api.ErrInvalidToken.Error()
 
 		if expectedError != body.ErrorMessage {
 			t.Errorf("Expected error message %q, got %q", expectedError, body.ErrorMessage)
 		}
-		tracker.AssertResponseStatus(t, http.StatusBadRequest, response.Code)
+		tracker.AssertResponseStatus(t, http.StatusUnauthorized, response.Code)
 	})
 
 	t.Run("Update Workout Plan with correct token", func(t *testing.T) {
@@ -143,15 +256,59 @@ func TestIntegration(t *testing.T) {
 
 		json.NewDecoder(response.Body).Decode(&body)
 
-		expectedError := "Bad Request: " + api.ErrInvalidToken.Error()
+		expectedError := "Unauthorized: " + api.ErrInvalidToken.Error()
 
 		if expectedError != body.ErrorMessage {
 			t.Errorf("Expected error message %q, got %q", expectedError, body.ErrorMessage)
 		}
-		tracker.AssertResponseStatus(t, http.StatusBadRequest, response.Code)
+		tracker.AssertResponseStatus(t, http.StatusUnauthorized, response.Code)
 
 	})
 
+	t.Run("Log a workout session following the plan", func(t *testing.T) {
+		var planId int
+		db.Get(&planId, "SELECT id FROM WORKOUT_PLAN WHERE owner = 'testuser' AND exercise_name = 'pushup'")
+		reqBody := fmt.Sprintf(`{"plan_id": %d, "started_at": "2025-06-02T07:00:00Z",
+			"exercises": [{"exercise_name": "pushup", "sets": [{"reps": 8, "weight": 20, "rpe": 7}]}]}`, planId)
+		req, _ := http.NewRequest(http.MethodPost, "/sessions", strings.NewReader(reqBody))
+		req.Header.Set("Authorization", "Bearer "+token.Token)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, req)
+
+		tracker.AssertResponseStatus(t, http.StatusCreated, response.Code)
+		session := tracker.WorkoutSession{}
+		json.NewDecoder(response.Body).Decode(&session)
+
+		req, _ = http.NewRequest(http.MethodPost, fmt.Sprintf("/sessions/%d/sets", session.Id), strings.NewReader(`{"exercise_id": `+fmt.Sprint(pushupId)+`, "reps": 6, "weight": 20}`))
+		req.Header.Set("Authorization", "Bearer "+token.Token)
+		response = httptest.NewRecorder()
+		server.ServeHTTP(response, req)
+
+		tracker.AssertResponseStatus(t, http.StatusCreated, response.Code)
+
+		req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("/sessions/%d", session.Id), nil)
+		req.Header.Set("Authorization", "Bearer "+token.Token)
+		response = httptest.NewRecorder()
+		server.ServeHTTP(response, req)
+
+		tracker.AssertResponseStatus(t, http.StatusOK, response.Code)
+		session = tracker.WorkoutSession{}
+		json.NewDecoder(response.Body).Decode(&session)
+		if session.Plan == nil || session.Plan.Repititions != 8 {
+			t.Errorf("Expected session to include the followed plan, got %+v", session.Plan)
+		}
+		if len(session.Exercises) != 1 || len(session.Exercises[0].Sets) != 2 {
+			t.Errorf("Expected one exercise with two sets, got %+v", session.Exercises)
+		}
+
+		req, _ = http.NewRequest(http.MethodDelete, fmt.Sprintf("/sessions/%d", session.Id), nil)
+		req.Header.Set("Authorization", "Bearer "+token.Token)
+		response = httptest.NewRecorder()
+		server.ServeHTTP(response, req)
+
+		tracker.AssertResponseStatus(t, http.StatusNoContent, response.Code)
+	})
+
 	t.Run("Delete Workout Plan with correct token", func(t *testing.T) {
 		req, _ := http.NewRequest(http.MethodDelete, "/workout-plans/pushup", nil)
 		req.Header.Set("Authorization", "Bearer "+token.Token)
@@ -173,12 +330,12 @@ func TestIntegration(t *testing.T) {
 
 		json.NewDecoder(response.Body).Decode(&body)
 
-		expectedError := "Bad Request: " + api.ErrInvalidToken.Error()
+		expectedError := "Unauthorized: " + api.ErrInvalidToken.Error()
 
 		if expectedError != body.ErrorMessage {
 			t.Errorf("Expected error message %q, got %q", expectedError, body.ErrorMessage)
 		}
-		tracker.AssertResponseStatus(t, http.StatusBadRequest, response.Code)
+		tracker.AssertResponseStatus(t, http.StatusUnauthorized, response.Code)
 
 	})
 
@@ -191,11 +348,11 @@ func TestIntegration(t *testing.T) {
 
 		server.ServeHTTP(response, req)
 
-		tracker.AssertResponseStatus(t, http.StatusInternalServerError, response.Code)
+		tracker.AssertResponseStatus(t, http.StatusNotFound, response.Code)
 
 		responseBody := api.ErrorWriter{}
 		json.NewDecoder(response.Body).Decode(&responseBody)
-		requiredError := "Internal Server Error: sql: no rows in result set"
+		requiredError := "Not Found: " + api.ErrWorkoutPlanNotFound.Error()
 
 		assertErrorMessage(t, responseBody, requiredError)
 
@@ -209,16 +366,54 @@ func TestIntegration(t *testing.T) {
 
 		server.ServeHTTP(response, req)
 
-		tracker.AssertResponseStatus(t, http.StatusInternalServerError, response.Code)
+		tracker.AssertResponseStatus(t, http.StatusNotFound, response.Code)
 
 		responseBody := api.ErrorWriter{}
 		json.NewDecoder(response.Body).Decode(&responseBody)
-		requiredError := "Internal Server Error: sql: no rows in result set"
+		requiredError := "Not Found: " + api.ErrWorkoutPlanNotFound.Error()
 
 		assertErrorMessage(t, responseBody, requiredError)
 
 	})
 
+	t.Run("Workout plans of another user are not visible", func(t *testing.T) {
+		reqBody := []byte(`{"username": "otheruser", "password": "otherpass", "email": "other@gmail.com"}`)
+		request, _ := http.NewRequest(http.MethodPost, "/auth/register", bytes.NewBuffer(reqBody))
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		tracker.AssertResponseStatus(t, http.StatusCreated, response.Code)
+
+		reqBody = []byte(`{"username": "otheruser", "password": "otherpass"}`)
+		request, _ = http.NewRequest(http.MethodPost, "/auth/login", bytes.NewBuffer(reqBody))
+		response = httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		otherToken := tracker.Token{}
+		json.NewDecoder(response.Body).Decode(&otherToken)
+
+		reqBody = []byte(`{"exerciseName": "pullup", "repetitions": 5, "sets": 3, "weight": 0}`)
+		req, _ := http.NewRequest(http.MethodPost, "/workout-plans/", bytes.NewBuffer(reqBody))
+		req.Header.Set("Authorization", "Bearer "+otherToken.Token)
+		response = httptest.NewRecorder()
+		server.ServeHTTP(response, req)
+		tracker.AssertResponseStatus(t, http.StatusCreated, response.Code)
+
+		req, _ = http.NewRequest(http.MethodGet, "/workouts", nil)
+		req.Header.Set("Authorization", "Bearer "+token.Token)
+		response = httptest.NewRecorder()
+		server.ServeHTTP(response, req)
+
+		if strings.TrimSpace(response.Body.String()) != `{"workout_plans":[]}` {
+			t.Errorf("Expected no workout plans, got %s", response.Body.String())
+		}
+
+		req, _ = http.NewRequest(http.MethodDelete, "/workout-plans/pullup", nil)
+		req.Header.Set("Authorization", "Bearer "+token.Token)
+		response = httptest.NewRecorder()
+		server.ServeHTTP(response, req)
+
+		tracker.AssertResponseStatus(t, http.StatusNotFound, response.Code)
+	})
+
 	t.Run("Add new user with existing username", func(t *testing.T) {
 
 		reqBody := []byte(`{"username": "testuser", "password": "testpass", "email": "test@gmail.com"}`)
@@ -277,6 +472,66 @@ func TestIntegration(t *testing.T) {
 		tracker.AssertResponseStatus(t, http.StatusBadRequest, response.Code)
 	})
 
+	t.Run("Logout revokes the access token", func(t *testing.T) {
+		reqBody := []byte(`{"username": "testuser", "password": "testpass"}`)
+		request, _ := http.NewRequest(http.MethodPost, "/auth/login", bytes.NewBuffer(reqBody))
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		session := tracker.Token{}
+		json.NewDecoder(response.Body).Decode(&session)
+
+		request, _ = http.NewRequest(http.MethodPost, "/auth/logout", strings.NewReader(`{"refresh_token": "`+session.RefreshToken+`"}`))
+		request.Header.Set("Authorization", "Bearer "+session.Token)
+		response = httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		tracker.AssertResponseStatus(t, http.StatusNoContent, response.Code)
+
+		request, _ = http.NewRequest(http.MethodGet, "/workouts", nil)
+		request.Header.Set("Authorization", "Bearer "+session.Token)
+		response = httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		tracker.AssertResponseStatus(t, http.StatusUnauthorized, response.Code)
+
+		request, _ = http.NewRequest(http.MethodPost, "/auth/refresh", strings.NewReader(`{"refresh_token": "`+session.RefreshToken+`"}`))
+		response = httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		tracker.AssertResponseStatus(t, http.StatusUnauthorized, response.Code)
+
+		if _, err := db.PruneRevokedTokens(time.Now()); err != nil {
+			t.Errorf("Expected pruning to succeed, got %v", err)
+		}
+	})
+
+	t.Run("Password reset with a mailed token", func(t *testing.T) {
+		mailFile := filepath.Join(t.TempDir(), "mail.log")
+		server.SetMailer(&tracker.FileMailer{Path: mailFile})
+
+		request, _ := http.NewRequest(http.MethodPost, "/auth/password/forgot", strings.NewReader(`{"email": "test@gmail.com"}`))
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		tracker.AssertResponseStatus(t, http.StatusAccepted, response.Code)
+
+		mail, _ := os.ReadFile(mailFile)
+		lines := strings.Split(strings.TrimSpace(string(mail)), "\r\n")
+		// The token is the only line of the body without spaces
+		resetToken := ""
+		for _, line := range lines[6:] {
+			if line != "" && !strings.Contains(line, " ") {
+				resetToken = line
+			}
+		}
+
+		request, _ = http.NewRequest(http.MethodPost, "/auth/password/reset", strings.NewReader(`{"token": "`+resetToken+`", "password": "newpass"}`))
+		response = httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		tracker.AssertResponseStatus(t, http.StatusNoContent, response.Code)
+
+		request, _ = http.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(`{"username": "testuser", "password": "newpass"}`))
+		response = httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		tracker.AssertResponseStatus(t, http.StatusOK, response.Code)
+	})
+
 }
 
 func assertErrorMessage(t testing.TB, responseBody api.ErrorWriter, expectedError string) {
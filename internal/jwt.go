@@ -1,22 +1,34 @@
 package tracker
 
 import (
-	"os"
 	"time"
 
+	"github.com/Oriseer/workout_tracker/config"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var jwtKey = os.Getenv("JWT_KEY")
+// JwtGenerator issues a short-lived access token signed with the key of cfg. Clients renew it with the
+// refresh token handed out next to it. The random jti claim identifies the
+// token when it is revoked on logout, the role claim is checked by
+// middleware.RequireRole. The iat claim has the microsecond precision of
+// middleware.TokenTimePrecision.
+func JwtGenerator(cfg config.JWTConfig, userDetails LoginData) (string, error) {
+	jti, _, err := newOpaqueToken()
+	if err != nil {
+		return "", err
+	}
 
-func JwtGenerator(userDetails LoginData) (string, error) {
 	// Generate jwt token
+	now := time.Now()
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"username": userDetails.Username,
-		"exp":      time.Now().Add(time.Hour * 24).Unix(),
+		"role":     userDetails.Role,
+		"jti":      jti,
+		"iat":      float64(now.UnixMicro()) / 1e6,
+		"exp":      now.Add(cfg.AccessTTL).Unix(),
 	})
 
-	tokenString, err := token.SignedString([]byte(jwtKey))
+	tokenString, err := token.SignedString([]byte(cfg.Key))
 
 	if err != nil {
 		return "", err
@@ -0,0 +1,193 @@
+package tracker
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Oriseer/workout_tracker/api"
+	"github.com/Oriseer/workout_tracker/middleware"
+)
+
+// LoginFailures are the failed logins of a username since FirstFailureAt,
+// reset by a successful login, and the end of its current lockout, if any.
+type LoginFailures struct {
+	Username       string     `db:"username"`
+	Failures       int        `db:"failures"`
+	FirstFailureAt *time.Time `db:"first_failure_at"`
+	LockedUntil    *time.Time `db:"locked_until"`
+}
+
+// LoginFailureStore keeps the failed logins of usernames so that lockouts
+// survive restarts. Failures are also kept for usernames without an account,
+// otherwise lockouts would reveal which usernames exist.
+type LoginFailureStore interface {
+	// GetLoginFailures returns a zero LoginFailures for usernames without
+	// failures.
+	GetLoginFailures(username string) (LoginFailures, error)
+	// RecordLoginFailure counts a failed login at now and returns the
+	// failures of the username within window of its first failure. A failure
+	// after the window starts counting anew.
+	RecordLoginFailure(username string, now time.Time, window time.Duration) (int, error)
+	LockLogin(username string, until time.Time) error
+	// ResetLoginFailures forgets the failures and the lockout of a username.
+	ResetLoginFailures(username string) error
+	// PruneLoginFailures deletes the failures whose window ended before now
+	// and that lock nothing out anymore, and returns how many were deleted.
+	PruneLoginFailures(now time.Time, window time.Duration) (int64, error)
+}
+
+// loginLimiter accepts up to limit attempts per key in fixed windows. It
+// lives in memory, unlike lockouts a restart resets it.
+type loginLimiter struct {
+	limit  int
+	window time.Duration
+
+	mu        sync.Mutex
+	attempts  map[string]*loginWindow
+	lastSweep time.Time
+}
+
+type loginWindow struct {
+	start time.Time
+	count int
+}
+
+// newLoginLimiter returns nil, which accepts everything, for a limit of
+// zero.
+func newLoginLimiter(limit int, window time.Duration) *loginLimiter {
+	if limit == 0 {
+		return nil
+	}
+	return &loginLimiter{limit: limit, window: window, attempts: map[string]*loginWindow{}}
+}
+
+// allow counts an attempt of key at now. When key already used up its
+// attempts it returns false and how long until its window ends.
+func (l *loginLimiter) allow(key string, now time.Time) (time.Duration, bool) {
+	if l == nil {
+		return 0, true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Windows of keys that stopped trying are dropped once per window
+	if now.Sub(l.lastSweep) >= l.window {
+		for k, attempts := range l.attempts {
+			if now.Sub(attempts.start) >= l.window {
+				delete(l.attempts, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	attempts, ok := l.attempts[key]
+	if !ok || now.Sub(attempts.start) >= l.window {
+		attempts = &loginWindow{start: now}
+		l.attempts[key] = attempts
+	}
+	if attempts.count >= l.limit {
+		return attempts.start.Add(l.window).Sub(now), false
+	}
+	attempts.count++
+	return 0, true
+}
+
+// clientIP is the address the request came from. Headers such as
+// X-Forwarded-For are not trusted, clients could send any address in them.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// lockoutDuration is how long a username is locked out after its failures
+// reached the threshold, doubled for every failure after that.
+func (ws *WorkoutServer) lockoutDuration(failures int) time.Duration {
+	auth := ws.config.Auth
+	duration := auth.LockoutDuration
+	for i := auth.LockoutThreshold; i < failures && duration < auth.LockoutMaxDuration; i++ {
+		duration *= 2
+	}
+	return min(duration, auth.LockoutMaxDuration)
+}
+
+// checkLoginLimits answers with 429 Too Many Requests and returns false
+// when the client or the username made too many attempts or the username
+// is locked out.
+func (ws *WorkoutServer) checkLoginLimits(w http.ResponseWriter, r *http.Request, username string) bool {
+	now := time.Now()
+	wait, ok := ws.ipLimiter.allow(clientIP(r), now)
+	if ok {
+		wait, ok = ws.usernameLimiter.allow(username, now)
+	}
+	if !ok {
+		ws.metrics.logins.WithLabelValues(loginRateLimited).Inc()
+		tooManyLoginAttempts(w, wait)
+		return false
+	}
+
+	failures, err := ws.store.GetLoginFailures(username)
+	if err != nil {
+		api.DatabaseError(w, err)
+		return false
+	}
+	if failures.LockedUntil != nil && failures.LockedUntil.After(now) {
+		ws.metrics.logins.WithLabelValues(loginLockedOut).Inc()
+		tooManyLoginAttempts(w, failures.LockedUntil.Sub(now))
+		return false
+	}
+	return true
+}
+
+// recordLoginFailure counts a failed login of username and locks it out
+// once the failures reach the threshold. Errors are only logged, the client
+// gets the response of the failed login either way.
+func (ws *WorkoutServer) recordLoginFailure(r *http.Request, username string) {
+	threshold := ws.config.Auth.LockoutThreshold
+	if threshold == 0 {
+		return
+	}
+	failures, err := ws.store.RecordLoginFailure(username, time.Now(), ws.config.Auth.LockoutWindow)
+	if err == nil && failures >= threshold {
+		duration := ws.lockoutDuration(failures)
+		err = ws.store.LockLogin(username, time.Now().Add(duration))
+		middleware.Logger(r.Context()).Warn("login locked out", "locked_username", username, "failures", failures, "duration", duration)
+	}
+	if err != nil {
+		middleware.Logger(r.Context()).Error("recording failed login", "error", err)
+	}
+}
+
+// PruneLoginFailures removes the failures that ended their window every
+// interval until ctx is done. Failed logins of any username are recorded,
+// so without pruning the failures would grow without bound.
+func PruneLoginFailures(ctx context.Context, store LoginFailureStore, window, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := store.PruneLoginFailures(time.Now(), window); err != nil {
+			slog.Error("pruning login failures", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func tooManyLoginAttempts(w http.ResponseWriter, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(max(seconds, 1)))
+	api.TooManyRequestsError(w, api.ErrTooManyLoginAttempts)
+}
@@ -0,0 +1,107 @@
+package tracker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Oriseer/workout_tracker/config"
+)
+
+func TestLoginLimits(t *testing.T) {
+	newServer := func(store WorkoutPlanStore, change func(auth *config.AuthConfig)) testServer {
+		cfg := testConfig
+		cfg.Auth.LoginIPLimit = 0
+		cfg.Auth.LoginUsernameLimit = 0
+		cfg.Auth.LockoutThreshold = 0
+		change(&cfg.Auth)
+		return newTestServerWithConfig(store, cfg)
+	}
+	newStore := func() *InMemoryStore {
+		store := NewInMemoryStore()
+		store.AddUser(UserDetails{Username: "alice", Password: "testpass", Email: "alice@example.com"})
+		return store
+	}
+	assertRetryAfter := func(t *testing.T, response *httptest.ResponseRecorder, atLeast, atMost int) {
+		t.Helper()
+		AssertResponseStatus(t, http.StatusTooManyRequests, response.Code)
+		seconds, err := strconv.Atoi(response.Header().Get("Retry-After"))
+		if err != nil || seconds < atLeast || seconds > atMost {
+			t.Errorf("Expected Retry-After between %d and %d seconds, got %q", atLeast, atMost, response.Header().Get("Retry-After"))
+		}
+	}
+
+	t.Run("limits the logins of a client address", func(t *testing.T) {
+		server := newServer(newStore(), func(auth *config.AuthConfig) {
+			auth.LoginIPLimit = 2
+		})
+		AssertResponseStatus(t, http.StatusOK, server.from("192.0.2.1").tryLogin("alice", "testpass").Code)
+		AssertResponseStatus(t, http.StatusBadRequest, server.from("192.0.2.1").tryLogin("bob", "testpass").Code)
+		assertRetryAfter(t, server.from("192.0.2.1").tryLogin("alice", "testpass"), 1, 60)
+		AssertResponseStatus(t, http.StatusOK, server.from("192.0.2.2").tryLogin("alice", "testpass").Code)
+	})
+
+	t.Run("limits the logins of a username", func(t *testing.T) {
+		server := newServer(newStore(), func(auth *config.AuthConfig) {
+			auth.LoginUsernameLimit = 2
+		})
+		AssertResponseStatus(t, http.StatusBadRequest, server.from("192.0.2.1").tryLogin("alice", "wrong").Code)
+		AssertResponseStatus(t, http.StatusBadRequest, server.from("192.0.2.2").tryLogin("alice", "wrong").Code)
+		assertRetryAfter(t, server.from("192.0.2.3").tryLogin("alice", "testpass"), 1, 60)
+		AssertResponseStatus(t, http.StatusBadRequest, server.from("192.0.2.3").tryLogin("bob", "wrong").Code)
+	})
+
+	t.Run("locks usernames out for longer with every failure", func(t *testing.T) {
+		store := newStore()
+		lockout := func(auth *config.AuthConfig) {
+			auth.LockoutThreshold = 2
+			auth.LockoutDuration = time.Minute
+			auth.LockoutMaxDuration = 3 * time.Minute
+		}
+		server := newServer(store, lockout)
+		AssertResponseStatus(t, http.StatusBadRequest, server.from("192.0.2.1").tryLogin("alice", "wrong").Code)
+		AssertResponseStatus(t, http.StatusBadRequest, server.from("192.0.2.1").tryLogin("alice", "wrong").Code)
+		assertRetryAfter(t, server.from("192.0.2.1").tryLogin("alice", "testpass"), 59, 60)
+
+		// Failures after the lockout ended lock the username out again
+		store.LockLogin("alice", time.Now().Add(-time.Second))
+		AssertResponseStatus(t, http.StatusBadRequest, server.from("192.0.2.1").tryLogin("alice", "wrong").Code)
+		assertRetryAfter(t, server.from("192.0.2.1").tryLogin("alice", "testpass"), 119, 120)
+
+		// The lockout is kept by the store and survives a restart
+		server = newServer(store, lockout)
+		assertRetryAfter(t, server.from("192.0.2.1").tryLogin("alice", "testpass"), 119, 120)
+
+		store.LockLogin("alice", time.Now().Add(-time.Second))
+		AssertResponseStatus(t, http.StatusOK, server.from("192.0.2.1").tryLogin("alice", "testpass").Code)
+		if failures, _ := store.GetLoginFailures("alice"); failures.Failures != 0 {
+			t.Errorf("Expected a successful login to reset the failures, got %+v", failures)
+		}
+	})
+
+	t.Run("only counts failures within the lockout window", func(t *testing.T) {
+		store := newStore()
+		server := newServer(store, func(auth *config.AuthConfig) {
+			auth.LockoutThreshold = 2
+			auth.LockoutWindow = time.Hour
+		})
+		store.RecordLoginFailure("alice", time.Now().Add(-2*time.Hour), time.Hour)
+		AssertResponseStatus(t, http.StatusBadRequest, server.from("192.0.2.1").tryLogin("alice", "wrong").Code)
+		AssertResponseStatus(t, http.StatusOK, server.from("192.0.2.1").tryLogin("alice", "testpass").Code)
+	})
+
+	t.Run("caps the lockout", func(t *testing.T) {
+		server := newServer(newStore(), func(auth *config.AuthConfig) {
+			auth.LockoutThreshold = 5
+			auth.LockoutDuration = 15 * time.Minute
+			auth.LockoutMaxDuration = 24 * time.Hour
+		})
+		for failures, expected := range map[int]time.Duration{5: 15 * time.Minute, 6: 30 * time.Minute, 9: 4 * time.Hour, 12: 24 * time.Hour, 100: 24 * time.Hour} {
+			if got := server.lockoutDuration(failures); got != expected {
+				t.Errorf("Expected %d failures to lock out for %v, got %v", failures, expected, got)
+			}
+		}
+	})
+}
@@ -0,0 +1,97 @@
+package tracker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Oriseer/workout_tracker/api"
+	"github.com/Oriseer/workout_tracker/middleware"
+)
+
+// TokenRevocationStore keeps the denylist of access tokens that were revoked
+// before they expired. JwtAuth consults it through IsTokenRevoked.
+type TokenRevocationStore interface {
+	// RevokeToken denies the access token with the jti until it expires.
+	RevokeToken(jti string, expiresAt time.Time) error
+	// RevokeUserTokens denies every access token of the user issued before
+	// the given time, truncated to middleware.TokenTimePrecision, and revokes
+	// all of the user's refresh tokens.
+	RevokeUserTokens(username string, before time.Time) error
+	// RevokeUserRefreshToken revokes the family of the refresh token when it
+	// belongs to the user. Unknown tokens are ignored.
+	RevokeUserRefreshToken(username, tokenHash string) error
+	IsTokenRevoked(jti, username string, issuedAt time.Time) (bool, error)
+	// PruneRevokedTokens deletes revoked access tokens and refresh tokens that
+	// expired before now and returns how many were deleted.
+	PruneRevokedTokens(now time.Time) (int64, error)
+}
+
+// logoutHandler revokes the access token of the request and, when the body
+// carries one, the family of the client's refresh token.
+func (ws *WorkoutServer) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	claims, _ := middleware.Claims(r.Context())
+
+	request := RefreshRequest{}
+	body, _ := io.ReadAll(r.Body)
+	r.Body.Close()
+	if len(bytes.TrimSpace(body)) > 0 {
+		if err := json.Unmarshal(body, &request); err != nil {
+			api.RequestBodyError(w, err)
+			return
+		}
+	}
+
+	if err := ws.store.RevokeToken(claims.ID, claims.ExpiresAt); err != nil {
+		api.DatabaseError(w, err)
+		return
+	}
+	if request.RefreshToken != "" {
+		if err := ws.store.RevokeUserRefreshToken(claims.Username, hashToken(request.RefreshToken)); err != nil {
+			api.DatabaseError(w, err)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// logoutAllHandler signs the user out everywhere by revoking every access
+// and refresh token issued so far.
+func (ws *WorkoutServer) logoutAllHandler(w http.ResponseWriter, r *http.Request) {
+	claims, _ := middleware.Claims(r.Context())
+
+	if err := ws.store.RevokeUserTokens(claims.Username, time.Now()); err != nil {
+		api.DatabaseError(w, err)
+		return
+	}
+	// The token of this request may have been issued in the same microsecond
+	// as the cut-off, so it is revoked by its jti as well
+	if err := ws.store.RevokeToken(claims.ID, claims.ExpiresAt); err != nil {
+		api.DatabaseError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PruneRevokedTokens removes expired revocation entries every interval until
+// ctx is done. Entries are only needed while the token they deny is valid.
+func PruneRevokedTokens(ctx context.Context, store TokenRevocationStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := store.PruneRevokedTokens(time.Now()); err != nil {
+			slog.Error("pruning revoked tokens", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
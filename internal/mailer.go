@@ -0,0 +1,137 @@
+package tracker
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Oriseer/workout_tracker/api"
+	"github.com/Oriseer/workout_tracker/config"
+)
+
+// Mail is a plain text email.
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers mail to users, such as password reset links.
+type Mailer interface {
+	Send(mail Mail) error
+}
+
+// SMTPMailer sends mail through an SMTP server, authenticating with PLAIN
+// auth when Username is set.
+type SMTPMailer struct {
+	Addr     string
+	From     string
+	Username string
+	Password string
+}
+
+func (m SMTPMailer) Send(mail Mail) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		host, _, err := net.SplitHostPort(m.Addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+	return smtp.SendMail(m.Addr, auth, m.From, []string{mail.To}, formatMail(m.From, mail))
+}
+
+// FileMailer appends every mail to a file instead of sending it, for local
+// development and tests.
+type FileMailer struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+func (m *FileMailer) Send(mail Mail) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	file, err := os.OpenFile(m.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(formatMail("", mail), '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// LogMailer writes every mail, tokens included, to a logger instead of
+// sending it, for local development only.
+type LogMailer struct {
+	Logger *slog.Logger
+}
+
+func (m LogMailer) Send(mail Mail) error {
+	logger := m.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logger.Warn("mail logged instead of sent", "to", mail.To, "subject", mail.Subject, "body", mail.Body)
+	return nil
+}
+
+// NewMailer returns the mailer of the driver cfg selects, or nil when mail
+// is not delivered.
+func NewMailer(cfg config.MailConfig) Mailer {
+	switch cfg.Delivery() {
+	case config.MailSMTP:
+		return SMTPMailer{
+			Addr:     cfg.SMTPAddr,
+			From:     cfg.SMTPFrom,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+		}
+	case config.MailFile:
+		return &FileMailer{Path: cfg.File}
+	case config.MailLog:
+		return LogMailer{}
+	}
+	return nil
+}
+
+// requireMailer answers 503 Service Unavailable while no mailer is set, for
+// the endpoints of tokens that are only handed out by mail.
+func (ws *WorkoutServer) requireMailer(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if ws.mailer == nil {
+			api.ServiceUnavailableError(w, api.ErrMailNotConfigured)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// headerValue strips line breaks so user input cannot add mail headers.
+var headerValue = strings.NewReplacer("\r", "", "\n", "")
+
+// formatMail renders mail as an RFC 5322 message.
+func formatMail(from string, mail Mail) []byte {
+	var b strings.Builder
+	if from != "" {
+		fmt.Fprintf(&b, "From: %s\r\n", headerValue.Replace(from))
+	}
+	fmt.Fprintf(&b, "To: %s\r\n", headerValue.Replace(mail.To))
+	fmt.Fprintf(&b, "Subject: %s\r\n", headerValue.Replace(mail.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(mail.Body, "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
@@ -0,0 +1,62 @@
+package tracker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Oriseer/workout_tracker/config"
+)
+
+func TestNewMailer(t *testing.T) {
+	if mailer := NewMailer(config.MailConfig{}); mailer != nil {
+		t.Errorf("Expected no mailer without a driver, got %#v", mailer)
+	}
+	if _, ok := NewMailer(config.MailConfig{Driver: config.MailLog}).(LogMailer); !ok {
+		t.Error("Expected a LogMailer for the log driver")
+	}
+	if _, ok := NewMailer(config.MailConfig{File: "mail.log"}).(*FileMailer); !ok {
+		t.Error("Expected a FileMailer when a mail file is set")
+	}
+	if _, ok := NewMailer(config.MailConfig{SMTPAddr: "localhost:25", File: "mail.log"}).(SMTPMailer); !ok {
+		t.Error("Expected an SMTPMailer when an SMTP address is set")
+	}
+}
+
+func TestMailedTokensWithoutMailer(t *testing.T) {
+	server := NewWorkoutServer(&StubWorkoutPlanStore{emails: map[string]string{"alice": "alice@example.com"}}, testConfig)
+	for _, endpoint := range []struct{ method, path, body string }{
+		{http.MethodPost, "/auth/password/forgot", `{"email": "alice@example.com"}`},
+		{http.MethodPost, "/auth/password/reset", `{"token": "token", "password": "newpass"}`},
+		{http.MethodGet, "/auth/verify?token=token", ""},
+	} {
+		request, _ := http.NewRequest(endpoint.method, endpoint.path, strings.NewReader(endpoint.body))
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		AssertResponseStatus(t, http.StatusServiceUnavailable, response.Code)
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mail.log")
+	mailer := &FileMailer{Path: path}
+
+	mailer.Send(Mail{To: "alice@example.com", Subject: "First", Body: "one"})
+	mailer.Send(Mail{To: "bob@example.com\r\nBcc: eve@example.com", Subject: "Second", Body: "two"})
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"To: alice@example.com\r\n", "Subject: First\r\n", "one", "Subject: Second\r\n", "two"} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("Expected the mail file to contain %q, got %q", want, content)
+		}
+	}
+	if strings.Contains(string(content), "\r\nBcc:") {
+		t.Errorf("Expected line breaks to be stripped from headers, got %q", content)
+	}
+}
@@ -0,0 +1,74 @@
+package tracker
+
+import (
+	"net/http"
+
+	"github.com/Oriseer/workout_tracker/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metricsNamespace prefixes the metrics of the tracker itself.
+const metricsNamespace = "workout_tracker"
+
+// Results of a login attempt.
+const (
+	loginSuccess     = "success"
+	loginInvalid     = "invalid_credentials"
+	loginUnverified  = "email_not_verified"
+	loginRateLimited = "rate_limited"
+	loginLockedOut   = "locked_out"
+)
+
+// serverMetrics are the metrics served at /metrics. Every server has its own
+// registry so servers, for example those of tests, do not share counters.
+type serverMetrics struct {
+	registry        *prometheus.Registry
+	logins          *prometheus.CounterVec
+	usersRegistered prometheus.Counter
+	plansCreated    prometheus.Counter
+	sessionsLogged  prometheus.Counter
+	setsLogged      prometheus.Counter
+}
+
+// newServerMetrics registers the metrics of the tracker, the Go runtime and,
+// when store is a database, its connection pool.
+func newServerMetrics(store WorkoutPlanStore) *serverMetrics {
+	counter := func(name, help string) prometheus.Counter {
+		return prometheus.NewCounter(prometheus.CounterOpts{Namespace: metricsNamespace, Name: name, Help: help})
+	}
+	m := &serverMetrics{
+		registry: prometheus.NewRegistry(),
+		logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "logins_total",
+			Help:      "Login attempts by result: success, invalid_credentials, email_not_verified, rate_limited or locked_out.",
+		}, []string{"result"}),
+		usersRegistered: counter("users_registered_total", "Users registered."),
+		plansCreated:    counter("workout_plans_created_total", "Workout plans created."),
+		sessionsLogged:  counter("sessions_logged_total", "Workout sessions logged."),
+		setsLogged:      counter("sets_logged_total", "Sets logged, with sessions or one at a time."),
+	}
+	m.registry.MustRegister(
+		m.logins, m.usersRegistered, m.plansCreated, m.sessionsLogged, m.setsLogged,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	for _, result := range []string{loginSuccess, loginInvalid, loginUnverified, loginRateLimited, loginLockedOut} {
+		m.logins.WithLabelValues(result)
+	}
+	if db, ok := store.(*DB); ok {
+		m.registry.MustRegister(collectors.NewDBStatsCollector(db.DB.DB, db.DriverName()))
+	}
+	return m
+}
+
+// instrument counts and times the requests routed by router.
+func (m *serverMetrics) instrument(router *http.ServeMux) http.Handler {
+	return middleware.RequestMetrics(m.registry)(router)
+}
+
+func (m *serverMetrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,67 @@
+package tracker
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	store := NewInMemoryStore()
+	store.AddExercise(Exercise{Name: "pushup", Category: "strength"})
+	server := newTestServer(store)
+
+	server.send(http.MethodPost, "/auth/register", "", `{"username": "alice", "password": "testpass", "email": "alice@example.com"}`)
+	server.send(http.MethodPost, "/auth/login", "", `{"username": "alice", "password": "wrong"}`)
+	token := server.login(t, "alice")
+	AssertResponseStatus(t, http.StatusCreated, server.send(http.MethodPost, "/workout-plans/", token, `{"ExerciseName": "pushup", "Repetitions": 10, "Sets": 3}`).Code)
+	session := `{"started_at": "2025-06-02T07:00:00Z", "exercises": [{"exercise_name": "pushup", "sets": [{"reps": 10}, {"reps": 8}]}]}`
+	response := server.send(http.MethodPost, "/sessions", token, session)
+	AssertResponseStatus(t, http.StatusCreated, response.Code)
+	logged := WorkoutSession{}
+	json.NewDecoder(response.Body).Decode(&logged)
+	AssertResponseStatus(t, http.StatusCreated, server.send(http.MethodPost, fmt.Sprintf("/sessions/%d/sets", logged.Id), token, `{"exercise_name": "pushup", "reps": 6}`).Code)
+	server.send(http.MethodGet, "/exercises/1", token, "")
+	server.send(http.MethodGet, "/no-such-route", "", "")
+
+	response = server.send(http.MethodGet, "/metrics", "", "")
+	AssertResponseStatus(t, http.StatusOK, response.Code)
+	body, _ := io.ReadAll(response.Body)
+	metrics := string(body)
+
+	for _, expected := range []string{
+		`workout_tracker_logins_total{result="success"} 1`,
+		`workout_tracker_logins_total{result="invalid_credentials"} 1`,
+		`workout_tracker_logins_total{result="email_not_verified"} 0`,
+		`workout_tracker_users_registered_total 1`,
+		`workout_tracker_workout_plans_created_total 1`,
+		`workout_tracker_sessions_logged_total 1`,
+		`workout_tracker_sets_logged_total 3`,
+		`http_requests_total{method="POST",route="/auth/login",status="400"} 1`,
+		`http_requests_total{method="GET",route="/exercises/{id}",status="200"} 1`,
+		`http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`http_request_duration_seconds_count{method="POST",route="/sessions",status="201"} 1`,
+	} {
+		if !strings.Contains(metrics, expected) {
+			t.Errorf("Expected %s in the metrics", expected)
+		}
+	}
+}
+
+func TestDatabaseMetrics(t *testing.T) {
+	server := NewWorkoutServer(newSQLiteTestDatabase(t), testConfig)
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(response.Body)
+
+	for _, expected := range []string{`go_sql_open_connections{db_name="sqlite"}`, `go_sql_wait_count_total{db_name="sqlite"}`} {
+		if !strings.Contains(string(body), expected) {
+			t.Errorf("Expected %s in the metrics", expected)
+		}
+	}
+}
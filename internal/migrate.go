@@ -0,0 +1,279 @@
+package tracker
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+var (
+	ErrSchemaOutdated   = errors.New("database schema is out of date, run the migrate command")
+	ErrUnknownMigration = errors.New("unknown migration version")
+)
+
+// Migration is one versioned schema change. Migration files are named
+// <version>_<name>.up.sql and <version>_<name>.down.sql.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus reports whether a migration has been applied.
+type MigrationStatus struct {
+	Version   int        `db:"version"`
+	Name      string     `db:"name"`
+	AppliedAt *time.Time `db:"applied_at"`
+}
+
+// Migrator applies the embedded migrations and records the applied versions
+// in the SCHEMA_MIGRATIONS table.
+type Migrator struct {
+	db         *sqlx.DB
+	migrations []Migration
+}
+
+// LoadMigrations reads the migration files in the root of fsys, ordered by version.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		fileName := entry.Name()
+		if entry.IsDir() || path.Ext(fileName) != ".sql" {
+			continue
+		}
+
+		base := strings.TrimSuffix(fileName, ".sql")
+		direction := path.Ext(base)
+		if direction != ".up" && direction != ".down" {
+			return nil, fmt.Errorf("migration %s: missing .up or .down suffix", fileName)
+		}
+		base = strings.TrimSuffix(base, direction)
+
+		versionStr, name, found := strings.Cut(base, "_")
+		if !found {
+			return nil, fmt.Errorf("migration %s: missing name", fileName)
+		}
+		version, err := strconv.Atoi(versionStr)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: invalid version %q", fileName, versionStr)
+		}
+
+		contents, err := fs.ReadFile(fsys, fileName)
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		} else if migration.Name != name {
+			return nil, fmt.Errorf("migration %s: version %d is already used by %s", fileName, version, migration.Name)
+		}
+
+		if direction == ".up" {
+			migration.Up = string(contents)
+		} else {
+			migration.Down = string(contents)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s: both up and down files are required", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// NewMigrator returns a Migrator for the migrations embedded in the binary.
+func NewMigrator(db *sqlx.DB) (*Migrator, error) {
+	fsys, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db, migrations}, nil
+}
+
+// Latest returns the version of the newest known migration.
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// dialect adapts a schema statement written for Postgres to the database
+// the migrator runs on.
+func (m *Migrator) dialect(statement string) string {
+	if m.db.DriverName() == sqliteDriverName {
+		return sqliteSchema.Replace(statement)
+	}
+	return statement
+}
+
+func (m *Migrator) ensureTable() error {
+	_, err := m.db.Exec(m.dialect(`CREATE TABLE IF NOT EXISTS SCHEMA_MIGRATIONS (
+    version INTEGER PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    applied_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`))
+	return err
+}
+
+// Version returns the highest applied migration version, 0 if none.
+func (m *Migrator) Version() (int, error) {
+	if err := m.ensureTable(); err != nil {
+		return 0, err
+	}
+	var version int
+	err := m.db.Get(&version, "SELECT COALESCE(MAX(version), 0) FROM SCHEMA_MIGRATIONS")
+	if err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+// Status lists every known migration together with the time it was applied.
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	if err := m.ensureTable(); err != nil {
+		return nil, err
+	}
+	var applied []MigrationStatus
+	err := m.db.Select(&applied, "SELECT version, name, applied_at FROM SCHEMA_MIGRATIONS")
+	if err != nil {
+		return nil, err
+	}
+	appliedAt := map[int]*time.Time{}
+	for _, status := range applied {
+		appliedAt[status.Version] = status.AppliedAt
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		statuses = append(statuses, MigrationStatus{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			AppliedAt: appliedAt[migration.Version],
+		})
+	}
+	return statuses, nil
+}
+
+// Up applies every pending migration.
+func (m *Migrator) Up() error {
+	return m.To(m.Latest())
+}
+
+// Down reverts the most recently applied migration.
+func (m *Migrator) Down() error {
+	current, err := m.Version()
+	if err != nil {
+		return err
+	}
+	if current == 0 {
+		return nil
+	}
+	target := 0
+	for _, migration := range m.migrations {
+		if migration.Version < current {
+			target = migration.Version
+		}
+	}
+	return m.To(target)
+}
+
+// To migrates the schema up or down until version is the latest applied
+// migration. Version 0 reverts every migration.
+func (m *Migrator) To(version int) error {
+	if version != 0 && !m.known(version) {
+		return fmt.Errorf("%w: %d", ErrUnknownMigration, version)
+	}
+	current, err := m.Version()
+	if err != nil {
+		return err
+	}
+
+	if version >= current {
+		for _, migration := range m.migrations {
+			if migration.Version > current && migration.Version <= version {
+				if err := m.apply(migration, true); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if migration.Version <= current && migration.Version > version {
+			if err := m.apply(migration, false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) known(version int) bool {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// apply runs one migration and records it in a single transaction.
+func (m *Migrator) apply(migration Migration, up bool) error {
+	tx, err := m.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if up {
+		if _, err := tx.Exec(m.dialect(migration.Up)); err != nil {
+			return fmt.Errorf("migration %d_%s up: %w", migration.Version, migration.Name, err)
+		}
+		_, err = tx.Exec("INSERT INTO SCHEMA_MIGRATIONS (version, name) VALUES ($1, $2)", migration.Version, migration.Name)
+	} else {
+		if _, err := tx.Exec(m.dialect(migration.Down)); err != nil {
+			return fmt.Errorf("migration %d_%s down: %w", migration.Version, migration.Name, err)
+		}
+		_, err = tx.Exec("DELETE FROM SCHEMA_MIGRATIONS WHERE version = $1", migration.Version)
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,67 @@
+package tracker
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	t.Run("embedded migrations are ordered and complete", func(t *testing.T) {
+		migrator, err := NewMigrator(nil)
+		if err != nil {
+			t.Fatalf("Expected embedded migrations to load, got %v", err)
+		}
+
+		for i, migration := range migrator.migrations {
+			if migration.Version != i+1 {
+				t.Errorf("Expected migration version %d, got %d", i+1, migration.Version)
+			}
+		}
+		if migrator.Latest() != len(migrator.migrations) {
+			t.Errorf("Expected latest version %d, got %d", len(migrator.migrations), migrator.Latest())
+		}
+	})
+
+	t.Run("sorts migrations by version", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"10_second.up.sql":   {Data: []byte("SELECT 10")},
+			"10_second.down.sql": {Data: []byte("SELECT -10")},
+			"2_first.up.sql":     {Data: []byte("SELECT 2")},
+			"2_first.down.sql":   {Data: []byte("SELECT -2")},
+		}
+
+		migrations, err := LoadMigrations(fsys)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(migrations) != 2 || migrations[0].Name != "first" || migrations[1].Name != "second" {
+			t.Errorf("Expected migrations [first second], got %+v", migrations)
+		}
+		if migrations[1].Up != "SELECT 10" || migrations[1].Down != "SELECT -10" {
+			t.Errorf("Expected up and down SQL to be loaded, got %+v", migrations[1])
+		}
+	})
+
+	t.Run("rejects a migration without a down file", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"1_first.up.sql": {Data: []byte("SELECT 1")},
+		}
+
+		if _, err := LoadMigrations(fsys); err == nil {
+			t.Error("Expected an error for a missing down migration")
+		}
+	})
+
+	t.Run("rejects duplicate versions", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"1_first.up.sql":   {Data: []byte("SELECT 1")},
+			"1_first.down.sql": {Data: []byte("SELECT -1")},
+			"1_other.up.sql":   {Data: []byte("SELECT 1")},
+			"1_other.down.sql": {Data: []byte("SELECT -1")},
+		}
+
+		if _, err := LoadMigrations(fsys); err == nil {
+			t.Error("Expected an error for a duplicate version")
+		}
+	})
+}
@@ -0,0 +1,6 @@
+CREATE TABLE EXERCISES (
+    id SERIAL PRIMARY KEY,
+    exercise_name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    category VARCHAR(64) NOT NULL DEFAULT ''
+);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS WORKOUT_PLAN;
@@ -0,0 +1,10 @@
+CREATE TABLE WORKOUT_PLAN (
+    id SERIAL PRIMARY KEY,
+    owner VARCHAR(255) NOT NULL REFERENCES USERS (username) ON DELETE CASCADE,
+    exercise_name VARCHAR(255) NOT NULL,
+    repetitions INTEGER NOT NULL,
+    sets INTEGER NOT NULL,
+    weights INTEGER NOT NULL
+);
+
+CREATE INDEX workout_plan_owner_idx ON WORKOUT_PLAN (owner);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS workout_plan_exercise_idx;
+
+ALTER TABLE WORKOUT_PLAN DROP COLUMN exercise_id;
+
+DROP INDEX IF EXISTS exercises_name_idx;
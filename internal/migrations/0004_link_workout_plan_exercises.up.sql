@@ -0,0 +1,9 @@
+CREATE UNIQUE INDEX exercises_name_idx ON EXERCISES (LOWER(exercise_name));
+
+ALTER TABLE WORKOUT_PLAN ADD COLUMN exercise_id INTEGER REFERENCES EXERCISES (id);
+
+UPDATE WORKOUT_PLAN SET exercise_id = (
+    SELECT id FROM EXERCISES WHERE LOWER(EXERCISES.exercise_name) = LOWER(WORKOUT_PLAN.exercise_name)
+);
+
+CREATE INDEX workout_plan_exercise_idx ON WORKOUT_PLAN (exercise_id);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS SESSION_SETS;
+
+DROP TABLE IF EXISTS SESSION_EXERCISES;
+
+DROP TABLE IF EXISTS WORKOUT_SESSIONS;
@@ -0,0 +1,31 @@
+CREATE TABLE WORKOUT_SESSIONS (
+    id SERIAL PRIMARY KEY,
+    owner VARCHAR(255) NOT NULL REFERENCES USERS (username) ON DELETE CASCADE,
+    plan_id INTEGER REFERENCES WORKOUT_PLAN (id) ON DELETE SET NULL,
+    started_at TIMESTAMPTZ NOT NULL,
+    ended_at TIMESTAMPTZ,
+    notes TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX workout_sessions_owner_idx ON WORKOUT_SESSIONS (owner, started_at);
+
+CREATE TABLE SESSION_EXERCISES (
+    id SERIAL PRIMARY KEY,
+    session_id INTEGER NOT NULL REFERENCES WORKOUT_SESSIONS (id) ON DELETE CASCADE,
+    exercise_id INTEGER NOT NULL REFERENCES EXERCISES (id),
+    position INTEGER NOT NULL
+);
+
+CREATE INDEX session_exercises_session_idx ON SESSION_EXERCISES (session_id);
+
+CREATE TABLE SESSION_SETS (
+    id SERIAL PRIMARY KEY,
+    session_exercise_id INTEGER NOT NULL REFERENCES SESSION_EXERCISES (id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    reps INTEGER NOT NULL,
+    weight DOUBLE PRECISION NOT NULL DEFAULT 0,
+    rpe DOUBLE PRECISION,
+    notes TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX session_sets_exercise_idx ON SESSION_SETS (session_exercise_id);
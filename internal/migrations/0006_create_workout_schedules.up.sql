@@ -0,0 +1,10 @@
+CREATE TABLE WORKOUT_SCHEDULES (
+    id SERIAL PRIMARY KEY,
+    owner VARCHAR(255) NOT NULL REFERENCES USERS (username) ON DELETE CASCADE,
+    plan_id INTEGER NOT NULL REFERENCES WORKOUT_PLAN (id) ON DELETE CASCADE,
+    starts_at TIMESTAMPTZ NOT NULL,
+    time_zone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    rrule TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX workout_schedules_owner_idx ON WORKOUT_SCHEDULES (owner);
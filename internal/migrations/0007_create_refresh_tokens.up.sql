@@ -0,0 +1,12 @@
+CREATE TABLE REFRESH_TOKENS (
+    id SERIAL PRIMARY KEY,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    family_id VARCHAR(64) NOT NULL,
+    username VARCHAR(255) NOT NULL REFERENCES USERS (username) ON DELETE CASCADE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX refresh_tokens_family_idx ON REFRESH_TOKENS (family_id);
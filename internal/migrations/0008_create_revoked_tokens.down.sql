@@ -0,0 +1,2 @@
+ALTER TABLE USERS DROP COLUMN tokens_revoked_before;
+DROP TABLE IF EXISTS REVOKED_TOKENS;
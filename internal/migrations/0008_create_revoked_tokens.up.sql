@@ -0,0 +1,8 @@
+CREATE TABLE REVOKED_TOKENS (
+    jti VARCHAR(64) PRIMARY KEY,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX revoked_tokens_expires_idx ON REVOKED_TOKENS (expires_at);
+
+ALTER TABLE USERS ADD COLUMN tokens_revoked_before TIMESTAMPTZ;
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS PASSWORD_RESET_TOKENS;
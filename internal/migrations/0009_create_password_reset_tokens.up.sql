@@ -0,0 +1,8 @@
+CREATE TABLE PASSWORD_RESET_TOKENS (
+    id SERIAL PRIMARY KEY,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    username VARCHAR(255) NOT NULL REFERENCES USERS (username) ON DELETE CASCADE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    used_at TIMESTAMPTZ
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS EMAIL_VERIFICATION_TOKENS;
+ALTER TABLE USERS DROP COLUMN email_verified;
@@ -0,0 +1,13 @@
+ALTER TABLE USERS ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Accounts registered before verification existed stay usable
+UPDATE USERS SET email_verified = TRUE;
+
+CREATE TABLE EMAIL_VERIFICATION_TOKENS (
+    id SERIAL PRIMARY KEY,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    username VARCHAR(255) NOT NULL REFERENCES USERS (username) ON DELETE CASCADE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    used_at TIMESTAMPTZ
+);
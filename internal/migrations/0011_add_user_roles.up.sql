@@ -0,0 +1 @@
+ALTER TABLE USERS ADD COLUMN role VARCHAR(16) NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'coach', 'admin'));
@@ -0,0 +1,7 @@
+-- Failures are also counted for usernames that do not exist, so there is no
+-- reference to USERS
+CREATE TABLE LOGIN_FAILURES (
+    username VARCHAR(255) PRIMARY KEY,
+    failures INTEGER NOT NULL,
+    locked_until TIMESTAMPTZ
+);
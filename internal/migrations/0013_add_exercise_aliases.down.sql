@@ -0,0 +1 @@
+DROP TABLE IF EXISTS EXERCISE_ALIASES;
@@ -0,0 +1,7 @@
+-- Aliases are stored lower case, so they are unique ignoring case
+CREATE TABLE EXERCISE_ALIASES (
+    owner VARCHAR(255) NOT NULL REFERENCES USERS (username) ON DELETE CASCADE,
+    alias VARCHAR(255) NOT NULL,
+    exercise_id INTEGER NOT NULL REFERENCES EXERCISES (id) ON DELETE CASCADE,
+    PRIMARY KEY (owner, alias)
+);
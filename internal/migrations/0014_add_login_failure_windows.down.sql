@@ -0,0 +1 @@
+ALTER TABLE LOGIN_FAILURES DROP COLUMN first_failure_at;
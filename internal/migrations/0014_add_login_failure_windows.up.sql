@@ -0,0 +1,3 @@
+-- Failures are only counted within a window from the first one, rows from
+-- before have none and start a new window with their next failure
+ALTER TABLE LOGIN_FAILURES ADD COLUMN first_failure_at TIMESTAMPTZ;
@@ -0,0 +1,354 @@
+package tracker
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Oriseer/workout_tracker/api"
+	"github.com/Oriseer/workout_tracker/middleware"
+)
+
+//go:embed docs.html
+var docsPage []byte
+
+// apiOperation documents one operation of the API. Request and Response are
+// zero values of the JSON bodies, nil for operations without one; their
+// schemas are generated from the Go types so they cannot drift apart.
+type apiOperation struct {
+	Method  string
+	Path    string
+	Summary string
+	// Auth is set for operations that need an access token, Role for those
+	// that need a role besides.
+	Auth    bool
+	Role    string
+	Query   []apiParameter
+	Request any
+	// OptionalRequest is set when the request body may be left out.
+	OptionalRequest bool
+	Status          int
+	Response        any
+	MediaType       string
+	Errors          []int
+}
+
+// apiParameter is a query parameter. Path parameters are taken from the
+// path.
+type apiParameter struct {
+	Name        string
+	Type        string
+	Description string
+}
+
+var (
+	fromParameter = apiParameter{"from", "string", "RFC 3339 timestamp or YYYY-MM-DD date"}
+	toParameter   = apiParameter{"to", "string", "RFC 3339 timestamp or YYYY-MM-DD date"}
+	progressQuery = []apiParameter{fromParameter, toParameter,
+		{"exercise_id", "string", "exercise id, may be repeated or hold a comma separated list"},
+	}
+)
+
+// apiOperations are the operations routed by NewWorkoutServer.
+var apiOperations = []apiOperation{
+	{Method: "POST", Path: "/workout-plans/", Summary: "Create a workout plan", Auth: true, Request: WorkoutPlan{}, Status: http.StatusCreated, Errors: []int{400}},
+	{Method: "PUT", Path: "/workout-plans/", Summary: "Update the workout plan of the exercise", Auth: true, Request: WorkoutPlan{}, Status: http.StatusNoContent, Errors: []int{404}},
+	{Method: "DELETE", Path: "/workout-plans/{name}", Summary: "Delete the workout plan of the exercise", Auth: true, Status: http.StatusNoContent, Errors: []int{404}},
+	{Method: "GET", Path: "/workouts", Summary: "List workout plans a page at a time", Auth: true, Query: []apiParameter{
+		{"exercise_name", "string", "case-insensitive substring of the exercise name"},
+		{"min_weight", "integer", ""}, {"max_weight", "integer", ""},
+		{"min_reps", "integer", ""}, {"max_reps", "integer", ""},
+		{"min_sets", "integer", ""}, {"max_sets", "integer", ""},
+		{"sort", "string", "id, exercise_name, weight, reps or sets"},
+		{"order", "string", "asc or desc"},
+		{"limit", "integer", "page size between 1 and 200"},
+		{"cursor", "string", "next_cursor of the previous page"},
+	}, Status: http.StatusOK, Response: WorkoutPlanPage{}, Errors: []int{400}},
+
+	{Method: "GET", Path: "/exercises", Summary: "List the exercise catalog", Auth: true, Query: []apiParameter{
+		{"category", "string", "exact category"},
+		{"name", "string", "case-insensitive substring of the name"},
+	}, Status: http.StatusOK, Response: []Exercise{}},
+	{Method: "GET", Path: "/exercises/{id}", Summary: "Get an exercise", Auth: true, Status: http.StatusOK, Response: Exercise{}, Errors: []int{400, 404}},
+	{Method: "POST", Path: "/exercises", Summary: "Add an exercise to the catalog", Auth: true, Role: middleware.RoleAdmin, Request: Exercise{}, Status: http.StatusCreated, Response: Exercise{}, Errors: []int{400}},
+	{Method: "PUT", Path: "/exercises/{id}", Summary: "Update an exercise", Auth: true, Role: middleware.RoleAdmin, Request: Exercise{}, Status: http.StatusNoContent, Errors: []int{400, 404}},
+	{Method: "DELETE", Path: "/exercises/{id}", Summary: "Delete an exercise no plan or session uses", Auth: true, Role: middleware.RoleAdmin, Status: http.StatusNoContent, Errors: []int{400, 404}},
+
+	{Method: "GET", Path: "/sessions", Summary: "List workout sessions", Auth: true, Status: http.StatusOK, Response: []WorkoutSession{}},
+	{Method: "POST", Path: "/sessions", Summary: "Log a workout session", Auth: true, Request: WorkoutSession{}, Status: http.StatusCreated, Response: WorkoutSession{}, Errors: []int{400}},
+	{Method: "GET", Path: "/sessions/{id}", Summary: "Get a workout session with the plan it followed", Auth: true, Status: http.StatusOK, Response: WorkoutSession{}, Errors: []int{400, 404}},
+	{Method: "PUT", Path: "/sessions/{id}", Summary: "Replace a workout session", Auth: true, Request: WorkoutSession{}, Status: http.StatusNoContent, Errors: []int{400, 404}},
+	{Method: "DELETE", Path: "/sessions/{id}", Summary: "Delete a workout session", Auth: true, Status: http.StatusNoContent, Errors: []int{400, 404}},
+	{Method: "POST", Path: "/sessions/{id}/sets", Summary: "Log one more set", Auth: true, Request: LoggedSetInput{}, Status: http.StatusCreated, Response: LoggedSet{}, Errors: []int{400, 404}},
+
+	{Method: "GET", Path: "/schedules", Summary: "List workout schedules", Auth: true, Status: http.StatusOK, Response: []WorkoutSchedule{}},
+	{Method: "POST", Path: "/schedules", Summary: "Schedule a workout plan", Auth: true, Request: WorkoutSchedule{}, Status: http.StatusCreated, Response: WorkoutSchedule{}, Errors: []int{400}},
+	{Method: "PUT", Path: "/schedules/{id}", Summary: "Replace a workout schedule", Auth: true, Request: WorkoutSchedule{}, Status: http.StatusNoContent, Errors: []int{400, 404}},
+	{Method: "DELETE", Path: "/schedules/{id}", Summary: "Delete a workout schedule", Auth: true, Status: http.StatusNoContent, Errors: []int{400, 404}},
+	{Method: "GET", Path: "/schedule", Summary: "Expand the schedules into occurrences", Auth: true, Query: []apiParameter{fromParameter, toParameter,
+		{"status", "string", "pending or completed"},
+	}, Status: http.StatusOK, Response: []ScheduledOccurrence{}, Errors: []int{400}},
+
+	{Method: "GET", Path: "/reports/progress", Summary: "Progress per exercise", Auth: true, Query: progressQuery, Status: http.StatusOK, Response: []ExerciseProgress{}, Errors: []int{400}},
+	{Method: "GET", Path: "/reports/progress/weekly", Summary: "Progress per exercise and week", Auth: true, Query: progressQuery, Status: http.StatusOK, Response: []WeeklyProgress{}, Errors: []int{400}},
+
+	{Method: "GET", Path: "/export", Summary: "Export all workout plans and sessions as JSON or CSV", Auth: true, Query: []apiParameter{
+		{"format", "string", "json (default) or csv"},
+	}, Status: http.StatusOK, Response: WorkoutData{}, Errors: []int{400}},
+	{Method: "POST", Path: "/import", Summary: "Import workout plans and sessions all at once, as JSON, as CSV with the columns of the CSV export or as a Strong or Hevy CSV export", Auth: true, Query: []apiParameter{
+		{"format", "string", "json, csv, strong or hevy, defaults to csv for a text/csv body and json otherwise"},
+		{"dry_run", "boolean", "validate without importing"},
+		{"unit", "string", "kg (default) or lb, the unit strong and hevy weights are imported in"},
+		{"source_unit", "string", "kg or lb, the unit of Strong exports without a Weight Unit column, defaults to unit"},
+		{"time_zone", "string", "IANA time zone of the local times of strong and hevy exports, defaults to UTC"},
+		{"skip_unmapped", "boolean", "import strong and hevy exports without the sets of exercises that are not in the catalog"},
+	}, Request: WorkoutData{}, Status: http.StatusCreated, Response: ImportResult{}, Errors: []int{400}},
+	{Method: "GET", Path: "/exercise-aliases", Summary: "List the exercise aliases Strong and Hevy imports are mapped with", Auth: true, Status: http.StatusOK, Response: []ExerciseAlias{}},
+	{Method: "PUT", Path: "/exercise-aliases/{alias}", Summary: "Map a name onto a catalog exercise by exercise_id or exercise_name", Auth: true, Request: ExerciseAlias{}, Status: http.StatusOK, Response: ExerciseAlias{}, Errors: []int{400}},
+	{Method: "DELETE", Path: "/exercise-aliases/{alias}", Summary: "Delete an exercise alias", Auth: true, Status: http.StatusNoContent, Errors: []int{404}},
+
+	{Method: "GET", Path: "/users", Summary: "List users", Auth: true, Role: middleware.RoleAdmin, Status: http.StatusOK, Response: []UserAccount{}},
+	{Method: "PUT", Path: "/users/{username}/role", Summary: "Change the role of a user", Auth: true, Role: middleware.RoleAdmin, Request: RoleRequest{}, Status: http.StatusNoContent, Errors: []int{400, 404}},
+
+	{Method: "POST", Path: "/auth/register", Summary: "Register a user", Request: UserDetails{}, Status: http.StatusCreated, Errors: []int{400}},
+	{Method: "POST", Path: "/auth/login", Summary: "Log in", Request: LoginData{}, Status: http.StatusOK, Response: Token{}, Errors: []int{400, 403, 429}},
+	{Method: "POST", Path: "/auth/refresh", Summary: "Exchange a refresh token for new tokens", Request: RefreshRequest{}, Status: http.StatusOK, Response: Token{}, Errors: []int{400, 401}},
+	{Method: "POST", Path: "/auth/logout", Summary: "Revoke the access token and optionally the refresh token", Auth: true, Request: RefreshRequest{}, OptionalRequest: true, Status: http.StatusNoContent},
+	{Method: "POST", Path: "/auth/logout-all", Summary: "Sign out of every device", Auth: true, Status: http.StatusNoContent},
+	{Method: "POST", Path: "/auth/password/forgot", Summary: "Mail a password reset token", Request: ForgotPasswordRequest{}, Status: http.StatusAccepted, Errors: []int{400, 503}},
+	{Method: "POST", Path: "/auth/password/reset", Summary: "Set a new password with a reset token", Request: ResetPasswordRequest{}, Status: http.StatusNoContent, Errors: []int{400, 503}},
+	{Method: "GET", Path: "/auth/verify", Summary: "Verify an email address", Query: []apiParameter{
+		{"token", "string", "token of the verification mail"},
+	}, Status: http.StatusOK, Response: EmailVerification{}, Errors: []int{400, 503}},
+
+	{Method: "GET", Path: "/metrics", Summary: "Prometheus metrics", Status: http.StatusOK, Response: "", MediaType: "text/plain"},
+	{Method: "GET", Path: "/openapi.json", Summary: "This document", Status: http.StatusOK, Response: map[string]any{}},
+	{Method: "GET", Path: "/docs", Summary: "Documentation page of this document", Status: http.StatusOK, Response: "", MediaType: "text/html"},
+}
+
+var (
+	openAPIOnce     sync.Once
+	openAPIDocument []byte
+)
+
+// openAPIHandler serves the OpenAPI 3 document of apiOperations.
+func openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	openAPIOnce.Do(func() {
+		document, err := json.Marshal(newOpenAPIDocument(apiOperations))
+		if err != nil {
+			panic(err)
+		}
+		openAPIDocument = document
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPIDocument)
+}
+
+func docsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(docsPage)
+}
+
+var pathParameter = regexp.MustCompile(`\{(\w+)\}`)
+
+// newOpenAPIDocument describes operations as an OpenAPI 3 document.
+func newOpenAPIDocument(operations []apiOperation) map[string]any {
+	schemas := schemaGenerator{components: map[string]any{}}
+	errorResponse := func(status int) map[string]any {
+		return map[string]any{
+			"description": http.StatusText(status),
+			"content": map[string]any{
+				"application/json": map[string]any{"schema": schemas.schema(reflect.TypeOf(api.ErrorWriter{}))},
+			},
+		}
+	}
+
+	paths := map[string]any{}
+	for _, op := range operations {
+		operation := map[string]any{
+			"summary":     op.Summary,
+			"operationId": operationId(op),
+		}
+
+		parameters := []any{}
+		for _, match := range pathParameter.FindAllStringSubmatch(op.Path, -1) {
+			parameters = append(parameters, map[string]any{
+				"name": match[1], "in": "path", "required": true, "schema": map[string]any{"type": "string"},
+			})
+		}
+		for _, query := range op.Query {
+			parameter := map[string]any{"name": query.Name, "in": "query", "schema": map[string]any{"type": query.Type}}
+			if query.Description != "" {
+				parameter["description"] = query.Description
+			}
+			parameters = append(parameters, parameter)
+		}
+		if len(parameters) > 0 {
+			operation["parameters"] = parameters
+		}
+
+		if op.Request != nil {
+			operation["requestBody"] = map[string]any{
+				"required": !op.OptionalRequest,
+				"content": map[string]any{
+					"application/json": map[string]any{"schema": schemas.schema(reflect.TypeOf(op.Request))},
+				},
+			}
+		}
+
+		success := map[string]any{"description": http.StatusText(op.Status)}
+		if op.Response != nil {
+			mediaType := op.MediaType
+			if mediaType == "" {
+				mediaType = "application/json"
+			}
+			success["content"] = map[string]any{
+				mediaType: map[string]any{"schema": schemas.schema(reflect.TypeOf(op.Response))},
+			}
+		}
+		responses := map[string]any{fmt.Sprint(op.Status): success}
+		errors := op.Errors
+		if op.Auth {
+			// JwtAuth rejects missing or malformed authorization headers and
+			// invalid, expired or revoked tokens with 401
+			errors = append(errors, http.StatusUnauthorized)
+			operation["security"] = []any{map[string]any{"bearerAuth": []any{}}}
+		}
+		if op.Role != "" {
+			errors = append(errors, http.StatusForbidden)
+			operation["description"] = "Requires the " + op.Role + " role."
+		}
+		for _, status := range errors {
+			responses[fmt.Sprint(status)] = errorResponse(status)
+		}
+		operation["responses"] = responses
+
+		item, ok := paths[op.Path].(map[string]any)
+		if !ok {
+			item = map[string]any{}
+			paths[op.Path] = item
+		}
+		item[strings.ToLower(op.Method)] = operation
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "Workout Tracker API",
+			"version":     "1.0.0",
+			"description": "Create, schedule and log workouts and report on the progress.",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas.components,
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+			},
+		},
+	}
+}
+
+// operationId names an operation after its method and path, for example
+// getSessionsId for GET /sessions/{id}.
+func operationId(op apiOperation) string {
+	var id strings.Builder
+	id.WriteString(strings.ToLower(op.Method))
+	for _, word := range strings.FieldsFunc(op.Path, func(r rune) bool {
+		return r == '/' || r == '{' || r == '}' || r == '-' || r == '.' || r == '_'
+	}) {
+		id.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	return id.String()
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaGenerator derives JSON schemas from Go types the way encoding/json
+// marshals them. Named structs become components referenced by name.
+type schemaGenerator struct {
+	components map[string]any
+}
+
+func (g schemaGenerator) schema(t reflect.Type) map[string]any {
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.schema(t.Elem())
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Interface:
+		return map[string]any{}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		if _, ok := g.components[t.Name()]; !ok {
+			// Reserve the name first in case the type refers to itself
+			g.components[t.Name()] = nil
+			g.components[t.Name()] = g.object(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + t.Name()}
+	}
+	panic("openapi: no schema for " + t.String())
+}
+
+func (g schemaGenerator) object(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	g.addProperties(t, properties)
+	return map[string]any{"type": "object", "properties": properties}
+}
+
+// addProperties adds the JSON fields of t, including those of embedded
+// structs, to properties.
+func (g schemaGenerator) addProperties(t reflect.Type, properties map[string]any) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			g.addProperties(field.Type, properties)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = g.schema(field.Type)
+	}
+}
+
+// routeMux is an http.ServeMux that remembers its patterns, so that the
+// routes can be checked against apiOperations.
+type routeMux struct {
+	*http.ServeMux
+	patterns []string
+}
+
+func (m *routeMux) Handle(pattern string, handler http.Handler) {
+	m.ServeMux.Handle(pattern, handler)
+	m.patterns = append(m.patterns, pattern)
+}
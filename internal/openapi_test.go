@@ -0,0 +1,102 @@
+package tracker
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	server := NewWorkoutServer(NewInMemoryStore(), testConfig)
+
+	documented := map[string]bool{}
+	served := map[string]bool{}
+	for _, op := range apiOperations {
+		route := op.Method + " " + op.Path
+		if documented[route] {
+			t.Errorf("Expected %s to be documented once", route)
+		}
+		documented[route] = true
+
+		request := httptest.NewRequest(op.Method, pathParameter.ReplaceAllString(op.Path, "1"), nil)
+		if _, pattern := server.router.Handler(request); pattern == "" {
+			t.Errorf("Expected a route for %s, which the spec documents", route)
+		} else {
+			served[pattern] = true
+		}
+	}
+
+	for _, pattern := range server.router.patterns {
+		if !served[pattern] {
+			t.Errorf("Expected the route %s to be documented in the spec", pattern)
+		}
+	}
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	server := newTestServer(NewInMemoryStore())
+
+	response := server.send(http.MethodGet, "/openapi.json", "", "")
+	AssertResponseStatus(t, http.StatusOK, response.Code)
+	body := response.Body.String()
+	var document struct {
+		OpenAPI    string                    `json:"openapi"`
+		Paths      map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]any `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal([]byte(body), &document); err != nil {
+		t.Fatalf("Expected a JSON document, got %v", err)
+	}
+	if !strings.HasPrefix(document.OpenAPI, "3.") {
+		t.Errorf("Expected an OpenAPI 3 document, got %q", document.OpenAPI)
+	}
+
+	t.Run("describes the bodies", func(t *testing.T) {
+		for schema, properties := range map[string][]string{
+			"WorkoutPlan":    {"ExerciseName", "Repetitions", "Sets", "Weight"},
+			"UserDetails":    {"username", "password", "email"},
+			"Token":          {"token", "refresh_token", "expires_in"},
+			"ErrorWriter":    {"ErrorMessage", "Code"},
+			"LoggedSetInput": {"exercise_id", "reps", "weight"},
+		} {
+			for _, property := range properties {
+				if _, ok := document.Components.Schemas[schema].Properties[property]; !ok {
+					t.Errorf("Expected %s to have the property %s", schema, property)
+				}
+			}
+		}
+		if _, ok := document.Components.Schemas["LoginData"].Properties["Role"]; ok {
+			t.Error("Expected fields left out of JSON to be left out of the schema")
+		}
+	})
+
+	t.Run("refers only to described schemas", func(t *testing.T) {
+		for _, ref := range strings.Split(body, `"$ref":"#/components/schemas/`)[1:] {
+			name, _, _ := strings.Cut(ref, `"`)
+			if _, ok := document.Components.Schemas[name]; !ok {
+				t.Errorf("Expected the schema %s to be described", name)
+			}
+		}
+	})
+
+	t.Run("takes the name of plans to delete from the path", func(t *testing.T) {
+		operation, _ := document.Paths["/workout-plans/{name}"]["delete"].(map[string]any)
+		if _, ok := operation["requestBody"]; ok || operation == nil {
+			t.Errorf("Expected DELETE /workout-plans/{name} without a body, got %v", operation)
+		}
+	})
+
+	t.Run("serves a docs page", func(t *testing.T) {
+		response := server.send(http.MethodGet, "/docs", "", "")
+		AssertResponseStatus(t, http.StatusOK, response.Code)
+		if !strings.Contains(response.Body.String(), "/openapi.json") {
+			t.Error("Expected the docs page to load /openapi.json")
+		}
+	})
+}
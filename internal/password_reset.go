@@ -0,0 +1,136 @@
+package tracker
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Oriseer/workout_tracker/api"
+	"github.com/Oriseer/workout_tracker/middleware"
+)
+
+// PasswordResetToken is the server side record of a reset token mailed to a
+// user. Like refresh tokens only the hash is stored, and a token can be
+// used once before it expires.
+type PasswordResetToken struct {
+	TokenHash string     `db:"token_hash"`
+	Username  string     `db:"username"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	UsedAt    *time.Time `db:"used_at"`
+}
+
+// PasswordResetStore persists password reset tokens.
+type PasswordResetStore interface {
+	// GetUsersByEmail returns the username and email of every user
+	// registered with the email.
+	GetUsersByEmail(email string) ([]UserDetails, error)
+	AddPasswordResetToken(token PasswordResetToken) error
+	// ResetPassword marks the token used and sets the password of its user,
+	// returning the username. Unknown, used and expired tokens yield
+	// api.ErrInvalidResetToken.
+	ResetPassword(tokenHash, password string, now time.Time) (string, error)
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// forgotPasswordHandler mails a reset token to every user with the email.
+// It answers 202 Accepted whether or not the email is known, so the
+// endpoint cannot be used to find registered addresses.
+func (ws *WorkoutServer) forgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	request := ForgotPasswordRequest{}
+	if err := ws.jsonDecode(r, &request); err != nil {
+		api.RequestBodyError(w, err)
+		return
+	}
+	if request.Email == "" {
+		api.StatusBadRequestServerError(w, api.ErrInvalidUserDetails)
+		return
+	}
+
+	users, err := ws.store.GetUsersByEmail(request.Email)
+	if err != nil {
+		api.DatabaseError(w, err)
+		return
+	}
+	for _, user := range users {
+		token, tokenHash, err := newOpaqueToken()
+		if err != nil {
+			api.InternalServerError(w, err)
+			return
+		}
+		err = ws.store.AddPasswordResetToken(PasswordResetToken{
+			TokenHash: tokenHash,
+			Username:  user.Username,
+			ExpiresAt: time.Now().Add(ws.config.Auth.PasswordResetTTL),
+		})
+		if err != nil {
+			api.DatabaseError(w, err)
+			return
+		}
+
+		// A failed delivery is only logged, the response must not differ
+		// from the one for an unknown email.
+		if err := ws.mailer.Send(ws.passwordResetMail(user, token)); err != nil {
+			middleware.Logger(r.Context()).Error("sending password reset mail", "to", user.Username, "error", err)
+		}
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// resetPasswordHandler sets a new password with a mailed reset token, signs
+// the user out of every device and lifts a lockout.
+func (ws *WorkoutServer) resetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	request := ResetPasswordRequest{}
+	if err := ws.jsonDecode(r, &request); err != nil {
+		api.RequestBodyError(w, err)
+		return
+	}
+	if request.Token == "" {
+		api.StatusBadRequestServerError(w, api.ErrInvalidResetToken)
+		return
+	}
+	if request.Password == "" {
+		api.StatusBadRequestServerError(w, api.ErrInvalidUserDetails)
+		return
+	}
+
+	now := time.Now()
+	username, err := ws.store.ResetPassword(hashToken(request.Token), request.Password, now)
+	if err == api.ErrInvalidResetToken {
+		api.StatusBadRequestServerError(w, err)
+		return
+	} else if err != nil {
+		api.DatabaseError(w, err)
+		return
+	}
+
+	if err := ws.store.RevokeUserTokens(username, now); err != nil {
+		api.DatabaseError(w, err)
+		return
+	}
+	// The new password ends a lockout from guesses at the old one
+	if err := ws.store.ResetLoginFailures(username); err != nil {
+		api.DatabaseError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (ws *WorkoutServer) passwordResetMail(user UserDetails, token string) Mail {
+	return Mail{
+		To:      user.Email,
+		Subject: "Reset your Workout Tracker password",
+		Body: fmt.Sprintf("Hello %s,\n\n"+
+			"Use the token below with POST /auth/password/reset to choose a new password. "+
+			"It expires in %s and can only be used once.\n\n%s\n\n"+
+			"If you did not ask to reset your password you can ignore this mail.\n",
+			user.Username, ws.config.Auth.PasswordResetTTL, token),
+	}
+}
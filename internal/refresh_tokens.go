@@ -0,0 +1,134 @@
+package tracker
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Oriseer/workout_tracker/api"
+)
+
+// RefreshToken is the server side record of an opaque refresh token. Only
+// the SHA-256 hash of the token is stored. Every rotation issues a new token
+// in the same family, so a reused token identifies the family to revoke.
+type RefreshToken struct {
+	TokenHash string     `db:"token_hash"`
+	FamilyId  string     `db:"family_id"`
+	Username  string     `db:"username"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	UsedAt    *time.Time `db:"used_at"`
+	RevokedAt *time.Time `db:"revoked_at"`
+}
+
+// RefreshTokenStore persists refresh tokens.
+type RefreshTokenStore interface {
+	AddRefreshToken(token RefreshToken) error
+	// UseRefreshToken marks the token as used and returns it. A token that was
+	// used before is returned together with api.ErrRefreshTokenReused, an
+	// unknown token yields api.ErrInvalidRefreshToken.
+	UseRefreshToken(tokenHash string) (RefreshToken, error)
+	RevokeRefreshTokenFamily(familyId string) error
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// newOpaqueToken returns a random URL safe token and the hash to store for it.
+func newOpaqueToken() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, hashToken(token), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// issueTokens writes a new access token and a refresh token in familyId,
+// starting a new family when familyId is empty. The role is read on every
+// refresh, so role changes apply to the next access token.
+func (ws *WorkoutServer) issueTokens(w http.ResponseWriter, username, familyId string) {
+	role, err := ws.store.GetUserRole(username)
+	if err != nil {
+		api.DatabaseError(w, err)
+		return
+	}
+	accessToken, err := JwtGenerator(ws.config.JWT, LoginData{Username: username, Role: role})
+	if err != nil {
+		api.InternalServerError(w, api.ErrJWTToken)
+		return
+	}
+
+	refreshToken, tokenHash, err := newOpaqueToken()
+	if err != nil {
+		api.InternalServerError(w, api.ErrJWTToken)
+		return
+	}
+	if familyId == "" {
+		if familyId, _, err = newOpaqueToken(); err != nil {
+			api.InternalServerError(w, api.ErrJWTToken)
+			return
+		}
+	}
+
+	err = ws.store.AddRefreshToken(RefreshToken{
+		TokenHash: tokenHash,
+		FamilyId:  familyId,
+		Username:  username,
+		ExpiresAt: time.Now().Add(ws.config.JWT.RefreshTTL),
+	})
+	if err != nil {
+		api.DatabaseError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(Token{
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(ws.config.JWT.AccessTTL.Seconds()),
+	})
+}
+
+// refreshTokenHandler rotates a refresh token. Presenting a token that was
+// already rotated revokes its whole family, since either the client or an
+// attacker holds a stolen copy.
+func (ws *WorkoutServer) refreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	request := RefreshRequest{}
+	if err := ws.jsonDecode(r, &request); err != nil || request.RefreshToken == "" {
+		api.StatusBadRequestServerError(w, api.ErrInvalidRefreshToken)
+		return
+	}
+
+	token, err := ws.store.UseRefreshToken(hashToken(request.RefreshToken))
+	if err == api.ErrRefreshTokenReused {
+		if err := ws.store.RevokeRefreshTokenFamily(token.FamilyId); err != nil {
+			api.DatabaseError(w, err)
+			return
+		}
+		api.UnauthorizedError(w, err)
+		return
+	} else if err == api.ErrInvalidRefreshToken {
+		api.UnauthorizedError(w, err)
+		return
+	} else if err != nil {
+		api.DatabaseError(w, err)
+		return
+	}
+
+	if token.RevokedAt != nil || time.Now().After(token.ExpiresAt) {
+		api.UnauthorizedError(w, api.ErrInvalidRefreshToken)
+		return
+	}
+
+	ws.issueTokens(w, token.Username, token.FamilyId)
+}
@@ -0,0 +1,225 @@
+package tracker
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Oriseer/workout_tracker/api"
+	"github.com/Oriseer/workout_tracker/middleware"
+)
+
+// ProgressFilter limits progress reports to sessions started in [From, To)
+// and to the given exercises. Zero values do not filter.
+type ProgressFilter struct {
+	From        time.Time
+	To          time.Time
+	ExerciseIds []int
+}
+
+// ExerciseProgress aggregates the logged sets of one exercise. Volume is the
+// sum of reps × weight over all sets, and the best set is the one with the
+// highest estimated one rep max (Epley: weight × (1 + reps / 30)).
+type ExerciseProgress struct {
+	ExerciseId         int     `json:"exercise_id" db:"exercise_id"`
+	ExerciseName       string  `json:"exercise_name" db:"exercise_name"`
+	SessionCount       int     `json:"session_count" db:"session_count"`
+	SetCount           int     `json:"set_count" db:"set_count"`
+	TotalReps          int     `json:"total_reps" db:"total_reps"`
+	TotalVolume        float64 `json:"total_volume" db:"total_volume"`
+	BestSetReps        int     `json:"best_set_reps" db:"best_set_reps"`
+	BestSetWeight      float64 `json:"best_set_weight" db:"best_set_weight"`
+	EstimatedOneRepMax float64 `json:"estimated_one_rep_max" db:"estimated_one_rep_max"`
+}
+
+// WeeklyProgress is ExerciseProgress for one ISO week, starting on Monday in
+// UTC. The estimated one rep max of consecutive weeks gives the trend.
+type WeeklyProgress struct {
+	WeekStart time.Time `json:"week_start"`
+	ExerciseProgress
+}
+
+// ReportStore computes progress reports over the owner's logged sets.
+type ReportStore interface {
+	GetProgressReport(owner string, filter ProgressFilter) ([]ExerciseProgress, error)
+	GetWeeklyProgressReport(owner string, filter ProgressFilter) ([]WeeklyProgress, error)
+}
+
+func (ws *WorkoutServer) getProgressReportHandler(w http.ResponseWriter, r *http.Request) {
+	owner, _ := middleware.Username(r.Context())
+	filter, err := parseProgressFilter(r)
+	if err != nil {
+		api.StatusBadRequestServerError(w, err)
+		return
+	}
+
+	report, err := ws.store.GetProgressReport(owner, filter)
+	if err != nil {
+		api.DatabaseError(w, err)
+		return
+	}
+	if report == nil {
+		report = []ExerciseProgress{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+func (ws *WorkoutServer) getWeeklyProgressReportHandler(w http.ResponseWriter, r *http.Request) {
+	owner, _ := middleware.Username(r.Context())
+	filter, err := parseProgressFilter(r)
+	if err != nil {
+		api.StatusBadRequestServerError(w, err)
+		return
+	}
+
+	report, err := ws.store.GetWeeklyProgressReport(owner, filter)
+	if err != nil {
+		api.DatabaseError(w, err)
+		return
+	}
+	if report == nil {
+		report = []WeeklyProgress{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// parseProgressFilter reads the optional from and to query parameters and
+// exercise_id, which may be repeated or hold a comma separated list.
+func parseProgressFilter(r *http.Request) (ProgressFilter, error) {
+	query := r.URL.Query()
+	filter := ProgressFilter{}
+
+	var err error
+	if from := query.Get("from"); from != "" {
+		if filter.From, err = parseTimeParam(from); err != nil {
+			return ProgressFilter{}, api.ErrInvalidTimeRange
+		}
+	}
+	if to := query.Get("to"); to != "" {
+		if filter.To, err = parseTimeParam(to); err != nil {
+			return ProgressFilter{}, api.ErrInvalidTimeRange
+		}
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.To.After(filter.From) {
+		return ProgressFilter{}, api.ErrInvalidTimeRange
+	}
+
+	for _, value := range query["exercise_id"] {
+		for _, idStr := range strings.Split(value, ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(idStr))
+			if err != nil {
+				return ProgressFilter{}, api.ErrInvalidID
+			}
+			filter.ExerciseIds = append(filter.ExerciseIds, id)
+		}
+	}
+	return filter, nil
+}
+
+// estimatedOneRepMax uses the Epley formula, the same one the SQL reports use.
+func estimatedOneRepMax(reps int, weight float64) float64 {
+	return weight * (1 + float64(reps)/30.0)
+}
+
+// roundReport rounds to hundredths so SQL and Go aggregation agree.
+func roundReport(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+func roundExerciseProgress(progress *ExerciseProgress) {
+	progress.TotalVolume = roundReport(progress.TotalVolume)
+	progress.BestSetWeight = roundReport(progress.BestSetWeight)
+	progress.EstimatedOneRepMax = roundReport(progress.EstimatedOneRepMax)
+}
+
+// weekStart returns the Monday, in UTC, of the week t falls in.
+func weekStart(t time.Time) time.Time {
+	t = t.UTC()
+	year, month, day := t.Date()
+	return time.Date(year, month, day-mondayOffset(t.Weekday()), 0, 0, 0, 0, time.UTC)
+}
+
+// computeProgress aggregates sessions in Go the same way the SQL reports do.
+// With weekly set, the sets are grouped per exercise and week.
+func computeProgress(sessions []WorkoutSession, filter ProgressFilter, weekly bool) []WeeklyProgress {
+	type key struct {
+		week       time.Time
+		exerciseId int
+	}
+	type bestSet struct {
+		e1rm, weight float64
+		reps         int
+	}
+	reports := map[key]*WeeklyProgress{}
+	best := map[key]bestSet{}
+	seenSessions := map[key]map[int]bool{}
+
+	for _, session := range sessions {
+		if !filter.From.IsZero() && session.StartedAt.Before(filter.From) {
+			continue
+		}
+		if !filter.To.IsZero() && !session.StartedAt.Before(filter.To) {
+			continue
+		}
+		for _, exercise := range session.Exercises {
+			if len(filter.ExerciseIds) > 0 && !slices.Contains(filter.ExerciseIds, exercise.ExerciseId) {
+				continue
+			}
+			k := key{exerciseId: exercise.ExerciseId}
+			if weekly {
+				k.week = weekStart(session.StartedAt)
+			}
+			for _, set := range exercise.Sets {
+				report, ok := reports[k]
+				if !ok {
+					report = &WeeklyProgress{WeekStart: k.week}
+					report.ExerciseId = exercise.ExerciseId
+					report.ExerciseName = exercise.ExerciseName
+					reports[k] = report
+					seenSessions[k] = map[int]bool{}
+				}
+				if !seenSessions[k][session.Id] {
+					seenSessions[k][session.Id] = true
+					report.SessionCount++
+				}
+				report.SetCount++
+				report.TotalReps += set.Reps
+				report.TotalVolume += float64(set.Reps) * set.Weight
+
+				candidate := bestSet{estimatedOneRepMax(set.Reps, set.Weight), set.Weight, set.Reps}
+				current, ok := best[k]
+				if !ok || candidate.e1rm > current.e1rm ||
+					(candidate.e1rm == current.e1rm && (candidate.weight > current.weight ||
+						(candidate.weight == current.weight && candidate.reps > current.reps))) {
+					best[k] = candidate
+				}
+			}
+		}
+	}
+
+	list := make([]WeeklyProgress, 0, len(reports))
+	for k, report := range reports {
+		report.BestSetReps = best[k].reps
+		report.BestSetWeight = best[k].weight
+		report.EstimatedOneRepMax = best[k].e1rm
+		roundExerciseProgress(&report.ExerciseProgress)
+		list = append(list, *report)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].WeekStart.Equal(list[j].WeekStart) {
+			return list[i].WeekStart.Before(list[j].WeekStart)
+		}
+		if list[i].ExerciseName != list[j].ExerciseName {
+			return list[i].ExerciseName < list[j].ExerciseName
+		}
+		return list[i].ExerciseId < list[j].ExerciseId
+	})
+	return list
+}
@@ -0,0 +1,97 @@
+package tracker
+
+import (
+	"testing"
+	"time"
+)
+
+func newReportStore() *InMemoryStore {
+	store := NewInMemoryStore()
+	seedReportStore(store)
+	return store
+}
+
+// seedReportStore logs sessions of bench press and squat, which must be the
+// first exercises added to the store.
+func seedReportStore(store WorkoutPlanStore) {
+	store.AddExercise(Exercise{Name: "bench press", Category: "strength"})
+	store.AddExercise(Exercise{Name: "squat", Category: "strength"})
+	bench := func(sets ...LoggedSet) SessionExercise {
+		return SessionExercise{ExerciseId: 1, ExerciseName: "bench press", Sets: sets}
+	}
+	store.AddWorkoutSession("test", WorkoutSession{
+		StartedAt: time.Date(2025, 6, 2, 7, 0, 0, 0, time.UTC),
+		Exercises: []SessionExercise{
+			bench(LoggedSet{Reps: 5, Weight: 100}, LoggedSet{Reps: 3, Weight: 110}),
+			{ExerciseId: 2, ExerciseName: "squat", Sets: []LoggedSet{{Reps: 5, Weight: 140}}},
+		},
+	})
+	store.AddWorkoutSession("test", WorkoutSession{
+		StartedAt: time.Date(2025, 6, 5, 7, 0, 0, 0, time.UTC),
+		Exercises: []SessionExercise{bench(LoggedSet{Reps: 8, Weight: 90})},
+	})
+	store.AddWorkoutSession("test", WorkoutSession{
+		StartedAt: time.Date(2025, 6, 9, 7, 0, 0, 0, time.UTC),
+		Exercises: []SessionExercise{bench(LoggedSet{Reps: 2, Weight: 120})},
+	})
+	store.AddWorkoutSession("other", WorkoutSession{
+		StartedAt: time.Date(2025, 6, 9, 7, 0, 0, 0, time.UTC),
+		Exercises: []SessionExercise{bench(LoggedSet{Reps: 1, Weight: 200})},
+	})
+}
+
+func TestProgressReport(t *testing.T) {
+	store := newReportStore()
+
+	t.Run("aggregates per exercise", func(t *testing.T) {
+		report, _ := store.GetProgressReport("test", ProgressFilter{})
+
+		expected := []ExerciseProgress{
+			{ExerciseId: 1, ExerciseName: "bench press", SessionCount: 3, SetCount: 4, TotalReps: 18, TotalVolume: 1790,
+				BestSetReps: 2, BestSetWeight: 120, EstimatedOneRepMax: 128},
+			{ExerciseId: 2, ExerciseName: "squat", SessionCount: 1, SetCount: 1, TotalReps: 5, TotalVolume: 700,
+				BestSetReps: 5, BestSetWeight: 140, EstimatedOneRepMax: 163.33},
+		}
+		assertProgress(t, expected, report)
+	})
+
+	t.Run("filters by date range and exercise", func(t *testing.T) {
+		filter := ProgressFilter{From: time.Date(2025, 6, 3, 0, 0, 0, 0, time.UTC), ExerciseIds: []int{1}}
+		report, _ := store.GetProgressReport("test", filter)
+
+		expected := []ExerciseProgress{
+			{ExerciseId: 1, ExerciseName: "bench press", SessionCount: 2, SetCount: 2, TotalReps: 10, TotalVolume: 960,
+				BestSetReps: 2, BestSetWeight: 120, EstimatedOneRepMax: 128},
+		}
+		assertProgress(t, expected, report)
+	})
+
+	t.Run("aggregates per week", func(t *testing.T) {
+		report, _ := store.GetWeeklyProgressReport("test", ProgressFilter{ExerciseIds: []int{1}})
+
+		if len(report) != 2 {
+			t.Fatalf("Expected 2 weeks, got %+v", report)
+		}
+		if !report[0].WeekStart.Equal(time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC)) || !report[1].WeekStart.Equal(time.Date(2025, 6, 9, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("Expected weeks starting 2025-06-02 and 2025-06-09, got %v and %v", report[0].WeekStart, report[1].WeekStart)
+		}
+		assertProgress(t, []ExerciseProgress{
+			{ExerciseId: 1, ExerciseName: "bench press", SessionCount: 2, SetCount: 3, TotalReps: 16, TotalVolume: 1550,
+				BestSetReps: 3, BestSetWeight: 110, EstimatedOneRepMax: 121},
+			{ExerciseId: 1, ExerciseName: "bench press", SessionCount: 1, SetCount: 1, TotalReps: 2, TotalVolume: 240,
+				BestSetReps: 2, BestSetWeight: 120, EstimatedOneRepMax: 128},
+		}, []ExerciseProgress{report[0].ExerciseProgress, report[1].ExerciseProgress})
+	})
+}
+
+func assertProgress(t testing.TB, expected, got []ExerciseProgress) {
+	t.Helper()
+	if len(expected) != len(got) {
+		t.Fatalf("Expected %+v, got %+v", expected, got)
+	}
+	for i := range expected {
+		if expected[i] != got[i] {
+			t.Errorf("Expected %+v, got %+v", expected[i], got[i])
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package tracker
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/Oriseer/workout_tracker/api"
+	"github.com/Oriseer/workout_tracker/middleware"
+)
+
+// UserAccount is a registered user as administrators see it.
+type UserAccount struct {
+	Username      string `json:"username" db:"username"`
+	Email         string `json:"email" db:"email"`
+	EmailVerified bool   `json:"email_verified" db:"email_verified"`
+	Role          string `json:"role" db:"role"`
+}
+
+type RoleRequest struct {
+	Role string `json:"role"`
+}
+
+// RoleStore keeps the role of every user, one of middleware.Roles.
+// api.ErrUserNotFound is returned for unknown usernames.
+type RoleStore interface {
+	GetUserRole(username string) (string, error)
+	GetUserList() ([]UserAccount, error)
+	SetUserRole(username, role string) error
+}
+
+func (ws *WorkoutServer) getUserListHandler(w http.ResponseWriter, r *http.Request) {
+	list, err := ws.store.GetUserList()
+	if err != nil {
+		api.DatabaseError(w, err)
+		return
+	}
+	if list == nil {
+		list = []UserAccount{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// updateUserRoleHandler changes the role of a user and signs them out, so
+// tokens carrying the old role stop working right away.
+func (ws *WorkoutServer) updateUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	username := r.PathValue("username")
+	request := RoleRequest{}
+	if err := ws.jsonDecode(r, &request); err != nil {
+		api.RequestBodyError(w, err)
+		return
+	}
+	if !slices.Contains(middleware.Roles, request.Role) {
+		api.StatusBadRequestServerError(w, api.ErrInvalidRole)
+		return
+	}
+
+	err := ws.store.SetUserRole(username, request.Role)
+	if err == api.ErrUserNotFound {
+		api.NotFoundError(w, err)
+		return
+	} else if err != nil {
+		api.DatabaseError(w, err)
+		return
+	}
+	if err := ws.store.RevokeUserTokens(username, time.Now()); err != nil {
+		api.DatabaseError(w, err)
+		return
+	}
+	// Admins changing their own role are signed out of this request too
+	if claims, _ := middleware.Claims(r.Context()); claims.Username == username {
+		if err := ws.store.RevokeToken(claims.ID, claims.ExpiresAt); err != nil {
+			api.DatabaseError(w, err)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
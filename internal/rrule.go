@@ -0,0 +1,198 @@
+package tracker
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Oriseer/workout_tracker/api"
+)
+
+// maxRRuleIterations bounds the expansion of rules whose candidates never
+// reach the requested range, such as a monthly rule on the 31st.
+const maxRRuleIterations = 100000
+
+var rruleWeekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// RRule is the subset of the iCalendar (RFC 5545) recurrence rule used for
+// scheduled workouts: FREQ (DAILY, WEEKLY or MONTHLY), INTERVAL, BYDAY,
+// COUNT and UNTIL. Weeks start on Monday.
+type RRule struct {
+	Freq     string
+	Interval int
+	ByDay    []time.Weekday
+	Count    int
+	Until    time.Time
+}
+
+// ParseRRule parses a rule such as "FREQ=WEEKLY;BYDAY=MO,WE,FR". An optional
+// "RRULE:" prefix is accepted.
+func ParseRRule(rule string) (RRule, error) {
+	rule = strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+	rrule := RRule{Interval: 1}
+
+	for _, part := range strings.Split(rule, ";") {
+		key, value, found := strings.Cut(part, "=")
+		if !found {
+			return RRule{}, fmt.Errorf("%w: %q", api.ErrInvalidRRule, part)
+		}
+
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			rrule.Freq = strings.ToUpper(value)
+			if rrule.Freq != "DAILY" && rrule.Freq != "WEEKLY" && rrule.Freq != "MONTHLY" {
+				return RRule{}, fmt.Errorf("%w: unsupported FREQ %q", api.ErrInvalidRRule, value)
+			}
+		case "INTERVAL":
+			interval, err := strconv.Atoi(value)
+			if err != nil || interval < 1 {
+				return RRule{}, fmt.Errorf("%w: invalid INTERVAL %q", api.ErrInvalidRRule, value)
+			}
+			rrule.Interval = interval
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				weekday, ok := rruleWeekdays[strings.ToUpper(day)]
+				if !ok {
+					return RRule{}, fmt.Errorf("%w: invalid BYDAY %q", api.ErrInvalidRRule, day)
+				}
+				rrule.ByDay = append(rrule.ByDay, weekday)
+			}
+		case "COUNT":
+			count, err := strconv.Atoi(value)
+			if err != nil || count < 1 {
+				return RRule{}, fmt.Errorf("%w: invalid COUNT %q", api.ErrInvalidRRule, value)
+			}
+			rrule.Count = count
+		case "UNTIL":
+			until, err := parseRRuleTime(value)
+			if err != nil {
+				return RRule{}, fmt.Errorf("%w: invalid UNTIL %q", api.ErrInvalidRRule, value)
+			}
+			rrule.Until = until
+		default:
+			return RRule{}, fmt.Errorf("%w: unsupported part %q", api.ErrInvalidRRule, key)
+		}
+	}
+
+	if rrule.Freq == "" {
+		return RRule{}, fmt.Errorf("%w: FREQ is required", api.ErrInvalidRRule)
+	}
+	if rrule.Count != 0 && !rrule.Until.IsZero() {
+		return RRule{}, fmt.Errorf("%w: COUNT and UNTIL are mutually exclusive", api.ErrInvalidRRule)
+	}
+	slices.SortFunc(rrule.ByDay, func(a, b time.Weekday) int {
+		return mondayOffset(a) - mondayOffset(b)
+	})
+	return rrule, nil
+}
+
+// parseRRuleTime parses an UNTIL value. A bare date includes the whole day.
+func parseRRuleTime(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	t, err := time.Parse("20060102", value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.Add(24*time.Hour - time.Second), nil
+}
+
+// mondayOffset returns the number of days between Monday and weekday.
+func mondayOffset(weekday time.Weekday) int {
+	return (int(weekday) + 6) % 7
+}
+
+// Occurrences expands the rule starting at start and returns the occurrences
+// in [from, to) in ascending order. Occurrences keep the wall clock time of
+// start in its location, also across daylight saving changes.
+func (r RRule) Occurrences(start, from, to time.Time) []time.Time {
+	occurrences := []time.Time{}
+	loc := start.Location()
+	year, month, day := start.Date()
+	hour, minute, second := start.Clock()
+	at := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, hour, minute, second, 0, loc)
+	}
+
+	count := 0
+	// emit reports whether expansion should continue after the candidate.
+	emit := func(candidate time.Time) bool {
+		if candidate.Before(start) {
+			return true
+		}
+		if !r.Until.IsZero() && candidate.After(r.Until) {
+			return false
+		}
+		if !candidate.Before(to) {
+			return false
+		}
+		count++
+		if !candidate.Before(from) {
+			occurrences = append(occurrences, candidate)
+		}
+		return r.Count == 0 || count < r.Count
+	}
+
+	for i := 0; i < maxRRuleIterations; i++ {
+		step := i * r.Interval
+		switch r.Freq {
+		case "DAILY":
+			candidate := at(year, month, day+step)
+			if len(r.ByDay) > 0 && !slices.Contains(r.ByDay, candidate.Weekday()) && candidate.Before(to) {
+				continue
+			}
+			if !emit(candidate) {
+				return occurrences
+			}
+		case "WEEKLY":
+			weekStart := day - mondayOffset(start.Weekday()) + 7*step
+			days := r.ByDay
+			if len(days) == 0 {
+				days = []time.Weekday{start.Weekday()}
+			}
+			for _, weekday := range days {
+				if !emit(at(year, month, weekStart+mondayOffset(weekday))) {
+					return occurrences
+				}
+			}
+		case "MONTHLY":
+			first := time.Date(year, month+time.Month(step), 1, 0, 0, 0, 0, loc)
+			if len(r.ByDay) == 0 {
+				candidate := at(first.Year(), first.Month(), day)
+				// Months without the start day are skipped, as RFC 5545 requires
+				if candidate.Month() != first.Month() {
+					continue
+				}
+				if !emit(candidate) {
+					return occurrences
+				}
+				continue
+			}
+			for d := 1; d <= 31; d++ {
+				candidate := at(first.Year(), first.Month(), d)
+				if candidate.Month() != first.Month() {
+					break
+				}
+				if slices.Contains(r.ByDay, candidate.Weekday()) && !emit(candidate) {
+					return occurrences
+				}
+			}
+		default:
+			return occurrences
+		}
+	}
+	return occurrences
+}
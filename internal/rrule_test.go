@@ -0,0 +1,92 @@
+package tracker
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Oriseer/workout_tracker/api"
+)
+
+func TestRRuleOccurrences(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+
+	cases := []struct {
+		name     string
+		rule     string
+		start    time.Time
+		from, to time.Time
+		expected []string
+	}{
+		{
+			name:     "weekly on several days",
+			rule:     "FREQ=WEEKLY;BYDAY=MO,WE,FR",
+			start:    time.Date(2025, 6, 4, 7, 0, 0, 0, berlin),
+			from:     time.Date(2025, 6, 1, 0, 0, 0, 0, berlin),
+			to:       time.Date(2025, 6, 10, 0, 0, 0, 0, berlin),
+			expected: []string{"2025-06-04T07:00:00+02:00", "2025-06-06T07:00:00+02:00", "2025-06-09T07:00:00+02:00"},
+		},
+		{
+			name:     "keeps the wall clock time across daylight saving",
+			rule:     "FREQ=DAILY",
+			start:    time.Date(2025, 3, 29, 7, 0, 0, 0, berlin),
+			from:     time.Date(2025, 3, 29, 0, 0, 0, 0, berlin),
+			to:       time.Date(2025, 3, 31, 0, 0, 0, 0, berlin),
+			expected: []string{"2025-03-29T07:00:00+01:00", "2025-03-30T07:00:00+02:00"},
+		},
+		{
+			name:     "count includes occurrences before the range",
+			rule:     "FREQ=DAILY;INTERVAL=2;COUNT=3",
+			start:    time.Date(2025, 6, 1, 7, 0, 0, 0, time.UTC),
+			from:     time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC),
+			to:       time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC),
+			expected: []string{"2025-06-03T07:00:00Z", "2025-06-05T07:00:00Z"},
+		},
+		{
+			name:     "until is inclusive",
+			rule:     "FREQ=WEEKLY;UNTIL=20250615",
+			start:    time.Date(2025, 6, 1, 7, 0, 0, 0, time.UTC),
+			from:     time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+			to:       time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC),
+			expected: []string{"2025-06-01T07:00:00Z", "2025-06-08T07:00:00Z", "2025-06-15T07:00:00Z"},
+		},
+		{
+			name:     "monthly skips months without the day",
+			rule:     "FREQ=MONTHLY",
+			start:    time.Date(2025, 1, 31, 7, 0, 0, 0, time.UTC),
+			from:     time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			to:       time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC),
+			expected: []string{"2025-01-31T07:00:00Z", "2025-03-31T07:00:00Z"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			rule, err := ParseRRule(c.rule)
+			if err != nil {
+				t.Fatalf("Expected rule %q to parse, got %v", c.rule, err)
+			}
+
+			got := rule.Occurrences(c.start, c.from, c.to)
+			if len(got) != len(c.expected) {
+				t.Fatalf("Expected %v, got %v", c.expected, got)
+			}
+			for i := range got {
+				if got[i].Format(time.RFC3339) != c.expected[i] {
+					t.Errorf("Expected occurrence %s, got %s", c.expected[i], got[i].Format(time.RFC3339))
+				}
+			}
+		})
+	}
+}
+
+func TestParseRRuleErrors(t *testing.T) {
+	for _, rule := range []string{"", "FREQ=HOURLY", "FREQ=WEEKLY;BYDAY=XX", "FREQ=DAILY;COUNT=2;UNTIL=20250101", "FREQ=DAILY;INTERVAL=0"} {
+		if _, err := ParseRRule(rule); !errors.Is(err, api.ErrInvalidRRule) {
+			t.Errorf("Expected ErrInvalidRRule for %q, got %v", rule, err)
+		}
+	}
+}
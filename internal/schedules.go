@@ -0,0 +1,294 @@
+package tracker
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Oriseer/workout_tracker/api"
+	"github.com/Oriseer/workout_tracker/middleware"
+)
+
+const (
+	OccurrencePending   = "pending"
+	OccurrenceCompleted = "completed"
+
+	// defaultScheduleRange is used by GET /schedule when no range is given,
+	// maxScheduleRange bounds how far a single request may expand.
+	defaultScheduleRange = 30 * 24 * time.Hour
+	maxScheduleRange     = 366 * 24 * time.Hour
+)
+
+// WorkoutSchedule schedules a workout plan once at StartsAt or, with an
+// RRule, repeatedly at the wall clock time of StartsAt in TimeZone.
+type WorkoutSchedule struct {
+	Id           int       `json:"id" db:"id"`
+	PlanId       int       `json:"plan_id" db:"plan_id"`
+	ExerciseName string    `json:"exercise_name" db:"exercise_name"`
+	StartsAt     time.Time `json:"starts_at" db:"starts_at"`
+	TimeZone     string    `json:"time_zone" db:"time_zone"`
+	RRule        string    `json:"rrule,omitempty" db:"rrule"`
+}
+
+// ScheduledOccurrence is one expanded occurrence of a schedule. It is
+// completed when a session following the plan started on the same day.
+type ScheduledOccurrence struct {
+	ScheduleId   int       `json:"schedule_id"`
+	PlanId       int       `json:"plan_id"`
+	ExerciseName string    `json:"exercise_name"`
+	ScheduledAt  time.Time `json:"scheduled_at"`
+	Status       string    `json:"status"`
+	SessionId    *int      `json:"session_id,omitempty"`
+}
+
+// ScheduleStore persists workout schedules, scoped to their owner.
+// api.ErrScheduleNotFound is returned for schedules the owner does not have
+// and api.ErrWorkoutPlanNotFound when the plan is not one of the owner's.
+type ScheduleStore interface {
+	AddWorkoutSchedule(owner string, schedule WorkoutSchedule) (WorkoutSchedule, error)
+	GetWorkoutScheduleList(owner string) ([]WorkoutSchedule, error)
+	UpdateWorkoutSchedule(owner string, schedule WorkoutSchedule) error
+	DeleteWorkoutSchedule(owner string, id int) error
+}
+
+func (ws *WorkoutServer) getWorkoutScheduleListHandler(w http.ResponseWriter, r *http.Request) {
+	owner, _ := middleware.Username(r.Context())
+	list, err := ws.store.GetWorkoutScheduleList(owner)
+	if err != nil {
+		api.DatabaseError(w, err)
+		return
+	}
+	if list == nil {
+		list = []WorkoutSchedule{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+func (ws *WorkoutServer) addWorkoutScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	owner, _ := middleware.Username(r.Context())
+	schedule := WorkoutSchedule{}
+	if err := ws.jsonDecode(r, &schedule); err != nil {
+		api.RequestBodyError(w, err)
+		return
+	}
+	if err := validateWorkoutSchedule(&schedule); err != nil {
+		api.StatusBadRequestServerError(w, err)
+		return
+	}
+
+	schedule, err := ws.store.AddWorkoutSchedule(owner, schedule)
+	if err == api.ErrWorkoutPlanNotFound {
+		api.StatusBadRequestServerError(w, err)
+		return
+	} else if err != nil {
+		api.DatabaseError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(schedule)
+}
+
+func (ws *WorkoutServer) updateWorkoutScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	owner, _ := middleware.Username(r.Context())
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		api.StatusBadRequestServerError(w, api.ErrInvalidID)
+		return
+	}
+	schedule := WorkoutSchedule{}
+	if err := ws.jsonDecode(r, &schedule); err != nil {
+		api.RequestBodyError(w, err)
+		return
+	}
+	if err := validateWorkoutSchedule(&schedule); err != nil {
+		api.StatusBadRequestServerError(w, err)
+		return
+	}
+	schedule.Id = id
+
+	err = ws.store.UpdateWorkoutSchedule(owner, schedule)
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case api.ErrScheduleNotFound:
+		api.NotFoundError(w, err)
+	case api.ErrWorkoutPlanNotFound:
+		api.StatusBadRequestServerError(w, err)
+	default:
+		api.DatabaseError(w, err)
+	}
+}
+
+func (ws *WorkoutServer) deleteWorkoutScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	owner, _ := middleware.Username(r.Context())
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		api.StatusBadRequestServerError(w, api.ErrInvalidID)
+		return
+	}
+
+	err = ws.store.DeleteWorkoutSchedule(owner, id)
+	if err == api.ErrScheduleNotFound {
+		api.NotFoundError(w, err)
+		return
+	} else if err != nil {
+		api.DatabaseError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getScheduleHandler expands the schedules of the user into occurrences
+// between the from and to query parameters, optionally filtered by status.
+func (ws *WorkoutServer) getScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	owner, _ := middleware.Username(r.Context())
+	query := r.URL.Query()
+
+	from, to, err := parseTimeRange(query.Get("from"), query.Get("to"), defaultScheduleRange)
+	if err != nil || to.Sub(from) > maxScheduleRange {
+		api.StatusBadRequestServerError(w, api.ErrInvalidTimeRange)
+		return
+	}
+	status := query.Get("status")
+	if status != "" && status != OccurrencePending && status != OccurrenceCompleted {
+		api.StatusBadRequestServerError(w, api.ErrInvalidStatus)
+		return
+	}
+
+	schedules, err := ws.store.GetWorkoutScheduleList(owner)
+	if err != nil {
+		api.DatabaseError(w, err)
+		return
+	}
+	sessions, err := ws.store.GetWorkoutSessionList(owner)
+	if err != nil {
+		api.DatabaseError(w, err)
+		return
+	}
+
+	occurrences := expandSchedules(schedules, sessions, from, to)
+	filtered := []ScheduledOccurrence{}
+	for _, occurrence := range occurrences {
+		if status == "" || occurrence.Status == status {
+			filtered = append(filtered, occurrence)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(filtered)
+}
+
+// parseTimeRange parses RFC 3339 timestamps or YYYY-MM-DD dates. A missing
+// from defaults to now and a missing to to from plus defaultRange.
+func parseTimeRange(fromStr, toStr string, defaultRange time.Duration) (time.Time, time.Time, error) {
+	from := time.Now()
+	if fromStr != "" {
+		var err error
+		if from, err = parseTimeParam(fromStr); err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+	to := from.Add(defaultRange)
+	if toStr != "" {
+		var err error
+		if to, err = parseTimeParam(toStr); err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+	if !to.After(from) {
+		return time.Time{}, time.Time{}, api.ErrInvalidTimeRange
+	}
+	return from, to, nil
+}
+
+func parseTimeParam(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
+
+// expandSchedules returns the occurrences of all schedules in [from, to)
+// sorted by date. Each session linked to a plan completes at most one
+// occurrence of that plan on the day, in the schedule's time zone, it started.
+func expandSchedules(schedules []WorkoutSchedule, sessions []WorkoutSession, from, to time.Time) []ScheduledOccurrence {
+	occurrences := []ScheduledOccurrence{}
+	used := map[int]bool{}
+
+	for _, schedule := range schedules {
+		loc, err := time.LoadLocation(schedule.TimeZone)
+		if err != nil {
+			loc = time.UTC
+		}
+		start := schedule.StartsAt.In(loc)
+
+		var times []time.Time
+		if schedule.RRule == "" {
+			if !start.Before(from) && start.Before(to) {
+				times = append(times, start)
+			}
+		} else if rule, err := ParseRRule(schedule.RRule); err == nil {
+			times = rule.Occurrences(start, from, to)
+		}
+
+		for _, scheduledAt := range times {
+			occurrence := ScheduledOccurrence{
+				ScheduleId:   schedule.Id,
+				PlanId:       schedule.PlanId,
+				ExerciseName: schedule.ExerciseName,
+				ScheduledAt:  scheduledAt,
+				Status:       OccurrencePending,
+			}
+			for _, session := range sessions {
+				if used[session.Id] || session.PlanId == nil || *session.PlanId != schedule.PlanId {
+					continue
+				}
+				if sameDay(session.StartedAt.In(loc), scheduledAt) {
+					used[session.Id] = true
+					sessionId := session.Id
+					occurrence.Status = OccurrenceCompleted
+					occurrence.SessionId = &sessionId
+					break
+				}
+			}
+			occurrences = append(occurrences, occurrence)
+		}
+	}
+
+	sort.SliceStable(occurrences, func(i, j int) bool {
+		if occurrences[i].ScheduledAt.Equal(occurrences[j].ScheduledAt) {
+			return occurrences[i].ScheduleId < occurrences[j].ScheduleId
+		}
+		return occurrences[i].ScheduledAt.Before(occurrences[j].ScheduledAt)
+	})
+	return occurrences
+}
+
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
+
+// validateWorkoutSchedule checks the schedule and defaults its time zone to UTC.
+func validateWorkoutSchedule(schedule *WorkoutSchedule) error {
+	if schedule.PlanId == 0 || schedule.StartsAt.IsZero() {
+		return api.ErrInvalidSchedule
+	}
+	if schedule.TimeZone == "" {
+		schedule.TimeZone = "UTC"
+	}
+	if _, err := time.LoadLocation(schedule.TimeZone); err != nil {
+		return api.ErrInvalidTimeZone
+	}
+	if schedule.RRule != "" {
+		if _, err := ParseRRule(schedule.RRule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
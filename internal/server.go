@@ -3,11 +3,13 @@ package tracker
 import (
 	"encoding/json"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
+	"net/mail"
 	"strings"
 
 	"github.com/Oriseer/workout_tracker/api"
+	"github.com/Oriseer/workout_tracker/config"
 	"github.com/Oriseer/workout_tracker/middleware"
 )
 
@@ -24,71 +26,160 @@ type User struct {
 }
 
 type LoginData struct {
-	Username string `json:"username" db:"username"`
-	Password string `json:"password" db:"password_hash"`
+	Username      string `json:"username" db:"username"`
+	Password      string `json:"password" db:"password_hash"`
+	EmailVerified bool   `json:"-" db:"email_verified"`
+	Role          string `json:"-" db:"role"`
 }
 
+// WorkoutPlanStore persists workout plans, users, the exercise catalog,
+// workout sessions and schedules, reports on the logged sessions and keeps
+// the roles, refresh tokens, revoked access tokens, password reset and email
+// verification tokens and failed logins of users. It imports plans and
+// sessions in bulk and keeps the exercise aliases imports from other apps
+// are mapped with.
+// Every workout plan method is scoped to the owner, the username of the
+// authenticated user, and returns api.ErrWorkoutPlanNotFound for plans the
+// owner does not have. Workout plans reference a catalog exercise by
+// ExerciseId, or by ExerciseName when no id is given.
 type WorkoutPlanStore interface {
-	AddWorkoutPlan(input WorkoutPlan)
-	DeleteWorkoutPlan(name string) error
-	UpdateWorkoutPlan(input WorkoutPlan) error
-	GetWorkoutPlanList() ([]WorkoutPlan, error)
+	AddWorkoutPlan(owner string, input WorkoutPlan) error
+	DeleteWorkoutPlan(owner, name string) error
+	UpdateWorkoutPlan(owner string, input WorkoutPlan) error
+	GetWorkoutPlanList(owner string, query WorkoutPlanQuery) ([]WorkoutPlan, error)
 	AddUser(userDetails UserDetails) error
+	// UserLogin checks the password of the user and returns its details.
+	// Unknown users and wrong passwords both yield api.ErrInvalidLoginDetails.
 	UserLogin(loginData LoginData) (LoginData, error)
+	RoleStore
+	ExerciseStore
+	SessionStore
+	ScheduleStore
+	ReportStore
+	RefreshTokenStore
+	TokenRevocationStore
+	PasswordResetStore
+	EmailVerificationStore
+	LoginFailureStore
+	ImportStore
+	ExerciseAliasStore
 }
 
 type Token struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int    `json:"expires_in,omitempty"`
 }
 
 // workoutPlanStore is a concrete implementation of the WorkoutPlanStore interface
 type WorkoutServer struct {
-	store WorkoutPlanStore
+	store   WorkoutPlanStore
+	config  config.Config
+	mailer  Mailer
+	metrics *serverMetrics
+
+	ipLimiter       *loginLimiter
+	usernameLimiter *loginLimiter
+	router          *routeMux
 	http.Handler
 }
 
 type WorkoutPlan struct {
+	Id           int    `json:"Id,omitempty" db:"id"`
+	ExerciseId   int    `json:"ExerciseId,omitempty" db:"exercise_id"`
 	ExerciseName string `json:"ExerciseName" db:"exercise_name"`
 	Repititions  int    `json:"Repetitions" db:"repetitions"`
 	Sets         int    `json:"Sets" db:"sets"`
 	Weight       int    `json:"Weight" db:"weights"`
 }
 
-func NewWorkoutServer(store WorkoutPlanStore) *WorkoutServer {
+// NewWorkoutServer serves the API from store. Tokens are signed and mail is
+// delivered as cfg says.
+func NewWorkoutServer(store WorkoutPlanStore, cfg config.Config) *WorkoutServer {
 	s := new(WorkoutServer)
 
 	s.store = store
+	s.config = cfg
+	s.mailer = NewMailer(cfg.Mail)
+	s.metrics = newServerMetrics(store)
+	s.ipLimiter = newLoginLimiter(cfg.Auth.LoginIPLimit, cfg.Auth.LoginLimitWindow)
+	s.usernameLimiter = newLoginLimiter(cfg.Auth.LoginUsernameLimit, cfg.Auth.LoginLimitWindow)
 
-	router := http.NewServeMux()
+	router := &routeMux{ServeMux: http.NewServeMux()}
+	auth := middleware.JwtAuth(cfg.JWT, store)
+	admin := middleware.RequireRole(middleware.RoleAdmin)
 
 	// Route for storing and deleting workout plans
-	router.Handle("/workout-plans/", middleware.JwtAuth(http.HandlerFunc(s.storeWorkoutHandler)))
-	router.Handle("/workouts", middleware.JwtAuth(http.HandlerFunc(s.getWorkoutPlanListHandler)))
+	router.Handle("/workout-plans/", auth(http.HandlerFunc(s.storeWorkoutHandler)))
+	router.Handle("/workouts", auth(http.HandlerFunc(s.getWorkoutPlanListHandler)))
+	router.Handle("GET /exercises", auth(http.HandlerFunc(s.getExerciseListHandler)))
+	router.Handle("GET /exercises/{id}", auth(http.HandlerFunc(s.getExerciseHandler)))
+	router.Handle("POST /exercises", auth(admin(http.HandlerFunc(s.addExerciseHandler))))
+	router.Handle("PUT /exercises/{id}", auth(admin(http.HandlerFunc(s.updateExerciseHandler))))
+	router.Handle("DELETE /exercises/{id}", auth(admin(http.HandlerFunc(s.deleteExerciseHandler))))
+	router.Handle("GET /sessions", auth(http.HandlerFunc(s.getWorkoutSessionListHandler)))
+	router.Handle("POST /sessions", auth(http.HandlerFunc(s.addWorkoutSessionHandler)))
+	router.Handle("GET /sessions/{id}", auth(http.HandlerFunc(s.getWorkoutSessionHandler)))
+	router.Handle("PUT /sessions/{id}", auth(http.HandlerFunc(s.updateWorkoutSessionHandler)))
+	router.Handle("DELETE /sessions/{id}", auth(http.HandlerFunc(s.deleteWorkoutSessionHandler)))
+	router.Handle("POST /sessions/{id}/sets", auth(http.HandlerFunc(s.addLoggedSetHandler)))
+	router.Handle("GET /schedules", auth(http.HandlerFunc(s.getWorkoutScheduleListHandler)))
+	router.Handle("POST /schedules", auth(http.HandlerFunc(s.addWorkoutScheduleHandler)))
+	router.Handle("PUT /schedules/{id}", auth(http.HandlerFunc(s.updateWorkoutScheduleHandler)))
+	router.Handle("DELETE /schedules/{id}", auth(http.HandlerFunc(s.deleteWorkoutScheduleHandler)))
+	router.Handle("GET /schedule", auth(http.HandlerFunc(s.getScheduleHandler)))
+	router.Handle("GET /reports/progress", auth(http.HandlerFunc(s.getProgressReportHandler)))
+	router.Handle("GET /reports/progress/weekly", auth(http.HandlerFunc(s.getWeeklyProgressReportHandler)))
+	router.Handle("GET /export", auth(http.HandlerFunc(s.exportHandler)))
+	router.Handle("POST /import", auth(http.HandlerFunc(s.importHandler)))
+	router.Handle("GET /exercise-aliases", auth(http.HandlerFunc(s.getExerciseAliasListHandler)))
+	router.Handle("PUT /exercise-aliases/{alias}", auth(http.HandlerFunc(s.setExerciseAliasHandler)))
+	router.Handle("DELETE /exercise-aliases/{alias}", auth(http.HandlerFunc(s.deleteExerciseAliasHandler)))
+	router.Handle("GET /users", auth(admin(http.HandlerFunc(s.getUserListHandler))))
+	router.Handle("PUT /users/{username}/role", auth(admin(http.HandlerFunc(s.updateUserRoleHandler))))
 	router.Handle("/auth/register", http.HandlerFunc(s.registerUserHandler))
 	router.Handle("/auth/login", http.HandlerFunc(s.loginUserHandler))
-	s.Handler = router
+	router.Handle("POST /auth/refresh", http.HandlerFunc(s.refreshTokenHandler))
+	router.Handle("POST /auth/logout", auth(http.HandlerFunc(s.logoutHandler)))
+	router.Handle("POST /auth/logout-all", auth(http.HandlerFunc(s.logoutAllHandler)))
+	router.Handle("POST /auth/password/forgot", s.requireMailer(s.forgotPasswordHandler))
+	router.Handle("POST /auth/password/reset", s.requireMailer(s.resetPasswordHandler))
+	router.Handle("GET /auth/verify", s.requireMailer(s.verifyEmailHandler))
+	router.Handle("GET /metrics", s.metrics.handler())
+	router.Handle("GET /openapi.json", http.HandlerFunc(openAPIHandler))
+	router.Handle("GET /docs", http.HandlerFunc(docsHandler))
+	s.router = router
+	s.Handler = middleware.AccessLog(slog.Default())(s.metrics.instrument(router.ServeMux))
 
 	return s
 }
 
+// SetMailer replaces the mailer chosen by the configuration that delivers
+// mail such as password reset tokens. Without a mailer password resets and
+// email verification are disabled.
+func (ws *WorkoutServer) SetMailer(mailer Mailer) {
+	ws.mailer = mailer
+}
+
 func (ws *WorkoutServer) storeWorkoutHandler(w http.ResponseWriter, r *http.Request) {
 	name := strings.TrimPrefix(r.URL.Path, "/workout-plans/")
+	owner, _ := middleware.Username(r.Context())
 	workoutPlan := WorkoutPlan{}
 
 	if r.Body != nil {
 		jsonErr := ws.jsonDecode(r, &workoutPlan)
 		if jsonErr != nil {
-			log.Println("Error decoding JSON:", jsonErr)
+			middleware.Logger(r.Context()).Warn("decoding workout plan", "error", jsonErr)
 		}
 	}
 
 	switch r.Method {
 	case http.MethodPost:
-		ws.storeWorkoutPlan(w, workoutPlan)
+		ws.storeWorkoutPlan(w, owner, workoutPlan)
 	case http.MethodDelete:
-		ws.deleteWorkoutPlan(w, name)
+		ws.deleteWorkoutPlan(w, owner, name)
 	case http.MethodPut:
-		ws.updateWorkoutPlan(w, workoutPlan)
+		ws.updateWorkoutPlan(w, owner, workoutPlan)
 	default:
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
@@ -101,23 +192,37 @@ func (ws *WorkoutServer) jsonDecode(r *http.Request, v any) error {
 	return err
 }
 
-func (ws *WorkoutServer) storeWorkoutPlan(w http.ResponseWriter, plan WorkoutPlan) {
-	ws.store.AddWorkoutPlan(plan)
+func (ws *WorkoutServer) storeWorkoutPlan(w http.ResponseWriter, owner string, plan WorkoutPlan) {
+	err := ws.store.AddWorkoutPlan(owner, plan)
+	if err == api.ErrExerciseNotFound {
+		api.StatusBadRequestServerError(w, err)
+		return
+	} else if err != nil {
+		api.DatabaseError(w, err)
+		return
+	}
+	ws.metrics.plansCreated.Inc()
 	w.WriteHeader(http.StatusCreated)
 }
 
-func (ws *WorkoutServer) deleteWorkoutPlan(w http.ResponseWriter, name string) {
-	err := ws.store.DeleteWorkoutPlan(name)
-	if err != nil {
+func (ws *WorkoutServer) deleteWorkoutPlan(w http.ResponseWriter, owner, name string) {
+	err := ws.store.DeleteWorkoutPlan(owner, name)
+	if err == api.ErrWorkoutPlanNotFound {
+		api.NotFoundError(w, err)
+		return
+	} else if err != nil {
 		api.InternalServerError(w, err)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func (ws *WorkoutServer) updateWorkoutPlan(w http.ResponseWriter, plan WorkoutPlan) {
-	err := ws.store.UpdateWorkoutPlan(plan)
-	if err != nil {
+func (ws *WorkoutServer) updateWorkoutPlan(w http.ResponseWriter, owner string, plan WorkoutPlan) {
+	err := ws.store.UpdateWorkoutPlan(owner, plan)
+	if err == api.ErrWorkoutPlanNotFound {
+		api.NotFoundError(w, err)
+		return
+	} else if err != nil {
 		api.InternalServerError(w, err)
 		return
 	}
@@ -125,13 +230,32 @@ func (ws *WorkoutServer) updateWorkoutPlan(w http.ResponseWriter, plan WorkoutPl
 }
 
 func (ws *WorkoutServer) getWorkoutPlanListHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	list, err := ws.store.GetWorkoutPlanList()
+	owner, _ := middleware.Username(r.Context())
+	query, err := parseWorkoutPlanQuery(r.URL.Query())
+	if err != nil {
+		api.StatusBadRequestServerError(w, err)
+		return
+	}
+
+	// One plan more than the page holds tells whether another page follows
+	limit := query.Limit
+	query.Limit++
+	list, err := ws.store.GetWorkoutPlanList(owner, query)
 	if err != nil {
 		api.InternalServerError(w, err)
 		return
 	}
-	json.NewEncoder(w).Encode(list)
+
+	page := WorkoutPlanPage{WorkoutPlans: list}
+	if len(list) > limit {
+		page.WorkoutPlans = list[:limit]
+		page.NextCursor = newWorkoutPlanCursor(query, list[limit-1]).String()
+	}
+	if page.WorkoutPlans == nil {
+		page.WorkoutPlans = []WorkoutPlan{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
 }
 
 func (ws *WorkoutServer) registerUserHandler(w http.ResponseWriter, r *http.Request) {
@@ -145,6 +269,7 @@ func (ws *WorkoutServer) registerUserHandler(w http.ResponseWriter, r *http.Requ
 
 	if validationErr != nil {
 		api.StatusBadRequestServerError(w, validationErr)
+		return
 	}
 	err := ws.store.AddUser(userDetails)
 	if err == api.ErrUserName {
@@ -154,6 +279,11 @@ func (ws *WorkoutServer) registerUserHandler(w http.ResponseWriter, r *http.Requ
 		api.DatabaseError(w, err)
 		return
 	}
+	ws.metrics.usersRegistered.Inc()
+	if err := ws.sendVerificationMail(r.Context(), userDetails); err != nil {
+		api.DatabaseError(w, err)
+		return
+	}
 
 	w.WriteHeader(http.StatusCreated)
 }
@@ -165,30 +295,42 @@ func (ws *WorkoutServer) loginUserHandler(w http.ResponseWriter, r *http.Request
 		api.StatusBadRequestServerError(w, jsonErr)
 		return
 	}
+	if !ws.checkLoginLimits(w, r, loginData.Username) {
+		return
+	}
 	userDetails, err := ws.store.UserLogin(loginData)
 	if err == api.ErrInvalidLoginDetails {
+		ws.metrics.logins.WithLabelValues(loginInvalid).Inc()
+		ws.recordLoginFailure(r, loginData.Username)
 		api.StatusBadRequestServerError(w, err)
 		return
 	} else if err != nil {
 		api.StatusBadRequestServerError(w, err)
 		return
 	}
-
-	tokenStr, err := JwtGenerator(userDetails)
-
-	if err != nil {
-		api.StatusBadRequestServerError(w, err)
+	if ws.config.Auth.RequireEmailVerification && !userDetails.EmailVerified {
+		ws.metrics.logins.WithLabelValues(loginUnverified).Inc()
+		api.ForbiddenError(w, api.ErrEmailNotVerified)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(Token{tokenStr})
-
+	if err := ws.store.ResetLoginFailures(userDetails.Username); err != nil {
+		api.DatabaseError(w, err)
+		return
+	}
+	ws.metrics.logins.WithLabelValues(loginSuccess).Inc()
+	ws.issueTokens(w, userDetails.Username, "")
 }
 
 func validateUserDetails(userDetails UserDetails) error {
 	if userDetails.Username == "" || userDetails.Password == "" || userDetails.Email == "" {
 		return api.ErrInvalidUserDetails
 	}
+	// Only a bare address is accepted, not a display name form such as
+	// "Alice <alice@example.com>"
+	address, err := mail.ParseAddress(userDetails.Email)
+	if err != nil || address.Address != userDetails.Email {
+		return api.ErrInvalidEmail
+	}
 	return nil
 }
@@ -2,37 +2,92 @@ package tracker
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/Oriseer/workout_tracker/api"
+	"github.com/Oriseer/workout_tracker/config"
+	"github.com/Oriseer/workout_tracker/middleware"
+	"github.com/golang-jwt/jwt/v5"
 )
 
+// testConfig is the configuration of the servers under test.
+var testConfig = func() config.Config {
+	cfg := config.Default()
+	cfg.JWT.Key = "test-key"
+	return cfg
+}()
+
 type StubWorkoutPlanStore struct {
 	workoutCalls []int
 	workouts     map[string]string
 	workoutPlans []WorkoutPlan
 	userAdded    int
 	userLogged   int
+	owners       []string
+	exercises    map[int]Exercise
+	filters      []ExerciseFilter
+	sessions     map[int]WorkoutSession
+	sessionOwner map[int]string
+	schedules    []WorkoutSchedule
+	refresh      map[string]RefreshToken
+	revoked      map[string]time.Time
+	revokedUsers map[string]time.Time
+	emails       map[string]string
+	resetTokens  map[string]PasswordResetToken
+	passwords    map[string]string
+	verifyTokens map[string]EmailVerificationToken
+	verified     map[string]bool
+	roles        map[string]string
+	planQueries  []WorkoutPlanQuery
+	failures     map[string]LoginFailures
 }
 
-func (s *StubWorkoutPlanStore) AddWorkoutPlan(input WorkoutPlan) {
+func (s *StubWorkoutPlanStore) AddWorkoutPlan(owner string, input WorkoutPlan) error {
+	s.owners = append(s.owners, owner)
 	s.workoutCalls = append(s.workoutCalls, 1)
+	return nil
 }
 
-func (s *StubWorkoutPlanStore) DeleteWorkoutPlan(name string) error {
+func (s *StubWorkoutPlanStore) DeleteWorkoutPlan(owner, name string) error {
+	s.owners = append(s.owners, owner)
+	if _, exists := s.workouts[name]; !exists {
+		return api.ErrWorkoutPlanNotFound
+	}
 	delete(s.workouts, name)
 	return nil
 }
 
-func (s *StubWorkoutPlanStore) UpdateWorkoutPlan(input WorkoutPlan) error {
+func (s *StubWorkoutPlanStore) UpdateWorkoutPlan(owner string, input WorkoutPlan) error {
+	s.owners = append(s.owners, owner)
 	s.workouts["pushup"] = "updated workout plan"
 	return nil
 }
 
-func (s *StubWorkoutPlanStore) GetWorkoutPlanList() ([]WorkoutPlan, error) {
-	return s.workoutPlans, nil
+// GetWorkoutPlanList only pages the stored plans, which must be sorted by id.
+func (s *StubWorkoutPlanStore) GetWorkoutPlanList(owner string, query WorkoutPlanQuery) ([]WorkoutPlan, error) {
+	s.owners = append(s.owners, owner)
+	s.planQueries = append(s.planQueries, query)
+	plans := s.workoutPlans
+	if query.After != nil {
+		plans = []WorkoutPlan{}
+		for _, plan := range s.workoutPlans {
+			if plan.Id > query.After.Id {
+				plans = append(plans, plan)
+			}
+		}
+	}
+	if len(plans) > query.Limit {
+		plans = plans[:query.Limit]
+	}
+	return plans, nil
 }
 
 func (s *StubWorkoutPlanStore) AddUser(userDetails UserDetails) error {
@@ -42,7 +97,359 @@ func (s *StubWorkoutPlanStore) AddUser(userDetails UserDetails) error {
 
 func (s *StubWorkoutPlanStore) UserLogin(loginData LoginData) (LoginData, error) {
 	s.userLogged++
-	return LoginData{}, nil
+	return LoginData{Username: loginData.Username, EmailVerified: s.verified[loginData.Username]}, nil
+}
+
+func (s *StubWorkoutPlanStore) GetExerciseList(filter ExerciseFilter) ([]Exercise, error) {
+	s.filters = append(s.filters, filter)
+	list := []Exercise{}
+	for _, exercise := range s.exercises {
+		list = append(list, exercise)
+	}
+	return list, nil
+}
+
+func (s *StubWorkoutPlanStore) GetExercise(id int) (Exercise, error) {
+	exercise, ok := s.exercises[id]
+	if !ok {
+		return Exercise{}, api.ErrExerciseNotFound
+	}
+	return exercise, nil
+}
+
+func (s *StubWorkoutPlanStore) AddExercise(exercise Exercise) (Exercise, error) {
+	exercise.Id = len(s.exercises) + 1
+	s.exercises[exercise.Id] = exercise
+	return exercise, nil
+}
+
+func (s *StubWorkoutPlanStore) UpdateExercise(exercise Exercise) error {
+	if _, ok := s.exercises[exercise.Id]; !ok {
+		return api.ErrExerciseNotFound
+	}
+	s.exercises[exercise.Id] = exercise
+	return nil
+}
+
+func (s *StubWorkoutPlanStore) DeleteExercise(id int) error {
+	if _, ok := s.exercises[id]; !ok {
+		return api.ErrExerciseNotFound
+	}
+	delete(s.exercises, id)
+	return nil
+}
+
+func (s *StubWorkoutPlanStore) AddWorkoutSession(owner string, session WorkoutSession) (WorkoutSession, error) {
+	session.Id = len(s.sessions) + 1
+	s.sessions[session.Id] = session
+	s.sessionOwner[session.Id] = owner
+	return session, nil
+}
+
+func (s *StubWorkoutPlanStore) GetWorkoutSessionList(owner string) ([]WorkoutSession, error) {
+	list := []WorkoutSession{}
+	for id, session := range s.sessions {
+		if s.sessionOwner[id] == owner {
+			list = append(list, session)
+		}
+	}
+	return list, nil
+}
+
+func (s *StubWorkoutPlanStore) GetWorkoutSessionPage(owner string, afterId, limit int) ([]WorkoutSession, error) {
+	list := []WorkoutSession{}
+	for id, session := range s.sessions {
+		if s.sessionOwner[id] == owner && id > afterId {
+			list = append(list, session)
+		}
+	}
+	slices.SortFunc(list, func(a, b WorkoutSession) int { return a.Id - b.Id })
+	return list[:min(limit, len(list))], nil
+}
+
+func (s *StubWorkoutPlanStore) GetWorkoutSession(owner string, id int) (WorkoutSession, error) {
+	session, ok := s.sessions[id]
+	if !ok || s.sessionOwner[id] != owner {
+		return WorkoutSession{}, api.ErrSessionNotFound
+	}
+	return session, nil
+}
+
+func (s *StubWorkoutPlanStore) UpdateWorkoutSession(owner string, session WorkoutSession) error {
+	if _, err := s.GetWorkoutSession(owner, session.Id); err != nil {
+		return err
+	}
+	s.sessions[session.Id] = session
+	return nil
+}
+
+func (s *StubWorkoutPlanStore) DeleteWorkoutSession(owner string, id int) error {
+	if _, err := s.GetWorkoutSession(owner, id); err != nil {
+		return err
+	}
+	delete(s.sessions, id)
+	return nil
+}
+
+func (s *StubWorkoutPlanStore) AddLoggedSet(owner string, sessionId int, input LoggedSetInput) (LoggedSet, error) {
+	session, err := s.GetWorkoutSession(owner, sessionId)
+	if err != nil {
+		return LoggedSet{}, err
+	}
+	session.Exercises = append(session.Exercises, SessionExercise{ExerciseId: input.ExerciseId, Sets: []LoggedSet{input.LoggedSet}})
+	s.sessions[sessionId] = session
+	return input.LoggedSet, nil
+}
+
+func (s *StubWorkoutPlanStore) AddWorkoutSchedule(owner string, schedule WorkoutSchedule) (WorkoutSchedule, error) {
+	schedule.Id = len(s.schedules) + 1
+	s.schedules = append(s.schedules, schedule)
+	return schedule, nil
+}
+
+func (s *StubWorkoutPlanStore) GetWorkoutScheduleList(owner string) ([]WorkoutSchedule, error) {
+	return s.schedules, nil
+}
+
+func (s *StubWorkoutPlanStore) UpdateWorkoutSchedule(owner string, schedule WorkoutSchedule) error {
+	return nil
+}
+
+func (s *StubWorkoutPlanStore) DeleteWorkoutSchedule(owner string, id int) error {
+	return nil
+}
+
+func (s *StubWorkoutPlanStore) GetProgressReport(owner string, filter ProgressFilter) ([]ExerciseProgress, error) {
+	return nil, nil
+}
+
+func (s *StubWorkoutPlanStore) GetWeeklyProgressReport(owner string, filter ProgressFilter) ([]WeeklyProgress, error) {
+	return nil, nil
+}
+
+func (s *StubWorkoutPlanStore) AddRefreshToken(token RefreshToken) error {
+	if s.refresh == nil {
+		s.refresh = map[string]RefreshToken{}
+	}
+	s.refresh[token.TokenHash] = token
+	return nil
+}
+
+func (s *StubWorkoutPlanStore) UseRefreshToken(tokenHash string) (RefreshToken, error) {
+	token, ok := s.refresh[tokenHash]
+	if !ok {
+		return RefreshToken{}, api.ErrInvalidRefreshToken
+	}
+	if token.UsedAt != nil {
+		return token, api.ErrRefreshTokenReused
+	}
+	now := time.Now()
+	token.UsedAt = &now
+	s.refresh[tokenHash] = token
+	return token, nil
+}
+
+func (s *StubWorkoutPlanStore) RevokeRefreshTokenFamily(familyId string) error {
+	now := time.Now()
+	for hash, token := range s.refresh {
+		if token.FamilyId == familyId {
+			token.RevokedAt = &now
+			s.refresh[hash] = token
+		}
+	}
+	return nil
+}
+
+func (s *StubWorkoutPlanStore) RevokeToken(jti string, expiresAt time.Time) error {
+	if s.revoked == nil {
+		s.revoked = map[string]time.Time{}
+	}
+	s.revoked[jti] = expiresAt
+	return nil
+}
+
+func (s *StubWorkoutPlanStore) RevokeUserTokens(username string, before time.Time) error {
+	if s.revokedUsers == nil {
+		s.revokedUsers = map[string]time.Time{}
+	}
+	s.revokedUsers[username] = before.Truncate(middleware.TokenTimePrecision)
+	now := time.Now()
+	for hash, token := range s.refresh {
+		if token.Username == username {
+			token.RevokedAt = &now
+			s.refresh[hash] = token
+		}
+	}
+	return nil
+}
+
+func (s *StubWorkoutPlanStore) RevokeUserRefreshToken(username, tokenHash string) error {
+	token, ok := s.refresh[tokenHash]
+	if !ok || token.Username != username {
+		return nil
+	}
+	return s.RevokeRefreshTokenFamily(token.FamilyId)
+}
+
+func (s *StubWorkoutPlanStore) IsTokenRevoked(jti, username string, issuedAt time.Time) (bool, error) {
+	if _, ok := s.revoked[jti]; ok {
+		return true, nil
+	}
+	before, ok := s.revokedUsers[username]
+	return ok && issuedAt.Before(before), nil
+}
+
+func (s *StubWorkoutPlanStore) PruneRevokedTokens(now time.Time) (int64, error) {
+	var pruned int64
+	for jti, expiresAt := range s.revoked {
+		if expiresAt.Before(now) {
+			delete(s.revoked, jti)
+			pruned++
+		}
+	}
+	return pruned, nil
+}
+
+func (s *StubWorkoutPlanStore) GetUsersByEmail(email string) ([]UserDetails, error) {
+	users := []UserDetails{}
+	for username, userEmail := range s.emails {
+		if userEmail == email {
+			users = append(users, UserDetails{Username: username, Email: userEmail})
+		}
+	}
+	return users, nil
+}
+
+func (s *StubWorkoutPlanStore) AddPasswordResetToken(token PasswordResetToken) error {
+	if s.resetTokens == nil {
+		s.resetTokens = map[string]PasswordResetToken{}
+	}
+	s.resetTokens[token.TokenHash] = token
+	return nil
+}
+
+func (s *StubWorkoutPlanStore) ResetPassword(tokenHash, password string, now time.Time) (string, error) {
+	token, ok := s.resetTokens[tokenHash]
+	if !ok || token.UsedAt != nil || !token.ExpiresAt.After(now) {
+		return "", api.ErrInvalidResetToken
+	}
+	token.UsedAt = &now
+	s.resetTokens[tokenHash] = token
+	if s.passwords == nil {
+		s.passwords = map[string]string{}
+	}
+	s.passwords[token.Username] = password
+	return token.Username, nil
+}
+
+func (s *StubWorkoutPlanStore) AddEmailVerificationToken(token EmailVerificationToken) error {
+	if s.verifyTokens == nil {
+		s.verifyTokens = map[string]EmailVerificationToken{}
+	}
+	s.verifyTokens[token.TokenHash] = token
+	return nil
+}
+
+func (s *StubWorkoutPlanStore) VerifyEmail(tokenHash string, now time.Time) (string, error) {
+	token, ok := s.verifyTokens[tokenHash]
+	if !ok || token.UsedAt != nil || !token.ExpiresAt.After(now) {
+		return "", api.ErrInvalidVerificationToken
+	}
+	token.UsedAt = &now
+	s.verifyTokens[tokenHash] = token
+	if s.verified == nil {
+		s.verified = map[string]bool{}
+	}
+	s.verified[token.Username] = true
+	return token.Username, nil
+}
+
+func (s *StubWorkoutPlanStore) GetUserRole(username string) (string, error) {
+	if role, ok := s.roles[username]; ok {
+		return role, nil
+	}
+	return middleware.RoleUser, nil
+}
+
+func (s *StubWorkoutPlanStore) GetUserList() ([]UserAccount, error) {
+	users := []UserAccount{}
+	for username, role := range s.roles {
+		users = append(users, UserAccount{Username: username, Role: role})
+	}
+	return users, nil
+}
+
+func (s *StubWorkoutPlanStore) SetUserRole(username, role string) error {
+	if _, ok := s.roles[username]; !ok {
+		return api.ErrUserNotFound
+	}
+	s.roles[username] = role
+	return nil
+}
+
+func (s *StubWorkoutPlanStore) GetLoginFailures(username string) (LoginFailures, error) {
+	return s.failures[username], nil
+}
+
+func (s *StubWorkoutPlanStore) RecordLoginFailure(username string, now time.Time, window time.Duration) (int, error) {
+	if s.failures == nil {
+		s.failures = map[string]LoginFailures{}
+	}
+	failures := s.failures[username]
+	failures.Username = username
+	if failures.FirstFailureAt == nil || !failures.FirstFailureAt.After(now.Add(-window)) {
+		failures.Failures = 0
+		failures.FirstFailureAt = &now
+	}
+	failures.Failures++
+	s.failures[username] = failures
+	return failures.Failures, nil
+}
+
+func (s *StubWorkoutPlanStore) LockLogin(username string, until time.Time) error {
+	if s.failures == nil {
+		s.failures = map[string]LoginFailures{}
+	}
+	failures := s.failures[username]
+	failures.Username = username
+	failures.LockedUntil = &until
+	s.failures[username] = failures
+	return nil
+}
+
+func (s *StubWorkoutPlanStore) ResetLoginFailures(username string) error {
+	delete(s.failures, username)
+	return nil
+}
+
+func (s *StubWorkoutPlanStore) PruneLoginFailures(now time.Time, window time.Duration) (int64, error) {
+	return 0, nil
+}
+
+func (s *StubWorkoutPlanStore) ImportWorkoutData(owner string, plans []WorkoutPlan, sessions []WorkoutSession) error {
+	return nil
+}
+
+func (s *StubWorkoutPlanStore) GetExerciseAliases(owner string) ([]ExerciseAlias, error) {
+	return nil, nil
+}
+
+func (s *StubWorkoutPlanStore) SetExerciseAlias(owner string, alias ExerciseAlias) (ExerciseAlias, error) {
+	return alias, nil
+}
+
+func (s *StubWorkoutPlanStore) DeleteExerciseAlias(owner, alias string) error {
+	return api.ErrExerciseAliasNotFound
+}
+
+// StubMailer records the mail it is asked to send.
+type StubMailer struct {
+	sent []Mail
+}
+
+func (m *StubMailer) Send(mail Mail) error {
+	m.sent = append(m.sent, mail)
+	return nil
 }
 
 func TestStoreWorkoutPlan(t *testing.T) {
@@ -51,7 +458,7 @@ func TestStoreWorkoutPlan(t *testing.T) {
 		Password: "pass",
 	}
 
-	token, _ := JwtGenerator(userDetails)
+	token, _ := JwtGenerator(testConfig.JWT, userDetails)
 
 	t.Run("successfully adds a workout plan", func(t *testing.T) {
 		store := &StubWorkoutPlanStore{}
@@ -60,7 +467,7 @@ func TestStoreWorkoutPlan(t *testing.T) {
 		request.Header.Set("Authorization", "Bearer "+token)
 		response := httptest.NewRecorder()
 
-		server := NewWorkoutServer(store)
+		server := NewWorkoutServer(store, testConfig)
 		server.ServeHTTP(response, request)
 
 		if store.workoutCalls == nil {
@@ -71,20 +478,16 @@ func TestStoreWorkoutPlan(t *testing.T) {
 
 	t.Run("successfully delete workout plans", func(t *testing.T) {
 		store := &StubWorkoutPlanStore{
-			nil,
-			map[string]string{
+			workouts: map[string]string{
 				"pushup": "10 3 20",
 				"pullup": "5 2 20",
 			},
-			nil,
-			0,
-			0,
 		}
 		request, _ := http.NewRequest(http.MethodDelete, "/workout-plans/pushup", nil)
 		request.Header.Set("Authorization", "Bearer "+token)
 		response := httptest.NewRecorder()
 
-		server := NewWorkoutServer(store)
+		server := NewWorkoutServer(store, testConfig)
 		server.ServeHTTP(response, request)
 
 		if _, exists := store.workouts["pushup"]; exists {
@@ -95,20 +498,16 @@ func TestStoreWorkoutPlan(t *testing.T) {
 
 	t.Run("successfully updated workout plan", func(t *testing.T) {
 		store := &StubWorkoutPlanStore{
-			nil,
-			map[string]string{
+			workouts: map[string]string{
 				"pushup": "10 3 20",
 				"pullup": "5 2 20",
 			},
-			nil,
-			0,
-			0,
 		}
 		request, _ := http.NewRequest(http.MethodPut, "/workout-plans/", nil)
 		request.Header.Set("Authorization", "Bearer "+token)
 		response := httptest.NewRecorder()
 
-		server := NewWorkoutServer(store)
+		server := NewWorkoutServer(store, testConfig)
 		server.ServeHTTP(response, request)
 
 		if store.workouts["pushup"] != "updated workout plan" {
@@ -116,6 +515,34 @@ func TestStoreWorkoutPlan(t *testing.T) {
 		}
 		AssertResponseStatus(t, http.StatusNoContent, response.Code)
 	})
+
+	t.Run("scopes workout plans to the token username", func(t *testing.T) {
+		store := &StubWorkoutPlanStore{}
+		reqBody := []byte(`{"exerciseName": "pushup", "repetitions": 10, "sets": 3, "weight": 20}`)
+		request, _ := http.NewRequest(http.MethodPost, "/workout-plans/", bytes.NewBuffer(reqBody))
+		request.Header.Set("Authorization", "Bearer "+token)
+		response := httptest.NewRecorder()
+
+		server := NewWorkoutServer(store, testConfig)
+		server.ServeHTTP(response, request)
+
+		if len(store.owners) != 1 || store.owners[0] != "test" {
+			t.Errorf("Expected workout plan owner %q, got %v", "test", store.owners)
+		}
+		AssertResponseStatus(t, http.StatusCreated, response.Code)
+	})
+
+	t.Run("deleting another user's workout plan returns not found", func(t *testing.T) {
+		store := &StubWorkoutPlanStore{workouts: map[string]string{}}
+		request, _ := http.NewRequest(http.MethodDelete, "/workout-plans/pushup", nil)
+		request.Header.Set("Authorization", "Bearer "+token)
+		response := httptest.NewRecorder()
+
+		server := NewWorkoutServer(store, testConfig)
+		server.ServeHTTP(response, request)
+
+		AssertResponseStatus(t, http.StatusNotFound, response.Code)
+	})
 }
 
 func TestGetWorkoutPlanList(t *testing.T) {
@@ -124,7 +551,7 @@ func TestGetWorkoutPlanList(t *testing.T) {
 		Password: "pass",
 	}
 
-	token, _ := JwtGenerator(userDetails)
+	token, _ := JwtGenerator(testConfig.JWT, userDetails)
 
 	workoutplan := []WorkoutPlan{
 		{
@@ -141,20 +568,17 @@ func TestGetWorkoutPlanList(t *testing.T) {
 		},
 	}
 	store := &StubWorkoutPlanStore{
-		nil,
-		make(map[string]string),
-		workoutplan,
-		0,
-		0,
+		workouts:     make(map[string]string),
+		workoutPlans: workoutplan,
 	}
-	server := NewWorkoutServer(store)
+	server := NewWorkoutServer(store, testConfig)
 	request, _ := http.NewRequest(http.MethodGet, "/workouts", nil)
 	request.Header.Set("Authorization", "Bearer "+token)
 	response := httptest.NewRecorder()
 
 	server.ServeHTTP(response, request)
 
-	jsonResponse := fmt.Sprintf(`[{"ExerciseName":"%s","Repetitions":%d,"Sets":%d,"Weight":%d},{"ExerciseName":"%s","Repetitions":%d,"Sets":%d,"Weight":%d}]`,
+	jsonResponse := fmt.Sprintf(`{"workout_plans":[{"ExerciseName":"%s","Repetitions":%d,"Sets":%d,"Weight":%d},{"ExerciseName":"%s","Repetitions":%d,"Sets":%d,"Weight":%d}]}`,
 		workoutplan[0].ExerciseName, workoutplan[0].Repititions, workoutplan[0].Sets, workoutplan[0].Weight,
 		workoutplan[1].ExerciseName, workoutplan[1].Repititions, workoutplan[1].Sets, workoutplan[1].Weight)
 
@@ -166,23 +590,107 @@ func TestGetWorkoutPlanList(t *testing.T) {
 
 }
 
+func TestWorkoutPlanListQuery(t *testing.T) {
+	token, _ := JwtGenerator(testConfig.JWT, LoginData{Username: "test"})
+	plans := []WorkoutPlan{}
+	for id := 1; id <= 5; id++ {
+		plans = append(plans, WorkoutPlan{Id: id, ExerciseName: fmt.Sprintf("exercise %d", id), Repititions: id, Sets: 3, Weight: 10 * id})
+	}
+	store := &StubWorkoutPlanStore{workoutPlans: plans}
+	server := NewWorkoutServer(store, testConfig)
+
+	list := func(query string) (*httptest.ResponseRecorder, WorkoutPlanPage) {
+		request, _ := http.NewRequest(http.MethodGet, "/workouts"+query, nil)
+		request.Header.Set("Authorization", "Bearer "+token)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		page := WorkoutPlanPage{}
+		json.NewDecoder(response.Body).Decode(&page)
+		return response, page
+	}
+
+	t.Run("passes filters and sorting to the store", func(t *testing.T) {
+		response, _ := list("?exercise_name=push&min_weight=0&max_weight=50&min_reps=5&max_reps=12&min_sets=2&max_sets=4&sort=weight&order=desc")
+		AssertResponseStatus(t, http.StatusOK, response.Code)
+
+		query := store.planQueries[len(store.planQueries)-1]
+		bounds := []*int{query.MinWeight, query.MaxWeight, query.MinReps, query.MaxReps, query.MinSets, query.MaxSets}
+		expected := []int{0, 50, 5, 12, 2, 4}
+		for i, bound := range bounds {
+			if bound == nil || *bound != expected[i] {
+				t.Errorf("Expected bound %d to be %d, got %v", i, expected[i], bound)
+			}
+		}
+		if query.ExerciseName != "push" || query.Sort != "weight" || !query.Desc {
+			t.Errorf("Expected name push sorted by weight descending, got %+v", query)
+		}
+		if query.Limit != defaultWorkoutPlanLimit+1 {
+			t.Errorf("Expected the store to be asked for %d plans, got %d", defaultWorkoutPlanLimit+1, query.Limit)
+		}
+	})
+
+	t.Run("pages through the plans with next_cursor", func(t *testing.T) {
+		ids := []int{}
+		cursor := ""
+		for pages := 0; pages < 10; pages++ {
+			response, page := list("?limit=2&cursor=" + cursor)
+			AssertResponseStatus(t, http.StatusOK, response.Code)
+			for _, plan := range page.WorkoutPlans {
+				ids = append(ids, plan.Id)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			cursor = page.NextCursor
+		}
+		if fmt.Sprint(ids) != "[1 2 3 4 5]" {
+			t.Errorf("Expected plans 1 to 5 once each, got %v", ids)
+		}
+	})
+
+	t.Run("no next_cursor when the page is not full", func(t *testing.T) {
+		_, page := list("?limit=5")
+		if len(page.WorkoutPlans) != 5 || page.NextCursor != "" {
+			t.Errorf("Expected 5 plans and no cursor, got %d plans and cursor %q", len(page.WorkoutPlans), page.NextCursor)
+		}
+	})
+
+	t.Run("rejects invalid parameters", func(t *testing.T) {
+		weightCursor := newWorkoutPlanCursor(WorkoutPlanQuery{Sort: "weight"}, plans[0]).String()
+		for _, query := range []string{
+			"?min_weight=heavy",
+			"?sort=owner",
+			"?order=sideways",
+			"?limit=0",
+			"?limit=1000",
+			"?cursor=not-a-cursor",
+			"?cursor=" + weightCursor,
+		} {
+			response, _ := list(query)
+			AssertResponseStatus(t, http.StatusBadRequest, response.Code)
+		}
+	})
+
+	t.Run("a cursor keeps the sort value of the last plan", func(t *testing.T) {
+		cursor := newWorkoutPlanCursor(WorkoutPlanQuery{Sort: "reps", Desc: true}, plans[3])
+		decoded, err := decodeWorkoutPlanCursor(cursor.String())
+		if err != nil || decoded != cursor || decoded.Number != 4 {
+			t.Errorf("Expected cursor %+v, got %+v (%v)", cursor, decoded, err)
+		}
+	})
+}
+
 func TestUserRegistration(t *testing.T) {
 	userDetails := LoginData{
 		Username: "test",
 		Password: "pass",
 	}
 
-	token, _ := JwtGenerator(userDetails)
+	token, _ := JwtGenerator(testConfig.JWT, userDetails)
 	t.Run("successfully registers a user", func(t *testing.T) {
-		store := &StubWorkoutPlanStore{
-			nil,
-			nil,
-			nil,
-			0,
-			0,
-		}
+		store := &StubWorkoutPlanStore{}
 
-		server := NewWorkoutServer(store)
+		server := NewWorkoutServer(store, testConfig)
 		//reqBody := []byte(`{"username": "testuser", "password": "testpass", "email": "test@gmail.com"}`)
 		reqBody := []byte(`{"username": "testuser", "password": "testpass", "email": "test@gmail.com"}`)
 		request, _ := http.NewRequest(http.MethodPost, "/auth/register", bytes.NewBuffer(reqBody))
@@ -198,24 +706,93 @@ func TestUserRegistration(t *testing.T) {
 	})
 }
 
+func TestEmailVerification(t *testing.T) {
+	store := &StubWorkoutPlanStore{}
+	mailer := &StubMailer{}
+	server := newTestServer(store)
+	server.SetMailer(mailer)
+
+	// mailedLink returns the verification link path of the last mail.
+	mailedLink := func(t *testing.T) string {
+		t.Helper()
+		if len(mailer.sent) == 0 {
+			t.Fatal("Expected a verification mail")
+		}
+		body := mailer.sent[len(mailer.sent)-1].Body
+		start := strings.Index(body, "/auth/verify?token=")
+		if start < 0 {
+			t.Fatalf("Expected the mail to contain a verification link, got %q", body)
+		}
+		return strings.Fields(body[start:])[0]
+	}
+
+	t.Run("registration mails a verification link", func(t *testing.T) {
+		response := server.send(http.MethodPost, "/auth/register", "", `{"username": "alice", "password": "testpass", "email": "alice@example.com"}`)
+		AssertResponseStatus(t, http.StatusCreated, response.Code)
+
+		if mailer.sent[0].To != "alice@example.com" {
+			t.Errorf("Expected mail to alice@example.com, got %s", mailer.sent[0].To)
+		}
+		if store.verified["alice"] {
+			t.Error("Expected a new account to be unverified")
+		}
+	})
+
+	t.Run("the link verifies the email once", func(t *testing.T) {
+		server.send(http.MethodPost, "/auth/register", "", `{"username": "bob", "password": "testpass", "email": "bob@example.com"}`)
+		link := mailedLink(t)
+
+		response := server.send(http.MethodGet, link, "", "")
+		AssertResponseStatus(t, http.StatusOK, response.Code)
+		verification := EmailVerification{}
+		json.NewDecoder(response.Body).Decode(&verification)
+		if verification.Username != "bob" || !verification.EmailVerified {
+			t.Errorf("Expected bob to be verified, got %+v", verification)
+		}
+
+		AssertResponseStatus(t, http.StatusBadRequest, server.send(http.MethodGet, link, "", "").Code)
+	})
+
+	t.Run("rejects unknown tokens", func(t *testing.T) {
+		AssertResponseStatus(t, http.StatusBadRequest, server.send(http.MethodGet, "/auth/verify?token=unknown", "", "").Code)
+		AssertResponseStatus(t, http.StatusBadRequest, server.send(http.MethodGet, "/auth/verify", "", "").Code)
+	})
+
+	t.Run("rejects invalid email addresses", func(t *testing.T) {
+		added := store.userAdded
+		for _, email := range []string{"not-an-email", "Carol <carol@example.com>"} {
+			response := server.send(http.MethodPost, "/auth/register", "", `{"username": "carol", "password": "testpass", "email": "`+email+`"}`)
+			AssertResponseStatus(t, http.StatusBadRequest, response.Code)
+		}
+		if store.userAdded != added {
+			t.Error("Expected no user to be added")
+		}
+	})
+
+	t.Run("unverified users can log in unless verification is required", func(t *testing.T) {
+		AssertResponseStatus(t, http.StatusOK, server.tryLogin("alice", "testpass").Code)
+
+		cfg := testConfig
+		cfg.Auth.RequireEmailVerification = true
+		server = newTestServerWithConfig(store, cfg)
+
+		AssertResponseStatus(t, http.StatusForbidden, server.tryLogin("alice", "testpass").Code)
+		AssertResponseStatus(t, http.StatusOK, server.tryLogin("bob", "testpass").Code)
+	})
+}
+
 func TestUserLogin(t *testing.T) {
 	userDetails := LoginData{
 		Username: "test",
 		Password: "pass",
 	}
 
-	token, _ := JwtGenerator(userDetails)
+	token, _ := JwtGenerator(testConfig.JWT, userDetails)
 	t.Run("successfully, login", func(t *testing.T) {
 
-		store := &StubWorkoutPlanStore{
-			nil,
-			nil,
-			nil,
-			0,
-			0,
-		}
+		store := &StubWorkoutPlanStore{}
 
-		server := NewWorkoutServer(store)
+		server := NewWorkoutServer(store, testConfig)
 		reqBody := []byte(`{"username": "testuser", "password": "testpass"}`)
 		request, _ := http.NewRequest(http.MethodPost, "/auth/login", bytes.NewBuffer(reqBody))
 		request.Header.Set("Authorization", "Bearer "+token)
@@ -230,6 +807,515 @@ func TestUserLogin(t *testing.T) {
 	})
 }
 
+func TestExerciseCatalog(t *testing.T) {
+	userToken, _ := JwtGenerator(testConfig.JWT, LoginData{Username: "test", Role: middleware.RoleUser})
+	adminToken, _ := JwtGenerator(testConfig.JWT, LoginData{Username: "admin", Role: middleware.RoleAdmin})
+
+	newStore := func() *StubWorkoutPlanStore {
+		return &StubWorkoutPlanStore{exercises: map[int]Exercise{
+			1: {Id: 1, Name: "pushup", Description: "bodyweight exercise", Category: "strength"},
+		}}
+	}
+
+	t.Run("lists exercises with category and name filters", func(t *testing.T) {
+		store := newStore()
+		request, _ := http.NewRequest(http.MethodGet, "/exercises?category=strength&name=push", nil)
+		request.Header.Set("Authorization", "Bearer "+userToken)
+		response := httptest.NewRecorder()
+
+		NewWorkoutServer(store, testConfig).ServeHTTP(response, request)
+
+		AssertResponseStatus(t, http.StatusOK, response.Code)
+		expectedFilter := ExerciseFilter{Category: "strength", Name: "push"}
+		if len(store.filters) != 1 || store.filters[0] != expectedFilter {
+			t.Errorf("Expected filter %+v, got %+v", expectedFilter, store.filters)
+		}
+		expectedBody := `[{"id":1,"name":"pushup","description":"bodyweight exercise","category":"strength"}]`
+		if strings.TrimSpace(response.Body.String()) != expectedBody {
+			t.Errorf("Expected body %s, got %s", expectedBody, response.Body.String())
+		}
+	})
+
+	t.Run("returns not found for an unknown exercise", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodGet, "/exercises/42", nil)
+		request.Header.Set("Authorization", "Bearer "+userToken)
+		response := httptest.NewRecorder()
+
+		NewWorkoutServer(newStore(), testConfig).ServeHTTP(response, request)
+
+		AssertResponseStatus(t, http.StatusNotFound, response.Code)
+	})
+
+	t.Run("only admins can create exercises", func(t *testing.T) {
+		store := newStore()
+		server := NewWorkoutServer(store, testConfig)
+		reqBody := `{"name": "squat", "description": "barbell", "category": "strength"}`
+
+		request, _ := http.NewRequest(http.MethodPost, "/exercises", strings.NewReader(reqBody))
+		request.Header.Set("Authorization", "Bearer "+userToken)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		AssertResponseStatus(t, http.StatusForbidden, response.Code)
+
+		request, _ = http.NewRequest(http.MethodPost, "/exercises", strings.NewReader(reqBody))
+		request.Header.Set("Authorization", "Bearer "+adminToken)
+		response = httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		AssertResponseStatus(t, http.StatusCreated, response.Code)
+		if store.exercises[2].Name != "squat" {
+			t.Errorf("Expected exercise squat to be created, got %+v", store.exercises)
+		}
+	})
+
+	t.Run("admin deletes an exercise", func(t *testing.T) {
+		store := newStore()
+		request, _ := http.NewRequest(http.MethodDelete, "/exercises/1", nil)
+		request.Header.Set("Authorization", "Bearer "+adminToken)
+		response := httptest.NewRecorder()
+
+		NewWorkoutServer(store, testConfig).ServeHTTP(response, request)
+
+		AssertResponseStatus(t, http.StatusNoContent, response.Code)
+		if _, exists := store.exercises[1]; exists {
+			t.Error("Expected exercise 1 to be deleted, but it still exists.")
+		}
+	})
+}
+
+func TestWorkoutSessions(t *testing.T) {
+	token, _ := JwtGenerator(testConfig.JWT, LoginData{Username: "test"})
+	otherToken, _ := JwtGenerator(testConfig.JWT, LoginData{Username: "other"})
+
+	newStore := func() *StubWorkoutPlanStore {
+		return &StubWorkoutPlanStore{sessions: map[int]WorkoutSession{}, sessionOwner: map[int]string{}}
+	}
+
+	t.Run("logs a session with its sets", func(t *testing.T) {
+		store := newStore()
+		reqBody := `{"started_at": "2025-06-02T07:00:00Z", "ended_at": "2025-06-02T08:00:00Z",
+			"exercises": [{"exercise_id": 1, "sets": [{"reps": 10, "weight": 20, "rpe": 8}, {"reps": 8, "weight": 22.5}]}]}`
+		request, _ := http.NewRequest(http.MethodPost, "/sessions", strings.NewReader(reqBody))
+		request.Header.Set("Authorization", "Bearer "+token)
+		response := httptest.NewRecorder()
+
+		NewWorkoutServer(store, testConfig).ServeHTTP(response, request)
+
+		AssertResponseStatus(t, http.StatusCreated, response.Code)
+		session := store.sessions[1]
+		if store.sessionOwner[1] != "test" || len(session.Exercises) != 1 || len(session.Exercises[0].Sets) != 2 {
+			t.Fatalf("Expected session with one exercise and two sets owned by test, got %+v", session)
+		}
+		if session.Exercises[0].Sets[1].Weight != 22.5 {
+			t.Errorf("Expected weight 22.5, got %v", session.Exercises[0].Sets[1].Weight)
+		}
+	})
+
+	t.Run("rejects a session that ends before it starts", func(t *testing.T) {
+		reqBody := `{"started_at": "2025-06-02T07:00:00Z", "ended_at": "2025-06-02T06:00:00Z"}`
+		request, _ := http.NewRequest(http.MethodPost, "/sessions", strings.NewReader(reqBody))
+		request.Header.Set("Authorization", "Bearer "+token)
+		response := httptest.NewRecorder()
+
+		NewWorkoutServer(newStore(), testConfig).ServeHTTP(response, request)
+
+		AssertResponseStatus(t, http.StatusBadRequest, response.Code)
+	})
+
+	t.Run("rejects an RPE outside 1 to 10", func(t *testing.T) {
+		store := newStore()
+		store.AddWorkoutSession("test", WorkoutSession{})
+		request, _ := http.NewRequest(http.MethodPost, "/sessions/1/sets", strings.NewReader(`{"exercise_id": 1, "reps": 5, "rpe": 11}`))
+		request.Header.Set("Authorization", "Bearer "+token)
+		response := httptest.NewRecorder()
+
+		NewWorkoutServer(store, testConfig).ServeHTTP(response, request)
+
+		AssertResponseStatus(t, http.StatusBadRequest, response.Code)
+	})
+
+	t.Run("another user's session is not found", func(t *testing.T) {
+		store := newStore()
+		store.AddWorkoutSession("test", WorkoutSession{})
+		request, _ := http.NewRequest(http.MethodGet, "/sessions/1", nil)
+		request.Header.Set("Authorization", "Bearer "+otherToken)
+		response := httptest.NewRecorder()
+
+		NewWorkoutServer(store, testConfig).ServeHTTP(response, request)
+
+		AssertResponseStatus(t, http.StatusNotFound, response.Code)
+	})
+}
+
+func TestSchedule(t *testing.T) {
+	token, _ := JwtGenerator(testConfig.JWT, LoginData{Username: "test"})
+	planId := 7
+
+	store := &StubWorkoutPlanStore{
+		sessions:     map[int]WorkoutSession{},
+		sessionOwner: map[int]string{},
+		schedules: []WorkoutSchedule{{
+			Id:           1,
+			PlanId:       planId,
+			ExerciseName: "pushup",
+			StartsAt:     time.Date(2025, 6, 2, 5, 0, 0, 0, time.UTC),
+			TimeZone:     "Europe/Berlin",
+			RRule:        "FREQ=WEEKLY;BYDAY=MO,WE,FR",
+		}},
+	}
+	store.AddWorkoutSession("test", WorkoutSession{PlanId: &planId, StartedAt: time.Date(2025, 6, 4, 6, 0, 0, 0, time.UTC)})
+	server := NewWorkoutServer(store, testConfig)
+
+	t.Run("expands occurrences sorted by date", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodGet, "/schedule?from=2025-06-01&to=2025-06-08", nil)
+		request.Header.Set("Authorization", "Bearer "+token)
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		AssertResponseStatus(t, http.StatusOK, response.Code)
+		var occurrences []ScheduledOccurrence
+		json.NewDecoder(response.Body).Decode(&occurrences)
+
+		expected := []string{"2025-06-02T07:00:00+02:00", "2025-06-04T07:00:00+02:00", "2025-06-06T07:00:00+02:00"}
+		if len(occurrences) != len(expected) {
+			t.Fatalf("Expected %d occurrences, got %+v", len(expected), occurrences)
+		}
+		for i, occurrence := range occurrences {
+			if occurrence.ScheduledAt.Format(time.RFC3339) != expected[i] {
+				t.Errorf("Expected occurrence at %s, got %s", expected[i], occurrence.ScheduledAt.Format(time.RFC3339))
+			}
+		}
+		if occurrences[1].Status != OccurrenceCompleted || occurrences[0].Status != OccurrencePending {
+			t.Errorf("Expected only the Wednesday occurrence to be completed, got %+v", occurrences)
+		}
+	})
+
+	t.Run("filters occurrences by status", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodGet, "/schedule?from=2025-06-01&to=2025-06-08&status=pending", nil)
+		request.Header.Set("Authorization", "Bearer "+token)
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		var occurrences []ScheduledOccurrence
+		json.NewDecoder(response.Body).Decode(&occurrences)
+		if len(occurrences) != 2 {
+			t.Errorf("Expected 2 pending occurrences, got %+v", occurrences)
+		}
+	})
+
+	t.Run("rejects an invalid recurrence rule", func(t *testing.T) {
+		reqBody := `{"plan_id": 7, "starts_at": "2025-06-02T07:00:00+02:00", "time_zone": "Europe/Berlin", "rrule": "FREQ=HOURLY"}`
+		request, _ := http.NewRequest(http.MethodPost, "/schedules", strings.NewReader(reqBody))
+		request.Header.Set("Authorization", "Bearer "+token)
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		AssertResponseStatus(t, http.StatusBadRequest, response.Code)
+	})
+}
+
+func TestRefreshToken(t *testing.T) {
+	store := &StubWorkoutPlanStore{}
+	server := newTestServer(store)
+
+	refresh := func(refreshToken string) (*httptest.ResponseRecorder, Token) {
+		response := server.send(http.MethodPost, "/auth/refresh", "", `{"refresh_token": "`+refreshToken+`"}`)
+		token := Token{}
+		json.NewDecoder(response.Body).Decode(&token)
+		return response, token
+	}
+
+	t.Run("login returns a short-lived access token and a refresh token", func(t *testing.T) {
+		token := server.loginTokens(t, "testuser")
+		if token.Token == "" || token.RefreshToken == "" {
+			t.Fatalf("Expected access and refresh tokens, got %+v", token)
+		}
+		if token.ExpiresIn != int(testConfig.JWT.AccessTTL.Seconds()) {
+			t.Errorf("Expected expires_in %d, got %d", int(testConfig.JWT.AccessTTL.Seconds()), token.ExpiresIn)
+		}
+		if _, stored := store.refresh[token.RefreshToken]; stored {
+			t.Error("Expected only the hash of the refresh token to be stored")
+		}
+	})
+
+	t.Run("refresh rotates the refresh token", func(t *testing.T) {
+		first := server.loginTokens(t, "testuser")
+		response, second := refresh(first.RefreshToken)
+
+		AssertResponseStatus(t, http.StatusOK, response.Code)
+		if second.Token == "" || second.RefreshToken == "" || second.RefreshToken == first.RefreshToken {
+			t.Errorf("Expected a new token pair, got %+v", second)
+		}
+	})
+
+	t.Run("reusing a rotated token revokes the family", func(t *testing.T) {
+		first := server.loginTokens(t, "testuser")
+		_, second := refresh(first.RefreshToken)
+
+		response, _ := refresh(first.RefreshToken)
+		AssertResponseStatus(t, http.StatusUnauthorized, response.Code)
+
+		response, _ = refresh(second.RefreshToken)
+		AssertResponseStatus(t, http.StatusUnauthorized, response.Code)
+	})
+
+	t.Run("rejects an unknown refresh token", func(t *testing.T) {
+		response, _ := refresh("unknown")
+		AssertResponseStatus(t, http.StatusUnauthorized, response.Code)
+	})
+}
+
+func TestLogout(t *testing.T) {
+	store := &StubWorkoutPlanStore{}
+	server := newTestServer(store)
+
+	t.Run("tokens carry a unique jti", func(t *testing.T) {
+		first, _ := JwtGenerator(testConfig.JWT, LoginData{Username: "test"})
+		second, _ := JwtGenerator(testConfig.JWT, LoginData{Username: "test"})
+		if first == second {
+			t.Error("Expected tokens issued in the same second to differ")
+		}
+	})
+
+	t.Run("logout revokes the access token", func(t *testing.T) {
+		token := server.loginTokens(t, "alice")
+		AssertResponseStatus(t, http.StatusOK, server.send(http.MethodGet, "/sessions", token.Token, "").Code)
+
+		response := server.send(http.MethodPost, "/auth/logout", token.Token, "")
+		AssertResponseStatus(t, http.StatusNoContent, response.Code)
+
+		AssertResponseStatus(t, http.StatusUnauthorized, server.send(http.MethodGet, "/sessions", token.Token, "").Code)
+		AssertResponseStatus(t, http.StatusUnauthorized, server.send(http.MethodPost, "/auth/logout", token.Token, "").Code)
+	})
+
+	t.Run("logout revokes the given refresh token", func(t *testing.T) {
+		token := server.loginTokens(t, "bob")
+		response := server.send(http.MethodPost, "/auth/logout", token.Token, `{"refresh_token": "`+token.RefreshToken+`"}`)
+		AssertResponseStatus(t, http.StatusNoContent, response.Code)
+
+		response = server.send(http.MethodPost, "/auth/refresh", "", `{"refresh_token": "`+token.RefreshToken+`"}`)
+		AssertResponseStatus(t, http.StatusUnauthorized, response.Code)
+	})
+
+	t.Run("logout leaves other users' refresh tokens alone", func(t *testing.T) {
+		victim := server.loginTokens(t, "carol")
+		attacker := server.loginTokens(t, "mallory")
+		server.send(http.MethodPost, "/auth/logout", attacker.Token, `{"refresh_token": "`+victim.RefreshToken+`"}`)
+
+		if store.refresh[hashToken(victim.RefreshToken)].RevokedAt != nil {
+			t.Error("Expected the refresh token of another user not to be revoked")
+		}
+	})
+
+	t.Run("logout-all revokes every token of the user", func(t *testing.T) {
+		first := server.loginTokens(t, "dave")
+		second := server.loginTokens(t, "dave")
+		other := server.loginTokens(t, "erin")
+
+		response := server.send(http.MethodPost, "/auth/logout-all", first.Token, "")
+		AssertResponseStatus(t, http.StatusNoContent, response.Code)
+
+		AssertResponseStatus(t, http.StatusUnauthorized, server.send(http.MethodGet, "/sessions", first.Token, "").Code)
+		AssertResponseStatus(t, http.StatusUnauthorized, server.send(http.MethodGet, "/sessions", second.Token, "").Code)
+		AssertResponseStatus(t, http.StatusOK, server.send(http.MethodGet, "/sessions", other.Token, "").Code)
+		if store.refresh[hashToken(second.RefreshToken)].RevokedAt == nil {
+			t.Error("Expected the refresh tokens of the user to be revoked")
+		}
+	})
+
+	t.Run("logging in again in the second of a logout-all", func(t *testing.T) {
+		// Start at a whole second so the logins and the logout-all share it
+		time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+		first := server.loginTokens(t, "frank")
+		AssertResponseStatus(t, http.StatusNoContent, server.send(http.MethodPost, "/auth/logout-all", first.Token, "").Code)
+		second := server.loginTokens(t, "frank")
+
+		AssertResponseStatus(t, http.StatusUnauthorized, server.send(http.MethodGet, "/sessions", first.Token, "").Code)
+		AssertResponseStatus(t, http.StatusOK, server.send(http.MethodGet, "/sessions", second.Token, "").Code)
+	})
+
+	t.Run("tokens of another key are unauthorized", func(t *testing.T) {
+		cfg := testConfig
+		cfg.JWT.Key = "other-key"
+		forged, _ := JwtGenerator(cfg.JWT, LoginData{Username: "frank", Role: "user"})
+		AssertResponseStatus(t, http.StatusUnauthorized, server.send(http.MethodGet, "/sessions", forged, "").Code)
+	})
+
+	t.Run("tokens of another algorithm are unauthorized", func(t *testing.T) {
+		forged, _ := jwt.NewWithClaims(jwt.SigningMethodHS384, jwt.MapClaims{
+			"username": "grace",
+			"role":     "user",
+			"jti":      "forged",
+			"iat":      float64(time.Now().UnixMicro()) / 1e6,
+			"exp":      time.Now().Add(time.Minute).Unix(),
+		}).SignedString([]byte(testConfig.JWT.Key))
+		AssertResponseStatus(t, http.StatusUnauthorized, server.send(http.MethodGet, "/sessions", forged, "").Code)
+	})
+
+	t.Run("logout requires a token", func(t *testing.T) {
+		response := server.send(http.MethodPost, "/auth/logout", "", "")
+		AssertResponseStatus(t, http.StatusUnauthorized, response.Code)
+	})
+
+	t.Run("pruning drops expired revocations", func(t *testing.T) {
+		store.RevokeToken("expired", time.Now().Add(-time.Minute))
+		store.RevokeToken("valid", time.Now().Add(time.Minute))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		PruneRevokedTokens(ctx, store, time.Hour)
+
+		if _, ok := store.revoked["expired"]; ok {
+			t.Error("Expected the expired revocation to be pruned")
+		}
+		if _, ok := store.revoked["valid"]; !ok {
+			t.Error("Expected the valid revocation to be kept")
+		}
+	})
+}
+
+func TestPasswordReset(t *testing.T) {
+	store := &StubWorkoutPlanStore{emails: map[string]string{"alice": "alice@example.com"}}
+	mailer := &StubMailer{}
+	server := newTestServer(store)
+	server.SetMailer(mailer)
+
+	// mailedToken returns the token from the last line holding one in the mail.
+	mailedToken := func(t *testing.T) string {
+		t.Helper()
+		if len(mailer.sent) == 0 {
+			t.Fatal("Expected a password reset mail")
+		}
+		mail := mailer.sent[len(mailer.sent)-1]
+		for _, line := range strings.Split(mail.Body, "\n") {
+			if store.resetTokens[hashToken(line)].Username != "" {
+				return line
+			}
+		}
+		t.Fatalf("Expected the mail to contain the reset token, got %q", mail.Body)
+		return ""
+	}
+
+	t.Run("forgot mails a reset token to the user", func(t *testing.T) {
+		response := server.send(http.MethodPost, "/auth/password/forgot", "", `{"email": "alice@example.com"}`)
+		AssertResponseStatus(t, http.StatusAccepted, response.Code)
+
+		token := mailedToken(t)
+		if mailer.sent[0].To != "alice@example.com" {
+			t.Errorf("Expected mail to alice@example.com, got %s", mailer.sent[0].To)
+		}
+		if _, stored := store.resetTokens[token]; stored {
+			t.Error("Expected only the hash of the reset token to be stored")
+		}
+	})
+
+	t.Run("forgot does not reveal unknown emails", func(t *testing.T) {
+		sent := len(mailer.sent)
+		response := server.send(http.MethodPost, "/auth/password/forgot", "", `{"email": "nobody@example.com"}`)
+
+		AssertResponseStatus(t, http.StatusAccepted, response.Code)
+		if len(mailer.sent) != sent {
+			t.Error("Expected no mail for an unknown email")
+		}
+	})
+
+	t.Run("reset sets the password once", func(t *testing.T) {
+		server.send(http.MethodPost, "/auth/password/forgot", "", `{"email": "alice@example.com"}`)
+		token := mailedToken(t)
+		store.LockLogin("alice", time.Now().Add(time.Hour))
+
+		response := server.send(http.MethodPost, "/auth/password/reset", "", `{"token": "`+token+`", "password": "newpass"}`)
+		AssertResponseStatus(t, http.StatusNoContent, response.Code)
+		if store.passwords["alice"] != "newpass" {
+			t.Errorf("Expected the password to be reset, got %q", store.passwords["alice"])
+		}
+		if _, revoked := store.revokedUsers["alice"]; !revoked {
+			t.Error("Expected the tokens of the user to be revoked")
+		}
+		if failures, _ := store.GetLoginFailures("alice"); failures.LockedUntil != nil {
+			t.Errorf("Expected the reset to lift the lockout, got %+v", failures)
+		}
+
+		response = server.send(http.MethodPost, "/auth/password/reset", "", `{"token": "`+token+`", "password": "again"}`)
+		AssertResponseStatus(t, http.StatusBadRequest, response.Code)
+	})
+
+	t.Run("reset rejects expired and unknown tokens", func(t *testing.T) {
+		store.AddPasswordResetToken(PasswordResetToken{
+			TokenHash: hashToken("expired"),
+			Username:  "alice",
+			ExpiresAt: time.Now().Add(-time.Minute),
+		})
+
+		AssertResponseStatus(t, http.StatusBadRequest, server.send(http.MethodPost, "/auth/password/reset", "", `{"token": "expired", "password": "newpass"}`).Code)
+		AssertResponseStatus(t, http.StatusBadRequest, server.send(http.MethodPost, "/auth/password/reset", "", `{"token": "unknown", "password": "newpass"}`).Code)
+	})
+
+	t.Run("reset requires a password", func(t *testing.T) {
+		server.send(http.MethodPost, "/auth/password/forgot", "", `{"email": "alice@example.com"}`)
+		response := server.send(http.MethodPost, "/auth/password/reset", "", `{"token": "`+mailedToken(t)+`"}`)
+		AssertResponseStatus(t, http.StatusBadRequest, response.Code)
+	})
+}
+
+func TestRoles(t *testing.T) {
+	store := &StubWorkoutPlanStore{roles: map[string]string{
+		"admin": middleware.RoleAdmin,
+		"coach": middleware.RoleCoach,
+		"alice": middleware.RoleUser,
+	}}
+	server := newTestServer(store)
+
+	t.Run("the role is part of the token claims", func(t *testing.T) {
+		token, _ := jwt.Parse(server.login(t, "coach"), func(token *jwt.Token) (interface{}, error) {
+			return []byte(testConfig.JWT.Key), nil
+		})
+		claims, _ := token.Claims.(jwt.MapClaims)
+		if claims["role"] != middleware.RoleCoach {
+			t.Errorf("Expected role claim %q, got %v", middleware.RoleCoach, claims["role"])
+		}
+	})
+
+	t.Run("only admins manage users", func(t *testing.T) {
+		AssertResponseStatus(t, http.StatusForbidden, server.send(http.MethodGet, "/users", server.login(t, "alice"), "").Code)
+		AssertResponseStatus(t, http.StatusForbidden, server.send(http.MethodGet, "/users", server.login(t, "coach"), "").Code)
+
+		response := server.send(http.MethodGet, "/users", server.login(t, "admin"), "")
+		AssertResponseStatus(t, http.StatusOK, response.Code)
+		var users []UserAccount
+		json.NewDecoder(response.Body).Decode(&users)
+		if len(users) != 3 {
+			t.Errorf("Expected 3 users, got %d", len(users))
+		}
+	})
+
+	t.Run("admins change roles and the user is signed out", func(t *testing.T) {
+		aliceToken := server.login(t, "alice")
+		response := server.send(http.MethodPut, "/users/alice/role", server.login(t, "admin"), `{"role": "coach"}`)
+		AssertResponseStatus(t, http.StatusNoContent, response.Code)
+
+		if store.roles["alice"] != middleware.RoleCoach {
+			t.Errorf("Expected alice to be a coach, got %q", store.roles["alice"])
+		}
+		AssertResponseStatus(t, http.StatusUnauthorized, server.send(http.MethodGet, "/sessions", aliceToken, "").Code)
+	})
+
+	t.Run("rejects unknown roles and users", func(t *testing.T) {
+		adminToken := server.login(t, "admin")
+		AssertResponseStatus(t, http.StatusBadRequest, server.send(http.MethodPut, "/users/alice/role", adminToken, `{"role": "owner"}`).Code)
+		AssertResponseStatus(t, http.StatusNotFound, server.send(http.MethodPut, "/users/nobody/role", adminToken, `{"role": "coach"}`).Code)
+	})
+
+	t.Run("users cannot promote themselves", func(t *testing.T) {
+		response := server.send(http.MethodPut, "/users/coach/role", server.login(t, "coach"), `{"role": "admin"}`)
+		AssertResponseStatus(t, http.StatusForbidden, response.Code)
+	})
+}
+
 func AssertResponseStatus(t *testing.T, expected, got int) {
 	t.Helper()
 	if expected != got {
@@ -0,0 +1,240 @@
+package tracker
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Oriseer/workout_tracker/api"
+	"github.com/Oriseer/workout_tracker/middleware"
+)
+
+// WorkoutSession records a workout that was actually performed. It may link
+// to the plan it followed so planned and performed work can be compared.
+type WorkoutSession struct {
+	Id        int               `json:"id" db:"id"`
+	PlanId    *int              `json:"plan_id,omitempty" db:"plan_id"`
+	Plan      *WorkoutPlan      `json:"plan,omitempty" db:"-"`
+	StartedAt time.Time         `json:"started_at" db:"started_at"`
+	EndedAt   *time.Time        `json:"ended_at,omitempty" db:"ended_at"`
+	Notes     string            `json:"notes" db:"notes"`
+	Exercises []SessionExercise `json:"exercises" db:"-"`
+}
+
+// SessionExercise is a catalog exercise performed during a session, given by
+// ExerciseId or, when no id is set, by ExerciseName.
+type SessionExercise struct {
+	Id           int         `json:"id" db:"id"`
+	SessionId    int         `json:"-" db:"session_id"`
+	ExerciseId   int         `json:"exercise_id" db:"exercise_id"`
+	ExerciseName string      `json:"exercise_name" db:"exercise_name"`
+	Sets         []LoggedSet `json:"sets" db:"-"`
+}
+
+type LoggedSet struct {
+	Id                int      `json:"id" db:"id"`
+	SessionExerciseId int      `json:"-" db:"session_exercise_id"`
+	Reps              int      `json:"reps" db:"reps"`
+	Weight            float64  `json:"weight" db:"weight"`
+	RPE               *float64 `json:"rpe,omitempty" db:"rpe"`
+	Notes             string   `json:"notes" db:"notes"`
+}
+
+// LoggedSetInput appends a single set to a session while working out.
+type LoggedSetInput struct {
+	ExerciseId   int    `json:"exercise_id"`
+	ExerciseName string `json:"exercise_name"`
+	LoggedSet
+}
+
+// SessionStore persists workout sessions. Like workout plans, sessions are
+// scoped to their owner and api.ErrSessionNotFound is returned for sessions
+// the owner does not have.
+type SessionStore interface {
+	AddWorkoutSession(owner string, session WorkoutSession) (WorkoutSession, error)
+	GetWorkoutSessionList(owner string) ([]WorkoutSession, error)
+	// GetWorkoutSessionPage returns up to limit sessions of the owner with
+	// an id after afterId, in id order, to read all of them a page at a time.
+	GetWorkoutSessionPage(owner string, afterId, limit int) ([]WorkoutSession, error)
+	GetWorkoutSession(owner string, id int) (WorkoutSession, error)
+	UpdateWorkoutSession(owner string, session WorkoutSession) error
+	DeleteWorkoutSession(owner string, id int) error
+	AddLoggedSet(owner string, sessionId int, input LoggedSetInput) (LoggedSet, error)
+}
+
+func (ws *WorkoutServer) getWorkoutSessionListHandler(w http.ResponseWriter, r *http.Request) {
+	owner, _ := middleware.Username(r.Context())
+	list, err := ws.store.GetWorkoutSessionList(owner)
+	if err != nil {
+		api.DatabaseError(w, err)
+		return
+	}
+	if list == nil {
+		list = []WorkoutSession{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+func (ws *WorkoutServer) getWorkoutSessionHandler(w http.ResponseWriter, r *http.Request) {
+	owner, _ := middleware.Username(r.Context())
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		api.StatusBadRequestServerError(w, api.ErrInvalidID)
+		return
+	}
+
+	session, err := ws.store.GetWorkoutSession(owner, id)
+	if err == api.ErrSessionNotFound {
+		api.NotFoundError(w, err)
+		return
+	} else if err != nil {
+		api.DatabaseError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(session)
+}
+
+func (ws *WorkoutServer) addWorkoutSessionHandler(w http.ResponseWriter, r *http.Request) {
+	owner, _ := middleware.Username(r.Context())
+	session := WorkoutSession{}
+	if err := ws.jsonDecode(r, &session); err != nil {
+		api.RequestBodyError(w, err)
+		return
+	}
+	if err := validateWorkoutSession(session); err != nil {
+		api.StatusBadRequestServerError(w, err)
+		return
+	}
+
+	session, err := ws.store.AddWorkoutSession(owner, session)
+	switch err {
+	case nil:
+		ws.metrics.sessionsLogged.Inc()
+		for _, exercise := range session.Exercises {
+			ws.metrics.setsLogged.Add(float64(len(exercise.Sets)))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(session)
+	case api.ErrExerciseNotFound, api.ErrWorkoutPlanNotFound:
+		api.StatusBadRequestServerError(w, err)
+	default:
+		api.DatabaseError(w, err)
+	}
+}
+
+func (ws *WorkoutServer) updateWorkoutSessionHandler(w http.ResponseWriter, r *http.Request) {
+	owner, _ := middleware.Username(r.Context())
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		api.StatusBadRequestServerError(w, api.ErrInvalidID)
+		return
+	}
+	session := WorkoutSession{}
+	if err := ws.jsonDecode(r, &session); err != nil {
+		api.RequestBodyError(w, err)
+		return
+	}
+	if err := validateWorkoutSession(session); err != nil {
+		api.StatusBadRequestServerError(w, err)
+		return
+	}
+	session.Id = id
+
+	err = ws.store.UpdateWorkoutSession(owner, session)
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case api.ErrSessionNotFound:
+		api.NotFoundError(w, err)
+	case api.ErrExerciseNotFound, api.ErrWorkoutPlanNotFound:
+		api.StatusBadRequestServerError(w, err)
+	default:
+		api.DatabaseError(w, err)
+	}
+}
+
+func (ws *WorkoutServer) deleteWorkoutSessionHandler(w http.ResponseWriter, r *http.Request) {
+	owner, _ := middleware.Username(r.Context())
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		api.StatusBadRequestServerError(w, api.ErrInvalidID)
+		return
+	}
+
+	err = ws.store.DeleteWorkoutSession(owner, id)
+	if err == api.ErrSessionNotFound {
+		api.NotFoundError(w, err)
+		return
+	} else if err != nil {
+		api.DatabaseError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (ws *WorkoutServer) addLoggedSetHandler(w http.ResponseWriter, r *http.Request) {
+	owner, _ := middleware.Username(r.Context())
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		api.StatusBadRequestServerError(w, api.ErrInvalidID)
+		return
+	}
+	input := LoggedSetInput{}
+	if err := ws.jsonDecode(r, &input); err != nil {
+		api.RequestBodyError(w, err)
+		return
+	}
+	if err := validateLoggedSet(input.LoggedSet); err != nil {
+		api.StatusBadRequestServerError(w, err)
+		return
+	}
+
+	set, err := ws.store.AddLoggedSet(owner, id, input)
+	switch err {
+	case nil:
+		ws.metrics.setsLogged.Inc()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(set)
+	case api.ErrSessionNotFound:
+		api.NotFoundError(w, err)
+	case api.ErrExerciseNotFound:
+		api.StatusBadRequestServerError(w, err)
+	default:
+		api.DatabaseError(w, err)
+	}
+}
+
+func validateWorkoutSession(session WorkoutSession) error {
+	if session.StartedAt.IsZero() {
+		return api.ErrInvalidSession
+	}
+	if session.EndedAt != nil && session.EndedAt.Before(session.StartedAt) {
+		return api.ErrInvalidSession
+	}
+	for _, exercise := range session.Exercises {
+		if exercise.ExerciseId == 0 && exercise.ExerciseName == "" {
+			return api.ErrInvalidSession
+		}
+		for _, set := range exercise.Sets {
+			if err := validateLoggedSet(set); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validateLoggedSet(set LoggedSet) error {
+	if set.Reps < 0 || set.Weight < 0 {
+		return api.ErrInvalidSet
+	}
+	if set.RPE != nil && (*set.RPE < 1 || *set.RPE > 10) {
+		return api.ErrInvalidSet
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package tracker
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"modernc.org/sqlite"
+)
+
+const sqliteDriverName = "sqlite"
+
+// sqliteSchema rewrites the Postgres types used by the migrations into
+// their SQLite equivalents. TIMESTAMP keeps the driver parsing the column
+// back into a time.Time.
+var sqliteSchema = strings.NewReplacer(
+	"SERIAL PRIMARY KEY", "INTEGER PRIMARY KEY AUTOINCREMENT",
+	"TIMESTAMPTZ", "TIMESTAMP",
+)
+
+func init() {
+	sqlx.BindDriver(sqliteDriverName, sqlx.QUESTION)
+}
+
+// OpenSQLiteDatabase opens, and creates if needed, the SQLite database file
+// at path without checking the schema version. Foreign keys are enforced and
+// transactions take the write lock up front so concurrent writers wait for
+// each other instead of failing.
+func OpenSQLiteDatabase(path string) (*DB, error) {
+	params := url.Values{}
+	params.Add("_pragma", "foreign_keys(1)")
+	params.Add("_pragma", "busy_timeout(5000)")
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Set("_time_format", "sqlite")
+	params.Set("_txlock", "immediate")
+
+	db := sqlx.NewDb(sql.OpenDB(sqliteConnector{"file:" + path + "?" + params.Encode()}), sqliteDriverName)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &DB{db}, nil
+}
+
+func (db *DB) isSQLite() bool {
+	return db.DriverName() == sqliteDriverName
+}
+
+type sqliteConnector struct {
+	dsn string
+}
+
+func (c sqliteConnector) Connect(context.Context) (driver.Conn, error) {
+	conn, err := c.Driver().Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteConn{conn.(sqliteDriverConn)}, nil
+}
+
+func (c sqliteConnector) Driver() driver.Driver {
+	return &sqlite.Driver{}
+}
+
+type sqliteDriverConn interface {
+	driver.Conn
+	driver.ConnBeginTx
+	driver.ConnPrepareContext
+	driver.ExecerContext
+	driver.QueryerContext
+	driver.Pinger
+	driver.SessionResetter
+	driver.Validator
+}
+
+// sqliteConn stores every time in UTC. SQLite compares times as text, which
+// only orders them correctly when they share the same offset.
+type sqliteConn struct {
+	sqliteDriverConn
+}
+
+func (c *sqliteConn) CheckNamedValue(value *driver.NamedValue) error {
+	converted, err := driver.DefaultParameterConverter.ConvertValue(value.Value)
+	if err != nil {
+		return err
+	}
+	if t, ok := converted.(time.Time); ok {
+		converted = t.UTC()
+	}
+	value.Value = converted
+	return nil
+}
@@ -0,0 +1,110 @@
+package tracker
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newSQLiteTestDatabase(t testing.TB) *DB {
+	t.Helper()
+	db, err := OpenSQLiteDatabase(filepath.Join(t.TempDir(), "tracker.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := NewMigrator(db.DB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := migrator.Up(); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestSQLiteMigrations(t *testing.T) {
+	db := newSQLiteTestDatabase(t)
+	migrator, _ := NewMigrator(db.DB)
+
+	if err := migrator.To(0); err != nil {
+		t.Fatalf("Expected every migration to revert, got %v", err)
+	}
+	if err := migrator.Up(); err != nil {
+		t.Fatalf("Expected every migration to apply again, got %v", err)
+	}
+	version, _ := migrator.Version()
+	if version != migrator.Latest() {
+		t.Errorf("Expected version %d, got %d", migrator.Latest(), version)
+	}
+}
+
+func TestSQLiteProgressReport(t *testing.T) {
+	db := newSQLiteTestDatabase(t)
+	for _, username := range []string{"test", "other"} {
+		if err := db.AddUser(UserDetails{Username: username, Password: "testpass", Email: username + "@example.com"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	seedReportStore(db)
+	memory := newReportStore()
+
+	t.Run("matches the in-memory store", func(t *testing.T) {
+		filters := []ProgressFilter{{}, {From: time.Date(2025, 6, 3, 0, 0, 0, 0, time.UTC), ExerciseIds: []int{1}}}
+		for _, filter := range filters {
+			expected, _ := memory.GetProgressReport("test", filter)
+			got, err := db.GetProgressReport("test", filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertProgress(t, expected, got)
+
+			expectedWeeks, _ := memory.GetWeeklyProgressReport("test", filter)
+			gotWeeks, err := db.GetWeeklyProgressReport("test", filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(expectedWeeks, gotWeeks) {
+				t.Errorf("Expected %+v, got %+v", expectedWeeks, gotWeeks)
+			}
+		}
+	})
+
+	t.Run("compares times in UTC whatever their offset", func(t *testing.T) {
+		// Monday 01:00 in Berlin is still Sunday in UTC
+		berlin := time.FixedZone("CEST", 2*60*60)
+		db.AddWorkoutSession("other", WorkoutSession{
+			StartedAt: time.Date(2025, 6, 16, 1, 0, 0, 0, berlin),
+			Exercises: []SessionExercise{{ExerciseId: 1, Sets: []LoggedSet{{Reps: 1, Weight: 100}}}},
+		})
+
+		report, _ := db.GetWeeklyProgressReport("other", ProgressFilter{From: time.Date(2025, 6, 15, 22, 30, 0, 0, time.UTC)})
+		if len(report) != 1 || !report[0].WeekStart.Equal(time.Date(2025, 6, 9, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("Expected one session in the week of 2025-06-09, got %+v", report)
+		}
+	})
+}
+
+func TestSQLiteWorkoutPlanErrors(t *testing.T) {
+	db := newSQLiteTestDatabase(t)
+	db.AddUser(UserDetails{Username: "test", Password: "testpass", Email: "test@example.com"})
+	db.AddExercise(Exercise{Name: "Row", Category: "strength"})
+	if err := db.AddWorkoutPlan("test", WorkoutPlan{ExerciseName: "Row", Repititions: 10, Sets: 3}); err != nil {
+		t.Fatal(err)
+	}
+	for _, statement := range []string{
+		"CREATE TRIGGER no_update BEFORE UPDATE ON WORKOUT_PLAN BEGIN SELECT RAISE(ABORT, 'read only'); END",
+		"CREATE TRIGGER no_delete BEFORE DELETE ON WORKOUT_PLAN BEGIN SELECT RAISE(ABORT, 'read only'); END",
+	} {
+		db.MustExec(statement)
+	}
+
+	if err := db.UpdateWorkoutPlan("test", WorkoutPlan{ExerciseName: "Row", Repititions: 12, Sets: 3}); err == nil {
+		t.Error("Expected a failing update to return an error")
+	}
+	if err := db.DeleteWorkoutPlan("test", "Row"); err == nil {
+		t.Error("Expected a failing delete to return an error")
+	}
+}
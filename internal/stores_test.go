@@ -0,0 +1,46 @@
+package tracker_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	tracker "github.com/Oriseer/workout_tracker/internal"
+	"github.com/Oriseer/workout_tracker/internal/storetest"
+)
+
+func TestInMemoryStoreConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) tracker.WorkoutPlanStore {
+		return tracker.NewInMemoryStore()
+	})
+}
+
+func TestSQLiteStoreConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) tracker.WorkoutPlanStore {
+		db, err := tracker.OpenSQLiteDatabase(filepath.Join(t.TempDir(), "tracker.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+
+		migrator, err := tracker.NewMigrator(db.DB)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := migrator.Up(); err != nil {
+			t.Fatal(err)
+		}
+		return db
+	})
+}
+
+func TestDatabaseStoreConformance(t *testing.T) {
+	db, err := tracker.NewDatabase(integrationConfig(t))
+	if err != nil {
+		t.Skipf("skipping database conformance test, database unavailable: %v", err)
+	}
+	defer db.Close()
+
+	storetest.Run(t, func(t *testing.T) tracker.WorkoutPlanStore {
+		return db
+	})
+}
@@ -0,0 +1,908 @@
+// Package storetest checks that an implementation of
+// tracker.WorkoutPlanStore keeps the contract the handlers rely on. Every
+// store runs the same suite:
+//
+//	func TestConformance(t *testing.T) {
+//		storetest.Run(t, func(t *testing.T) tracker.WorkoutPlanStore {
+//			return tracker.NewInMemoryStore()
+//		})
+//	}
+package storetest
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Oriseer/workout_tracker/api"
+	tracker "github.com/Oriseer/workout_tracker/internal"
+)
+
+// Factory returns the store to check. It is called once per group of
+// checks. Stores that keep their data between calls, like a shared
+// database, are fine: every check registers users, exercises and tokens
+// under names of its own.
+type Factory func(t *testing.T) tracker.WorkoutPlanStore
+
+// Run checks the store returned by newStore against the full
+// WorkoutPlanStore contract.
+func Run(t *testing.T, newStore Factory) {
+	checks := []struct {
+		name  string
+		check func(t *testing.T, f *fixture)
+	}{
+		{"users", testUsers},
+		{"roles", testRoles},
+		{"exercises", testExercises},
+		{"workout plans", testWorkoutPlans},
+		{"workout plan queries", testWorkoutPlanQueries},
+		{"sessions", testSessions},
+		{"schedules", testSchedules},
+		{"reports", testReports},
+		{"refresh tokens", testRefreshTokens},
+		{"token revocation", testTokenRevocation},
+		{"password reset", testPasswordReset},
+		{"email verification", testEmailVerification},
+		{"login failures", testLoginFailures},
+		{"import", testImport},
+		{"exercise aliases", testExerciseAliases},
+	}
+	for _, c := range checks {
+		t.Run(c.name, func(t *testing.T) {
+			c.check(t, &fixture{newStore(t), nextSuffix()})
+		})
+	}
+}
+
+var suffixes atomic.Int64
+
+// nextSuffix keeps the names of one check apart from those of other checks
+// and of earlier runs against the same database.
+func nextSuffix() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 36) + strconv.FormatInt(suffixes.Add(1), 36)
+}
+
+type fixture struct {
+	tracker.WorkoutPlanStore
+	suffix string
+}
+
+func (f *fixture) name(base string) string {
+	return base + "-" + f.suffix
+}
+
+// user registers a user with the password "testpass" and returns its name.
+func (f *fixture) user(t *testing.T, base string) string {
+	t.Helper()
+	username := f.name(base)
+	err := f.AddUser(tracker.UserDetails{Username: username, Password: "testpass", Email: username + "@example.com"})
+	if err != nil {
+		t.Fatalf("Expected user %s to be added, got %v", username, err)
+	}
+	return username
+}
+
+func (f *fixture) exercise(t *testing.T, base string) tracker.Exercise {
+	t.Helper()
+	exercise, err := f.AddExercise(tracker.Exercise{Name: f.name(base), Category: "strength"})
+	if err != nil {
+		t.Fatalf("Expected exercise %s to be added, got %v", f.name(base), err)
+	}
+	return exercise
+}
+
+// plan adds a workout plan for the exercise and returns it as listed.
+func (f *fixture) plan(t *testing.T, owner string, exercise tracker.Exercise) tracker.WorkoutPlan {
+	t.Helper()
+	err := f.AddWorkoutPlan(owner, tracker.WorkoutPlan{ExerciseId: exercise.Id, Repititions: 10, Sets: 3, Weight: 20})
+	if err != nil {
+		t.Fatalf("Expected the plan to be added, got %v", err)
+	}
+	for _, plan := range f.plans(t, owner) {
+		if plan.ExerciseId == exercise.Id {
+			return plan
+		}
+	}
+	t.Fatalf("Expected a plan for %s", exercise.Name)
+	return tracker.WorkoutPlan{}
+}
+
+func (f *fixture) plans(t *testing.T, owner string) []tracker.WorkoutPlan {
+	t.Helper()
+	plans, err := f.GetWorkoutPlanList(owner, tracker.WorkoutPlanQuery{Sort: "id"})
+	if err != nil {
+		t.Fatalf("Expected the plans of %s, got %v", owner, err)
+	}
+	return plans
+}
+
+func assertError(t *testing.T, expected, got error) {
+	t.Helper()
+	if !errors.Is(got, expected) {
+		t.Errorf("Expected error %v, got %v", expected, got)
+	}
+}
+
+func assertNoError(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+}
+
+func testUsers(t *testing.T, f *fixture) {
+	username := f.user(t, "alice")
+
+	t.Run("usernames are unique", func(t *testing.T) {
+		err := f.AddUser(tracker.UserDetails{Username: username, Password: "other", Email: "other@example.com"})
+		assertError(t, api.ErrUserName, err)
+	})
+
+	t.Run("login returns the user", func(t *testing.T) {
+		login, err := f.UserLogin(tracker.LoginData{Username: username, Password: "testpass"})
+		assertNoError(t, err)
+		if login.Username != username || login.Role != "user" || login.EmailVerified {
+			t.Errorf("Expected an unverified user %s, got %+v", username, login)
+		}
+	})
+
+	t.Run("login fails for a wrong password or an unknown user", func(t *testing.T) {
+		_, err := f.UserLogin(tracker.LoginData{Username: username, Password: "wrong"})
+		assertError(t, api.ErrInvalidLoginDetails, err)
+		_, err = f.UserLogin(tracker.LoginData{Username: f.name("nobody"), Password: "testpass"})
+		assertError(t, api.ErrInvalidLoginDetails, err)
+	})
+
+	t.Run("users are found by email ignoring case", func(t *testing.T) {
+		users, err := f.GetUsersByEmail(f.name("ALICE") + "@example.com")
+		assertNoError(t, err)
+		if len(users) != 1 || users[0].Username != username || users[0].Email != username+"@example.com" {
+			t.Errorf("Expected %s, got %+v", username, users)
+		}
+	})
+}
+
+func testRoles(t *testing.T, f *fixture) {
+	username := f.user(t, "coach")
+
+	role, err := f.GetUserRole(username)
+	assertNoError(t, err)
+	if role != "user" {
+		t.Errorf("Expected new users to have the user role, got %q", role)
+	}
+
+	assertNoError(t, f.SetUserRole(username, "coach"))
+	login, _ := f.UserLogin(tracker.LoginData{Username: username, Password: "testpass"})
+	if login.Role != "coach" {
+		t.Errorf("Expected login to return the coach role, got %q", login.Role)
+	}
+
+	users, err := f.GetUserList()
+	assertNoError(t, err)
+	found := false
+	for _, user := range users {
+		if user.Username == username {
+			found = user.Role == "coach" && user.Email == username+"@example.com"
+		}
+	}
+	if !found {
+		t.Errorf("Expected %s to be listed as a coach, got %+v", username, users)
+	}
+
+	_, err = f.GetUserRole(f.name("nobody"))
+	assertError(t, api.ErrUserNotFound, err)
+	assertError(t, api.ErrUserNotFound, f.SetUserRole(f.name("nobody"), "admin"))
+}
+
+func testExercises(t *testing.T, f *fixture) {
+	squat := f.exercise(t, "squat")
+
+	t.Run("names are unique ignoring case", func(t *testing.T) {
+		_, err := f.AddExercise(tracker.Exercise{Name: f.name("SQUAT")})
+		assertError(t, api.ErrExerciseExists, err)
+	})
+
+	t.Run("exercises are read by id and listed by name and category", func(t *testing.T) {
+		got, err := f.GetExercise(squat.Id)
+		assertNoError(t, err)
+		if got != squat {
+			t.Errorf("Expected %+v, got %+v", squat, got)
+		}
+		_, err = f.GetExercise(-1)
+		assertError(t, api.ErrExerciseNotFound, err)
+
+		list, err := f.GetExerciseList(tracker.ExerciseFilter{Name: strings.ToUpper(squat.Name), Category: "strength"})
+		assertNoError(t, err)
+		if len(list) != 1 || list[0] != squat {
+			t.Errorf("Expected only %+v, got %+v", squat, list)
+		}
+	})
+
+	t.Run("names are filtered literally", func(t *testing.T) {
+		percent := f.exercise(t, `50% \_row`)
+		f.exercise(t, "500 xrow")
+		for _, name := range []string{"0% ", `\_row`} {
+			list, err := f.GetExerciseList(tracker.ExerciseFilter{Name: name})
+			assertNoError(t, err)
+			if len(list) != 1 || list[0] != percent {
+				t.Errorf("Expected only %+v for %q, got %+v", percent, name, list)
+			}
+		}
+	})
+
+	t.Run("renames reach the workout plans", func(t *testing.T) {
+		owner := f.user(t, "renamer")
+		f.plan(t, owner, squat)
+		squat.Name = f.name("back squat")
+		assertNoError(t, f.UpdateExercise(squat))
+
+		plans := f.plans(t, owner)
+		if len(plans) != 1 || plans[0].ExerciseName != squat.Name {
+			t.Errorf("Expected the plan to be renamed to %s, got %+v", squat.Name, plans)
+		}
+	})
+
+	t.Run("updates keep names unique", func(t *testing.T) {
+		lunge := f.exercise(t, "lunge")
+		lunge.Name = squat.Name
+		assertError(t, api.ErrExerciseExists, f.UpdateExercise(lunge))
+		assertError(t, api.ErrExerciseNotFound, f.UpdateExercise(tracker.Exercise{Id: -1, Name: f.name("ghost")}))
+	})
+
+	t.Run("only unused exercises are deleted", func(t *testing.T) {
+		assertError(t, api.ErrExerciseInUse, f.DeleteExercise(squat.Id))
+
+		dip := f.exercise(t, "dip")
+		assertNoError(t, f.DeleteExercise(dip.Id))
+		_, err := f.GetExercise(dip.Id)
+		assertError(t, api.ErrExerciseNotFound, err)
+		assertError(t, api.ErrExerciseNotFound, f.DeleteExercise(dip.Id))
+	})
+}
+
+func testWorkoutPlans(t *testing.T, f *fixture) {
+	owner := f.user(t, "owner")
+	other := f.user(t, "other")
+	row := f.exercise(t, "row")
+
+	t.Run("plans resolve the exercise by name ignoring case", func(t *testing.T) {
+		err := f.AddWorkoutPlan(owner, tracker.WorkoutPlan{ExerciseName: f.name("ROW"), Repititions: 8, Sets: 4, Weight: 40})
+		assertNoError(t, err)
+
+		plans := f.plans(t, owner)
+		expected := tracker.WorkoutPlan{ExerciseId: row.Id, ExerciseName: row.Name, Repititions: 8, Sets: 4, Weight: 40}
+		if len(plans) != 1 || plans[0].Id == 0 {
+			t.Fatalf("Expected one plan with an id, got %+v", plans)
+		}
+		expected.Id = plans[0].Id
+		if plans[0] != expected {
+			t.Errorf("Expected %+v, got %+v", expected, plans[0])
+		}
+	})
+
+	t.Run("plans need a catalog exercise", func(t *testing.T) {
+		err := f.AddWorkoutPlan(owner, tracker.WorkoutPlan{ExerciseName: f.name("unknown"), Repititions: 1, Sets: 1})
+		assertError(t, api.ErrExerciseNotFound, err)
+	})
+
+	t.Run("plans are updated by exercise id or name", func(t *testing.T) {
+		assertNoError(t, f.UpdateWorkoutPlan(owner, tracker.WorkoutPlan{ExerciseId: row.Id, Repititions: 12, Sets: 3, Weight: 35}))
+		assertNoError(t, f.UpdateWorkoutPlan(owner, tracker.WorkoutPlan{ExerciseName: f.name("Row"), Repititions: 12, Sets: 5, Weight: 35}))
+
+		plans := f.plans(t, owner)
+		if len(plans) != 1 || plans[0].Repititions != 12 || plans[0].Sets != 5 || plans[0].Weight != 35 {
+			t.Errorf("Expected the updated plan, got %+v", plans)
+		}
+	})
+
+	t.Run("plans of other owners are not visible", func(t *testing.T) {
+		if plans := f.plans(t, other); len(plans) != 0 {
+			t.Errorf("Expected %s to have no plans, got %+v", other, plans)
+		}
+		assertError(t, api.ErrWorkoutPlanNotFound, f.UpdateWorkoutPlan(other, tracker.WorkoutPlan{ExerciseId: row.Id, Repititions: 1, Sets: 1}))
+		assertError(t, api.ErrWorkoutPlanNotFound, f.DeleteWorkoutPlan(other, row.Name))
+	})
+
+	t.Run("plans are deleted by name ignoring case", func(t *testing.T) {
+		assertNoError(t, f.DeleteWorkoutPlan(owner, f.name("ROW")))
+		if plans := f.plans(t, owner); len(plans) != 0 {
+			t.Errorf("Expected no plans, got %+v", plans)
+		}
+		assertError(t, api.ErrWorkoutPlanNotFound, f.DeleteWorkoutPlan(owner, row.Name))
+		assertError(t, api.ErrWorkoutPlanNotFound, f.UpdateWorkoutPlan(owner, tracker.WorkoutPlan{ExerciseId: row.Id, Repititions: 1, Sets: 1}))
+	})
+}
+
+func testWorkoutPlanQueries(t *testing.T, f *fixture) {
+	owner := f.user(t, "owner")
+	weights := map[string]int{"curl": 10, "press": 30, "deadlift": 100}
+	for base, weight := range weights {
+		exercise := f.exercise(t, base)
+		err := f.AddWorkoutPlan(owner, tracker.WorkoutPlan{ExerciseId: exercise.Id, Repititions: 5, Sets: 5, Weight: weight})
+		assertNoError(t, err)
+	}
+
+	names := func(plans []tracker.WorkoutPlan) []string {
+		list := []string{}
+		for _, plan := range plans {
+			list = append(list, plan.ExerciseName)
+		}
+		return list
+	}
+	assertNames := func(t *testing.T, expected []string, plans []tracker.WorkoutPlan) {
+		t.Helper()
+		got := names(plans)
+		if len(got) != len(expected) {
+			t.Fatalf("Expected %v, got %v", expected, got)
+		}
+		for i := range expected {
+			if got[i] != f.name(expected[i]) {
+				t.Fatalf("Expected %v, got %v", expected, got)
+			}
+		}
+	}
+
+	t.Run("filters by name and bounds", func(t *testing.T) {
+		minWeight, maxWeight := 20, 100
+		plans, err := f.GetWorkoutPlanList(owner, tracker.WorkoutPlanQuery{Sort: "weight", MinWeight: &minWeight, MaxWeight: &maxWeight})
+		assertNoError(t, err)
+		assertNames(t, []string{"press", "deadlift"}, plans)
+
+		plans, err = f.GetWorkoutPlanList(owner, tracker.WorkoutPlanQuery{Sort: "id", ExerciseName: "CURL"})
+		assertNoError(t, err)
+		assertNames(t, []string{"curl"}, plans)
+	})
+
+	t.Run("sorts and pages after the cursor", func(t *testing.T) {
+		query := tracker.WorkoutPlanQuery{Sort: "weight", Desc: true, Limit: 2}
+		page, err := f.GetWorkoutPlanList(owner, query)
+		assertNoError(t, err)
+		assertNames(t, []string{"deadlift", "press"}, page)
+
+		last := page[len(page)-1]
+		query.After = &tracker.WorkoutPlanCursor{Sort: "weight", Desc: true, Id: last.Id, Number: last.Weight}
+		page, err = f.GetWorkoutPlanList(owner, query)
+		assertNoError(t, err)
+		assertNames(t, []string{"curl"}, page)
+	})
+
+	t.Run("names are filtered literally", func(t *testing.T) {
+		lifter := f.user(t, "lifter")
+		percent := f.plan(t, lifter, f.exercise(t, `50% \_lift`))
+		f.plan(t, lifter, f.exercise(t, "500 xlift"))
+		for _, name := range []string{"0% ", `\_lift`, "_"} {
+			plans, err := f.GetWorkoutPlanList(lifter, tracker.WorkoutPlanQuery{Sort: "id", ExerciseName: name})
+			assertNoError(t, err)
+			if len(plans) != 1 || plans[0].Id != percent.Id {
+				t.Errorf("Expected only %+v for %q, got %+v", percent, name, plans)
+			}
+		}
+	})
+}
+
+func testSessions(t *testing.T, f *fixture) {
+	owner := f.user(t, "owner")
+	other := f.user(t, "other")
+	bench := f.exercise(t, "bench")
+	squat := f.exercise(t, "squat")
+	plan := f.plan(t, owner, bench)
+	otherPlan := f.plan(t, other, bench)
+	startedAt := time.Date(2025, 6, 2, 7, 0, 0, 0, time.UTC)
+
+	session, err := f.AddWorkoutSession(owner, tracker.WorkoutSession{
+		PlanId:    &plan.Id,
+		StartedAt: startedAt,
+		Notes:     "heavy day",
+		Exercises: []tracker.SessionExercise{
+			{ExerciseName: f.name("BENCH"), Sets: []tracker.LoggedSet{{Reps: 5, Weight: 80}, {Reps: 5, Weight: 85}}},
+		},
+	})
+	assertNoError(t, err)
+
+	t.Run("sessions resolve exercises and link the plan", func(t *testing.T) {
+		got, err := f.GetWorkoutSession(owner, session.Id)
+		assertNoError(t, err)
+		if got.Id == 0 || !got.StartedAt.Equal(startedAt) || got.Notes != "heavy day" {
+			t.Errorf("Expected the added session, got %+v", got)
+		}
+		if got.PlanId == nil || *got.PlanId != plan.Id || got.Plan == nil || got.Plan.ExerciseName != bench.Name {
+			t.Errorf("Expected the session to link plan %d, got %+v", plan.Id, got)
+		}
+		if len(got.Exercises) != 1 || got.Exercises[0].ExerciseId != bench.Id || got.Exercises[0].ExerciseName != bench.Name || len(got.Exercises[0].Sets) != 2 {
+			t.Errorf("Expected two %s sets, got %+v", bench.Name, got.Exercises)
+		}
+	})
+
+	t.Run("sessions only link plans of their owner", func(t *testing.T) {
+		_, err := f.AddWorkoutSession(owner, tracker.WorkoutSession{PlanId: &otherPlan.Id, StartedAt: startedAt})
+		assertError(t, api.ErrWorkoutPlanNotFound, err)
+		_, err = f.AddWorkoutSession(owner, tracker.WorkoutSession{StartedAt: startedAt, Exercises: []tracker.SessionExercise{{ExerciseName: f.name("unknown")}}})
+		assertError(t, api.ErrExerciseNotFound, err)
+	})
+
+	t.Run("sets are appended while working out", func(t *testing.T) {
+		_, err := f.AddLoggedSet(owner, session.Id, tracker.LoggedSetInput{ExerciseId: bench.Id, LoggedSet: tracker.LoggedSet{Reps: 3, Weight: 90}})
+		assertNoError(t, err)
+		set, err := f.AddLoggedSet(owner, session.Id, tracker.LoggedSetInput{ExerciseName: squat.Name, LoggedSet: tracker.LoggedSet{Reps: 5, Weight: 100}})
+		assertNoError(t, err)
+		if set.Id == 0 || set.Reps != 5 {
+			t.Errorf("Expected the logged set with an id, got %+v", set)
+		}
+
+		got, _ := f.GetWorkoutSession(owner, session.Id)
+		if len(got.Exercises) != 2 || len(got.Exercises[0].Sets) != 3 || got.Exercises[1].ExerciseId != squat.Id || len(got.Exercises[1].Sets) != 1 {
+			t.Errorf("Expected three bench sets and one squat set, got %+v", got.Exercises)
+		}
+	})
+
+	t.Run("sessions are listed newest first", func(t *testing.T) {
+		later, err := f.AddWorkoutSession(owner, tracker.WorkoutSession{StartedAt: startedAt.Add(24 * time.Hour)})
+		assertNoError(t, err)
+
+		sessions, err := f.GetWorkoutSessionList(owner)
+		assertNoError(t, err)
+		if len(sessions) != 2 || sessions[0].Id != later.Id || sessions[1].Id != session.Id {
+			t.Errorf("Expected sessions %d and %d, got %+v", later.Id, session.Id, sessions)
+		}
+	})
+
+	t.Run("sessions are paged in id order", func(t *testing.T) {
+		all, _ := f.GetWorkoutSessionList(owner)
+		first, err := f.GetWorkoutSessionPage(owner, 0, 1)
+		assertNoError(t, err)
+		if len(first) != 1 || first[0].Id != session.Id || len(first[0].Exercises) != 2 {
+			t.Errorf("Expected session %d with its exercises, got %+v", session.Id, first)
+		}
+		rest, err := f.GetWorkoutSessionPage(owner, session.Id, len(all))
+		assertNoError(t, err)
+		if len(rest) != len(all)-1 || !slices.IsSortedFunc(rest, func(a, b tracker.WorkoutSession) int { return a.Id - b.Id }) || rest[0].Id <= session.Id {
+			t.Errorf("Expected the other %d sessions after %d, got %+v", len(all)-1, session.Id, rest)
+		}
+	})
+
+	t.Run("updates replace the exercises", func(t *testing.T) {
+		endedAt := startedAt.Add(time.Hour)
+		update := tracker.WorkoutSession{
+			Id:        session.Id,
+			StartedAt: startedAt,
+			EndedAt:   &endedAt,
+			Notes:     "light day",
+			Exercises: []tracker.SessionExercise{{ExerciseId: squat.Id, Sets: []tracker.LoggedSet{{Reps: 10, Weight: 60}}}},
+		}
+		assertNoError(t, f.UpdateWorkoutSession(owner, update))
+
+		got, _ := f.GetWorkoutSession(owner, session.Id)
+		if got.PlanId != nil || got.EndedAt == nil || !got.EndedAt.Equal(endedAt) || got.Notes != "light day" {
+			t.Errorf("Expected the updated session, got %+v", got)
+		}
+		if len(got.Exercises) != 1 || got.Exercises[0].ExerciseId != squat.Id || len(got.Exercises[0].Sets) != 1 {
+			t.Errorf("Expected a single squat set, got %+v", got.Exercises)
+		}
+	})
+
+	t.Run("sessions of other owners are not found", func(t *testing.T) {
+		_, err := f.GetWorkoutSession(other, session.Id)
+		assertError(t, api.ErrSessionNotFound, err)
+		assertError(t, api.ErrSessionNotFound, f.UpdateWorkoutSession(other, tracker.WorkoutSession{Id: session.Id, StartedAt: startedAt}))
+		assertError(t, api.ErrSessionNotFound, f.DeleteWorkoutSession(other, session.Id))
+		_, err = f.AddLoggedSet(other, session.Id, tracker.LoggedSetInput{ExerciseId: bench.Id, LoggedSet: tracker.LoggedSet{Reps: 1}})
+		assertError(t, api.ErrSessionNotFound, err)
+	})
+
+	t.Run("deleting a plan unlinks its sessions", func(t *testing.T) {
+		linked, err := f.AddWorkoutSession(owner, tracker.WorkoutSession{PlanId: &plan.Id, StartedAt: startedAt})
+		assertNoError(t, err)
+		assertNoError(t, f.DeleteWorkoutPlan(owner, bench.Name))
+
+		got, err := f.GetWorkoutSession(owner, linked.Id)
+		assertNoError(t, err)
+		if got.PlanId != nil || got.Plan != nil {
+			t.Errorf("Expected the session to be unlinked, got %+v", got)
+		}
+	})
+
+	t.Run("deleted sessions are gone", func(t *testing.T) {
+		assertNoError(t, f.DeleteWorkoutSession(owner, session.Id))
+		_, err := f.GetWorkoutSession(owner, session.Id)
+		assertError(t, api.ErrSessionNotFound, err)
+		assertError(t, api.ErrSessionNotFound, f.DeleteWorkoutSession(owner, session.Id))
+	})
+}
+
+func testSchedules(t *testing.T, f *fixture) {
+	owner := f.user(t, "owner")
+	other := f.user(t, "other")
+	plan := f.plan(t, owner, f.exercise(t, "press"))
+	startsAt := time.Date(2025, 6, 2, 18, 0, 0, 0, time.UTC)
+
+	schedule, err := f.AddWorkoutSchedule(owner, tracker.WorkoutSchedule{PlanId: plan.Id, StartsAt: startsAt, TimeZone: "UTC", RRule: "FREQ=WEEKLY"})
+	assertNoError(t, err)
+	if schedule.Id == 0 || schedule.ExerciseName != plan.ExerciseName {
+		t.Errorf("Expected the schedule with an id and the plan's exercise, got %+v", schedule)
+	}
+
+	t.Run("schedules only use plans of their owner", func(t *testing.T) {
+		_, err := f.AddWorkoutSchedule(other, tracker.WorkoutSchedule{PlanId: plan.Id, StartsAt: startsAt, TimeZone: "UTC"})
+		assertError(t, api.ErrWorkoutPlanNotFound, err)
+	})
+
+	t.Run("schedules are listed and updated", func(t *testing.T) {
+		schedule.StartsAt = startsAt.Add(time.Hour)
+		schedule.RRule = "FREQ=DAILY"
+		assertNoError(t, f.UpdateWorkoutSchedule(owner, schedule))
+
+		schedules, err := f.GetWorkoutScheduleList(owner)
+		assertNoError(t, err)
+		if len(schedules) != 1 || schedules[0].Id != schedule.Id || !schedules[0].StartsAt.Equal(schedule.StartsAt) || schedules[0].RRule != "FREQ=DAILY" {
+			t.Errorf("Expected the updated schedule, got %+v", schedules)
+		}
+	})
+
+	t.Run("schedules of other owners are not found", func(t *testing.T) {
+		if schedules, _ := f.GetWorkoutScheduleList(other); len(schedules) != 0 {
+			t.Errorf("Expected %s to have no schedules, got %+v", other, schedules)
+		}
+		assertError(t, api.ErrScheduleNotFound, f.UpdateWorkoutSchedule(other, schedule))
+		assertError(t, api.ErrScheduleNotFound, f.DeleteWorkoutSchedule(other, schedule.Id))
+	})
+
+	t.Run("deleting a plan deletes its schedules", func(t *testing.T) {
+		assertNoError(t, f.DeleteWorkoutPlan(owner, plan.ExerciseName))
+		if schedules, _ := f.GetWorkoutScheduleList(owner); len(schedules) != 0 {
+			t.Errorf("Expected no schedules, got %+v", schedules)
+		}
+		assertError(t, api.ErrScheduleNotFound, f.DeleteWorkoutSchedule(owner, schedule.Id))
+	})
+}
+
+func testReports(t *testing.T, f *fixture) {
+	owner := f.user(t, "owner")
+	other := f.user(t, "other")
+	bench := f.exercise(t, "bench")
+	squat := f.exercise(t, "squat")
+	log := func(owner string, day int, exercise tracker.Exercise, sets ...tracker.LoggedSet) {
+		_, err := f.AddWorkoutSession(owner, tracker.WorkoutSession{
+			StartedAt: time.Date(2025, 6, day, 7, 0, 0, 0, time.UTC),
+			Exercises: []tracker.SessionExercise{{ExerciseId: exercise.Id, Sets: sets}},
+		})
+		assertNoError(t, err)
+	}
+	log(owner, 2, bench, tracker.LoggedSet{Reps: 5, Weight: 100}, tracker.LoggedSet{Reps: 3, Weight: 110})
+	log(owner, 9, bench, tracker.LoggedSet{Reps: 2, Weight: 120})
+	log(owner, 9, squat, tracker.LoggedSet{Reps: 5, Weight: 140})
+	log(other, 9, bench, tracker.LoggedSet{Reps: 1, Weight: 200})
+
+	t.Run("progress is aggregated per exercise of the owner", func(t *testing.T) {
+		report, err := f.GetProgressReport(owner, tracker.ProgressFilter{ExerciseIds: []int{bench.Id}})
+		assertNoError(t, err)
+		expected := tracker.ExerciseProgress{ExerciseId: bench.Id, ExerciseName: bench.Name, SessionCount: 2, SetCount: 3, TotalReps: 10,
+			TotalVolume: 1070, BestSetReps: 2, BestSetWeight: 120, EstimatedOneRepMax: 128}
+		if len(report) != 1 || report[0] != expected {
+			t.Errorf("Expected %+v, got %+v", expected, report)
+		}
+	})
+
+	t.Run("progress is aggregated per week", func(t *testing.T) {
+		from := time.Date(2025, 6, 3, 0, 0, 0, 0, time.UTC)
+		report, err := f.GetWeeklyProgressReport(owner, tracker.ProgressFilter{From: from})
+		assertNoError(t, err)
+		if len(report) != 2 || !report[0].WeekStart.Equal(time.Date(2025, 6, 9, 0, 0, 0, 0, time.UTC)) {
+			t.Fatalf("Expected bench and squat in the week of 2025-06-09, got %+v", report)
+		}
+		if report[0].ExerciseId != bench.Id || report[1].ExerciseId != squat.Id || report[1].TotalVolume != 700 {
+			t.Errorf("Expected bench and squat progress, got %+v", report)
+		}
+	})
+}
+
+func testRefreshTokens(t *testing.T, f *fixture) {
+	username := f.user(t, "owner")
+	token := tracker.RefreshToken{TokenHash: f.name("refresh"), FamilyId: f.name("family"), Username: username, ExpiresAt: time.Now().Add(time.Hour)}
+	assertNoError(t, f.AddRefreshToken(token))
+
+	used, err := f.UseRefreshToken(token.TokenHash)
+	assertNoError(t, err)
+	if used.Username != username || used.FamilyId != token.FamilyId || used.UsedAt == nil || used.RevokedAt != nil {
+		t.Errorf("Expected the used token of %s, got %+v", username, used)
+	}
+
+	_, err = f.UseRefreshToken(token.TokenHash)
+	assertError(t, api.ErrRefreshTokenReused, err)
+	_, err = f.UseRefreshToken(f.name("unknown"))
+	assertError(t, api.ErrInvalidRefreshToken, err)
+
+	next := tracker.RefreshToken{TokenHash: f.name("next"), FamilyId: token.FamilyId, Username: username, ExpiresAt: time.Now().Add(time.Hour)}
+	assertNoError(t, f.AddRefreshToken(next))
+	assertNoError(t, f.RevokeRefreshTokenFamily(token.FamilyId))
+	revoked, _ := f.UseRefreshToken(next.TokenHash)
+	if revoked.RevokedAt == nil {
+		t.Errorf("Expected the family to be revoked, got %+v", revoked)
+	}
+}
+
+func testTokenRevocation(t *testing.T, f *fixture) {
+	username := f.user(t, "owner")
+	issuedAt := time.Now().Truncate(time.Second)
+
+	t.Run("single tokens are revoked by jti", func(t *testing.T) {
+		assertNoError(t, f.RevokeToken(f.name("jti"), issuedAt.Add(time.Minute)))
+		assertNoError(t, f.RevokeToken(f.name("jti"), issuedAt.Add(time.Minute)))
+
+		revoked, err := f.IsTokenRevoked(f.name("jti"), username, issuedAt)
+		assertNoError(t, err)
+		if !revoked {
+			t.Error("Expected the token to be revoked")
+		}
+		if revoked, _ := f.IsTokenRevoked(f.name("other-jti"), username, issuedAt); revoked {
+			t.Error("Expected other tokens to stay valid")
+		}
+	})
+
+	t.Run("expired revocations are pruned", func(t *testing.T) {
+		pruned, err := f.PruneRevokedTokens(issuedAt.Add(2 * time.Minute))
+		assertNoError(t, err)
+		if pruned < 1 {
+			t.Errorf("Expected the revoked token to be pruned, got %d", pruned)
+		}
+		if revoked, _ := f.IsTokenRevoked(f.name("jti"), username, issuedAt); revoked {
+			t.Error("Expected the pruned token to be forgotten")
+		}
+	})
+
+	t.Run("all tokens of a user issued before a time are revoked", func(t *testing.T) {
+		refresh := tracker.RefreshToken{TokenHash: f.name("refresh"), FamilyId: f.name("family"), Username: username, ExpiresAt: time.Now().Add(time.Hour)}
+		assertNoError(t, f.AddRefreshToken(refresh))
+		assertNoError(t, f.RevokeUserTokens(username, issuedAt))
+
+		if revoked, _ := f.IsTokenRevoked(f.name("early"), username, issuedAt.Add(-time.Millisecond)); !revoked {
+			t.Error("Expected tokens issued before the time to be revoked")
+		}
+		if revoked, _ := f.IsTokenRevoked(f.name("late"), username, issuedAt); revoked {
+			t.Error("Expected tokens issued at the time to stay valid")
+		}
+		if token, _ := f.UseRefreshToken(refresh.TokenHash); token.RevokedAt == nil {
+			t.Errorf("Expected the refresh token to be revoked, got %+v", token)
+		}
+	})
+
+	t.Run("refresh tokens are only revoked for their user", func(t *testing.T) {
+		refresh := tracker.RefreshToken{TokenHash: f.name("mine"), FamilyId: f.name("mine"), Username: username, ExpiresAt: time.Now().Add(time.Hour)}
+		assertNoError(t, f.AddRefreshToken(refresh))
+
+		assertNoError(t, f.RevokeUserRefreshToken(f.name("someone"), refresh.TokenHash))
+		if token, _ := f.UseRefreshToken(refresh.TokenHash); token.RevokedAt != nil {
+			t.Errorf("Expected the token to stay valid, got %+v", token)
+		}
+		assertNoError(t, f.RevokeUserRefreshToken(username, refresh.TokenHash))
+		if token, _ := f.UseRefreshToken(refresh.TokenHash); token.RevokedAt == nil {
+			t.Errorf("Expected the token to be revoked, got %+v", token)
+		}
+	})
+}
+
+func testPasswordReset(t *testing.T, f *fixture) {
+	username := f.user(t, "owner")
+	now := time.Now()
+	assertNoError(t, f.AddPasswordResetToken(tracker.PasswordResetToken{TokenHash: f.name("reset"), Username: username, ExpiresAt: now.Add(time.Hour)}))
+	assertNoError(t, f.AddPasswordResetToken(tracker.PasswordResetToken{TokenHash: f.name("expired"), Username: username, ExpiresAt: now.Add(-time.Minute)}))
+
+	_, err := f.ResetPassword(f.name("expired"), "newpass", now)
+	assertError(t, api.ErrInvalidResetToken, err)
+	_, err = f.ResetPassword(f.name("unknown"), "newpass", now)
+	assertError(t, api.ErrInvalidResetToken, err)
+
+	reset, err := f.ResetPassword(f.name("reset"), "newpass", now)
+	assertNoError(t, err)
+	if reset != username {
+		t.Errorf("Expected the password of %s to be reset, got %q", username, reset)
+	}
+	_, err = f.ResetPassword(f.name("reset"), "again", now)
+	assertError(t, api.ErrInvalidResetToken, err)
+
+	_, err = f.UserLogin(tracker.LoginData{Username: username, Password: "testpass"})
+	assertError(t, api.ErrInvalidLoginDetails, err)
+	_, err = f.UserLogin(tracker.LoginData{Username: username, Password: "newpass"})
+	assertNoError(t, err)
+}
+
+func testEmailVerification(t *testing.T, f *fixture) {
+	username := f.user(t, "owner")
+	now := time.Now()
+	assertNoError(t, f.AddEmailVerificationToken(tracker.EmailVerificationToken{TokenHash: f.name("verify"), Username: username, ExpiresAt: now.Add(time.Hour)}))
+	assertNoError(t, f.AddEmailVerificationToken(tracker.EmailVerificationToken{TokenHash: f.name("expired"), Username: username, ExpiresAt: now.Add(-time.Minute)}))
+
+	_, err := f.VerifyEmail(f.name("expired"), now)
+	assertError(t, api.ErrInvalidVerificationToken, err)
+
+	verified, err := f.VerifyEmail(f.name("verify"), now)
+	assertNoError(t, err)
+	if verified != username {
+		t.Errorf("Expected the email of %s to be verified, got %q", username, verified)
+	}
+	_, err = f.VerifyEmail(f.name("verify"), now)
+	assertError(t, api.ErrInvalidVerificationToken, err)
+
+	login, _ := f.UserLogin(tracker.LoginData{Username: username, Password: "testpass"})
+	if !login.EmailVerified {
+		t.Errorf("Expected %s to be verified, got %+v", username, login)
+	}
+}
+
+func testLoginFailures(t *testing.T, f *fixture) {
+	// Failures are kept for usernames without an account as well
+	username := f.name("nobody")
+	failures, err := f.GetLoginFailures(username)
+	assertNoError(t, err)
+	if failures.Failures != 0 || failures.LockedUntil != nil {
+		t.Errorf("Expected no failures, got %+v", failures)
+	}
+
+	now := time.Now().Truncate(time.Second)
+	for expected := 1; expected <= 3; expected++ {
+		count, err := f.RecordLoginFailure(username, now.Add(time.Duration(expected)*time.Minute), time.Hour)
+		assertNoError(t, err)
+		if count != expected {
+			t.Errorf("Expected failure %d, got %d", expected, count)
+		}
+	}
+	until := time.Now().Add(time.Hour).Truncate(time.Second)
+	assertNoError(t, f.LockLogin(username, until))
+
+	failures, err = f.GetLoginFailures(username)
+	assertNoError(t, err)
+	if failures.Failures != 3 || failures.LockedUntil == nil || !failures.LockedUntil.Equal(until) {
+		t.Errorf("Expected 3 failures locked until %v, got %+v", until, failures)
+	}
+	count, _ := f.RecordLoginFailure(username, now.Add(59*time.Minute), time.Hour)
+	if count != 4 {
+		t.Errorf("Expected failures to keep counting while locked out, got %d", count)
+	}
+	count, _ = f.RecordLoginFailure(username, now.Add(time.Hour+time.Minute), time.Hour)
+	if count != 1 {
+		t.Errorf("Expected the failures to be counted anew after the window, got %d", count)
+	}
+	failures, _ = f.GetLoginFailures(username)
+	if failures.FirstFailureAt == nil || !failures.FirstFailureAt.Equal(now.Add(time.Hour+time.Minute)) {
+		t.Errorf("Expected a new window from %v, got %+v", now.Add(time.Hour+time.Minute), failures)
+	}
+
+	// Failures are pruned once their window and lockout ended
+	locked := f.name("locked")
+	f.RecordLoginFailure(locked, now, time.Hour)
+	assertNoError(t, f.LockLogin(locked, now.Add(3*time.Hour)))
+	_, err = f.PruneLoginFailures(now.Add(2*time.Hour), time.Hour)
+	assertNoError(t, err)
+	for _, kept := range []string{username, locked} {
+		if failures, _ := f.GetLoginFailures(kept); failures.Failures != 1 {
+			t.Errorf("Expected the failures of %s in their window or lockout to be kept, got %+v", kept, failures)
+		}
+	}
+	pruned, err := f.PruneLoginFailures(now.Add(3*time.Hour), time.Hour)
+	assertNoError(t, err)
+	if pruned < 2 {
+		t.Errorf("Expected the failures of both usernames to be pruned, got %d", pruned)
+	}
+	for _, gone := range []string{username, locked} {
+		if failures, _ := f.GetLoginFailures(gone); failures.Failures != 0 || failures.LockedUntil != nil {
+			t.Errorf("Expected the failures of %s to be pruned, got %+v", gone, failures)
+		}
+	}
+	f.RecordLoginFailure(username, now, time.Hour)
+
+	assertNoError(t, f.ResetLoginFailures(username))
+	failures, err = f.GetLoginFailures(username)
+	assertNoError(t, err)
+	if failures.Failures != 0 || failures.LockedUntil != nil {
+		t.Errorf("Expected the failures to be reset, got %+v", failures)
+	}
+}
+
+func testImport(t *testing.T, f *fixture) {
+	owner := f.user(t, "owner")
+	bench := f.exercise(t, "bench")
+	existing := f.plan(t, owner, bench)
+	startedAt := time.Date(2025, 6, 2, 7, 0, 0, 0, time.UTC)
+
+	t.Run("imports link sessions to the imported plans", func(t *testing.T) {
+		plans := []tracker.WorkoutPlan{
+			{Id: existing.Id, ExerciseName: strings.ToUpper(bench.Name), Repititions: 5, Sets: 5, Weight: 80},
+			{Id: 1000, ExerciseId: bench.Id, Repititions: 8, Sets: 3, Weight: 60},
+		}
+		planId := 1000
+		sessions := []tracker.WorkoutSession{
+			{PlanId: &planId, StartedAt: startedAt, Notes: "imported", Exercises: []tracker.SessionExercise{
+				{ExerciseName: bench.Name, Sets: []tracker.LoggedSet{{Reps: 8, Weight: 60}, {Reps: 8, Weight: 62.5}}},
+			}},
+			{StartedAt: startedAt.Add(time.Hour)},
+		}
+		assertNoError(t, f.ImportWorkoutData(owner, plans, sessions))
+
+		got := f.plans(t, owner)
+		if len(got) != 3 || got[1].Id == existing.Id || got[1].ExerciseName != bench.Name || got[2].Repititions != 8 {
+			t.Fatalf("Expected two more plans with new ids, got %+v", got)
+		}
+		list, err := f.GetWorkoutSessionList(owner)
+		assertNoError(t, err)
+		if len(list) != 2 {
+			t.Fatalf("Expected two imported sessions, got %+v", list)
+		}
+		session, err := f.GetWorkoutSession(owner, list[1].Id)
+		assertNoError(t, err)
+		if session.PlanId == nil || *session.PlanId != got[2].Id || session.Notes != "imported" || len(session.Exercises) != 1 || len(session.Exercises[0].Sets) != 2 {
+			t.Errorf("Expected the session to link plan %d, got %+v", got[2].Id, session)
+		}
+	})
+
+	t.Run("failed imports add nothing", func(t *testing.T) {
+		other := f.user(t, "other")
+		unknownPlan := 5
+		assertError(t, api.ErrWorkoutPlanNotFound, f.ImportWorkoutData(other,
+			[]tracker.WorkoutPlan{{Id: 1, ExerciseId: bench.Id}},
+			[]tracker.WorkoutSession{{StartedAt: startedAt}, {PlanId: &unknownPlan, StartedAt: startedAt}}))
+		assertError(t, api.ErrExerciseNotFound, f.ImportWorkoutData(other,
+			[]tracker.WorkoutPlan{{ExerciseId: bench.Id}},
+			[]tracker.WorkoutSession{{StartedAt: startedAt, Exercises: []tracker.SessionExercise{{ExerciseName: f.name("unknown")}}}}))
+		assertError(t, api.ErrExerciseNotFound, f.ImportWorkoutData(other,
+			[]tracker.WorkoutPlan{{ExerciseName: bench.Name}, {ExerciseId: -1}}, nil))
+
+		sessions, err := f.GetWorkoutSessionList(other)
+		assertNoError(t, err)
+		if plans := f.plans(t, other); len(plans) != 0 || len(sessions) != 0 {
+			t.Errorf("Expected nothing to be imported, got %+v and %+v", plans, sessions)
+		}
+	})
+}
+
+func testExerciseAliases(t *testing.T, f *fixture) {
+	owner := f.user(t, "owner")
+	other := f.user(t, "other")
+	bench := f.exercise(t, "bench")
+	squat := f.exercise(t, "squat")
+
+	t.Run("aliases are per owner and replaced ignoring case", func(t *testing.T) {
+		alias, err := f.SetExerciseAlias(owner, tracker.ExerciseAlias{Alias: " Bench Press (Barbell) ", ExerciseName: strings.ToUpper(bench.Name)})
+		assertNoError(t, err)
+		if alias != (tracker.ExerciseAlias{Alias: "bench press (barbell)", ExerciseId: bench.Id, ExerciseName: bench.Name}) {
+			t.Errorf("Expected the alias to be stored lower case for %s, got %+v", bench.Name, alias)
+		}
+		_, err = f.SetExerciseAlias(owner, tracker.ExerciseAlias{Alias: "BENCH PRESS (BARBELL)", ExerciseId: squat.Id})
+		assertNoError(t, err)
+		_, err = f.SetExerciseAlias(owner, tracker.ExerciseAlias{Alias: "Back Squat", ExerciseId: squat.Id})
+		assertNoError(t, err)
+
+		aliases, err := f.GetExerciseAliases(owner)
+		assertNoError(t, err)
+		if len(aliases) != 2 || aliases[0].Alias != "back squat" || aliases[1].ExerciseName != squat.Name {
+			t.Errorf("Expected both aliases for %s, got %+v", squat.Name, aliases)
+		}
+		aliases, err = f.GetExerciseAliases(other)
+		assertNoError(t, err)
+		if len(aliases) != 0 {
+			t.Errorf("Expected no aliases of another owner, got %+v", aliases)
+		}
+	})
+
+	t.Run("unknown exercises and aliases", func(t *testing.T) {
+		_, err := f.SetExerciseAlias(owner, tracker.ExerciseAlias{Alias: "deadlift", ExerciseName: f.name("unknown")})
+		assertError(t, api.ErrExerciseNotFound, err)
+		assertError(t, api.ErrExerciseAliasNotFound, f.DeleteExerciseAlias(other, "back squat"))
+		assertNoError(t, f.DeleteExerciseAlias(owner, "Back Squat"))
+		assertError(t, api.ErrExerciseAliasNotFound, f.DeleteExerciseAlias(owner, "back squat"))
+	})
+
+	t.Run("aliases are removed with their exercise", func(t *testing.T) {
+		curl := f.exercise(t, "curl")
+		_, err := f.SetExerciseAlias(other, tracker.ExerciseAlias{Alias: "bicep curl", ExerciseId: curl.Id})
+		assertNoError(t, err)
+		assertNoError(t, f.DeleteExercise(curl.Id))
+		aliases, err := f.GetExerciseAliases(other)
+		assertNoError(t, err)
+		if len(aliases) != 0 {
+			t.Errorf("Expected the alias to be removed, got %+v", aliases)
+		}
+	})
+}
@@ -0,0 +1,88 @@
+package tracker
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Oriseer/workout_tracker/config"
+)
+
+// testServer sends the requests of tests to a WorkoutServer.
+type testServer struct {
+	*WorkoutServer
+	remoteAddr string
+}
+
+func newTestServer(store WorkoutPlanStore) testServer {
+	return newTestServerWithConfig(store, testConfig)
+}
+
+func newTestServerWithConfig(store WorkoutPlanStore, cfg config.Config) testServer {
+	return testServer{WorkoutServer: NewWorkoutServer(store, cfg)}
+}
+
+// from returns a testServer that sends its requests from the client address.
+func (s testServer) from(ip string) testServer {
+	s.remoteAddr = ip + ":40000"
+	return s
+}
+
+// send serves a request with the bearer token, unless it is empty.
+func (s testServer) send(method, path, token, body string) *httptest.ResponseRecorder {
+	return s.sendContent(method, path, token, "", body)
+}
+
+// sendContent serves a request like send with the Content-Type, unless it
+// is empty.
+func (s testServer) sendContent(method, path, token, contentType, body string) *httptest.ResponseRecorder {
+	request, _ := http.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+	if s.remoteAddr != "" {
+		request.RemoteAddr = s.remoteAddr
+	}
+	response := httptest.NewRecorder()
+	s.ServeHTTP(response, request)
+	return response
+}
+
+// tryLogin serves a login of the user with the password.
+func (s testServer) tryLogin(username, password string) *httptest.ResponseRecorder {
+	return s.send(http.MethodPost, "/auth/login", "", `{"username": "`+username+`", "password": "`+password+`"}`)
+}
+
+// login logs the user in with the password "testpass" and returns its
+// access token.
+func (s testServer) login(t *testing.T, username string) string {
+	t.Helper()
+	return s.loginTokens(t, username).Token
+}
+
+// loginTokens logs the user in like login and returns its access and refresh
+// tokens.
+func (s testServer) loginTokens(t *testing.T, username string) Token {
+	t.Helper()
+	response := s.tryLogin(username, "testpass")
+	token := Token{}
+	json.NewDecoder(response.Body).Decode(&token)
+	if token.Token == "" {
+		t.Fatalf("Expected %s to log in, got status %d", username, response.Code)
+	}
+	return token
+}
+
+// register registers the user with the password "testpass" and the email
+// <username>@example.com, and logs it in.
+func (s testServer) register(t *testing.T, username string) string {
+	t.Helper()
+	response := s.send(http.MethodPost, "/auth/register", "", `{"username": "`+username+`", "password": "testpass", "email": "`+username+`@example.com"}`)
+	AssertResponseStatus(t, http.StatusCreated, response.Code)
+	return s.login(t, username)
+}
@@ -0,0 +1,162 @@
+package tracker
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/Oriseer/workout_tracker/api"
+)
+
+const (
+	defaultWorkoutPlanLimit = 50
+	maxWorkoutPlanLimit     = 200
+)
+
+// workoutPlanSortColumns maps the sort query parameter to the column of
+// WORKOUT_PLAN it orders by. Ties are broken by id.
+var workoutPlanSortColumns = map[string]string{
+	"id":            "id",
+	"exercise_name": "exercise_name",
+	"weight":        "weights",
+	"reps":          "repetitions",
+	"sets":          "sets",
+}
+
+// WorkoutPlanQuery filters, sorts and pages the workout plans of an owner.
+// Nil bounds do not filter. Plans come after the After cursor, ordered by
+// Sort and id, and at most Limit of them are returned.
+type WorkoutPlanQuery struct {
+	ExerciseName string
+	MinWeight    *int
+	MaxWeight    *int
+	MinReps      *int
+	MaxReps      *int
+	MinSets      *int
+	MaxSets      *int
+	Sort         string
+	Desc         bool
+	After        *WorkoutPlanCursor
+	Limit        int
+}
+
+// WorkoutPlanCursor is the position after the last plan of a page: its id
+// and the value of the sort field, Number for numeric fields and Text for
+// the exercise name. It is handed out base64 encoded and opaque to clients.
+type WorkoutPlanCursor struct {
+	Sort   string `json:"s"`
+	Desc   bool   `json:"d,omitempty"`
+	Id     int    `json:"i"`
+	Number int    `json:"n,omitempty"`
+	Text   string `json:"t,omitempty"`
+}
+
+// WorkoutPlanPage is a page of GET /workouts. NextCursor is empty on the
+// last page.
+type WorkoutPlanPage struct {
+	WorkoutPlans []WorkoutPlan `json:"workout_plans"`
+	NextCursor   string        `json:"next_cursor,omitempty"`
+}
+
+// parseWorkoutPlanQuery reads exercise_name, the min_ and max_ bounds of
+// weight, reps and sets, sort, order (asc or desc), limit and cursor.
+func parseWorkoutPlanQuery(values url.Values) (WorkoutPlanQuery, error) {
+	query := WorkoutPlanQuery{
+		ExerciseName: values.Get("exercise_name"),
+		Sort:         "id",
+		Limit:        defaultWorkoutPlanLimit,
+	}
+
+	bounds := []struct {
+		param string
+		bound **int
+	}{
+		{"min_weight", &query.MinWeight},
+		{"max_weight", &query.MaxWeight},
+		{"min_reps", &query.MinReps},
+		{"max_reps", &query.MaxReps},
+		{"min_sets", &query.MinSets},
+		{"max_sets", &query.MaxSets},
+	}
+	for _, b := range bounds {
+		value := values.Get(b.param)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return WorkoutPlanQuery{}, fmt.Errorf("%w: %s", api.ErrInvalidQuery, b.param)
+		}
+		*b.bound = &n
+	}
+
+	if sort := values.Get("sort"); sort != "" {
+		if _, ok := workoutPlanSortColumns[sort]; !ok {
+			return WorkoutPlanQuery{}, fmt.Errorf("%w: sort", api.ErrInvalidQuery)
+		}
+		query.Sort = sort
+	}
+	switch values.Get("order") {
+	case "", "asc":
+	case "desc":
+		query.Desc = true
+	default:
+		return WorkoutPlanQuery{}, fmt.Errorf("%w: order", api.ErrInvalidQuery)
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxWorkoutPlanLimit {
+			return WorkoutPlanQuery{}, fmt.Errorf("%w: limit must be between 1 and %d", api.ErrInvalidQuery, maxWorkoutPlanLimit)
+		}
+		query.Limit = n
+	}
+
+	if cursor := values.Get("cursor"); cursor != "" {
+		after, err := decodeWorkoutPlanCursor(cursor)
+		// A cursor only continues the listing in the order it was made for
+		if err != nil || after.Sort != query.Sort || after.Desc != query.Desc {
+			return WorkoutPlanQuery{}, api.ErrInvalidCursor
+		}
+		query.After = &after
+	}
+	return query, nil
+}
+
+// newWorkoutPlanCursor returns the cursor of the page that follows plan.
+func newWorkoutPlanCursor(query WorkoutPlanQuery, plan WorkoutPlan) WorkoutPlanCursor {
+	cursor := WorkoutPlanCursor{Sort: query.Sort, Desc: query.Desc, Id: plan.Id}
+	switch query.Sort {
+	case "exercise_name":
+		cursor.Text = plan.ExerciseName
+	case "weight":
+		cursor.Number = plan.Weight
+	case "reps":
+		cursor.Number = plan.Repititions
+	case "sets":
+		cursor.Number = plan.Sets
+	}
+	return cursor
+}
+
+func (c WorkoutPlanCursor) String() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeWorkoutPlanCursor(cursor string) (WorkoutPlanCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return WorkoutPlanCursor{}, err
+	}
+	decoded := WorkoutPlanCursor{}
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		return WorkoutPlanCursor{}, err
+	}
+	if _, ok := workoutPlanSortColumns[decoded.Sort]; !ok {
+		return WorkoutPlanCursor{}, api.ErrInvalidCursor
+	}
+	return decoded, nil
+}
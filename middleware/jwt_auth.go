@@ -2,61 +2,127 @@ package middleware
 
 import (
 	"context"
-	//	"fmt"
+	"math"
 	"net/http"
-	"os"
 	"strings"
+	"time"
 
 	"github.com/Oriseer/workout_tracker/api"
+	"github.com/Oriseer/workout_tracker/config"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var jwtKey = os.Getenv("JWT_KEY")
+type contextKey string
 
-type username string
+const claimsKey contextKey = "claims"
 
-func JwtAuth(next http.Handler) http.HandlerFunc {
+// TokenClaims are the claims of the access token that authenticated a request.
+type TokenClaims struct {
+	Username  string
+	Role      string
+	ID        string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// TokenTimePrecision is the precision of the iat claim of access tokens.
+// Revocations of all tokens issued before a time are kept at the same
+// precision, so a token issued right after a revocation is not revoked.
+const TokenTimePrecision = time.Microsecond
+
+// TokenRevocations reports whether an access token was revoked, either by
+// its id or because all tokens of the user issued before some time were.
+type TokenRevocations interface {
+	IsTokenRevoked(jti, username string, issuedAt time.Time) (bool, error)
+}
+
+// Claims returns the access token claims that JwtAuth stored in the request
+// context.
+func Claims(ctx context.Context) (TokenClaims, bool) {
+	claims, ok := ctx.Value(claimsKey).(TokenClaims)
+	return claims, ok
+}
+
+// Username returns the authenticated username that JwtAuth stored in the
+// request context.
+func Username(ctx context.Context) (string, bool) {
+	claims, ok := Claims(ctx)
+	return claims.Username, ok && claims.Username != ""
+}
+
+// JwtAuth returns middleware that requires a bearer token signed with the
+// key of cfg whose jti has not been revoked in revocations.
+func JwtAuth(cfg config.JWTConfig, revocations TokenRevocations) func(http.Handler) http.HandlerFunc {
+	return func(next http.Handler) http.HandlerFunc {
+		return jwtAuth(cfg.Key, revocations, next)
+	}
+}
+
+func jwtAuth(jwtKey string, revocations TokenRevocations, next http.Handler) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
 
 		authParts := strings.Split(authHeader, " ")
 		if authParts[0] != "Bearer" || len(authParts) != 2 {
-			api.StatusBadRequestServerError(w, api.ErrInvalidToken)
-			w.WriteHeader(http.StatusBadRequest)
+			api.UnauthorizedError(w, api.ErrInvalidToken)
 			return
 		}
 
+		// Only the algorithm access tokens are signed with is accepted, so a
+		// token cannot pick another one to be checked with
 		token, err := jwt.Parse(authParts[1], func(t *jwt.Token) (any, error) {
-			// if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			// 	w.WriteHeader(http.StatusBadRequest)
-			// 	return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
-			// }
-
 			return []byte(jwtKey), nil
-		})
+		}, jwt.WithValidMethods([]string{"HS256"}))
 
-		if err != nil {
-			api.StatusBadRequestServerError(w, err)
-			w.WriteHeader(http.StatusBadRequest)
+		// Expired tokens are answered with 401 like revoked ones, so that
+		// clients know to refresh them
+		if err != nil || !token.Valid {
+			api.UnauthorizedError(w, api.ErrInvalidExpredToken)
 			return
 		}
 
-		if !token.Valid {
-			api.StatusBadRequestServerError(w, api.ErrInvalidExpredToken)
-			w.WriteHeader(http.StatusBadRequest)
+		mapClaim, ok := token.Claims.(jwt.MapClaims)
+		if !ok {
+			api.UnauthorizedError(w, api.ErrInvalidTokenClaims)
 			return
 		}
 
-		if mapClaim, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			var username username
-			ctx := context.WithValue(r.Context(), username, mapClaim["username"])
-			r = r.WithContext(ctx)
-			next.ServeHTTP(w, r)
-		} else {
-			api.StatusBadRequestServerError(w, api.ErrInvalidTokenClaims)
-			w.WriteHeader(http.StatusBadRequest)
+		username, ok := mapClaim["username"].(string)
+		jti, jtiOk := mapClaim["jti"].(string)
+		// GetIssuedAt would truncate iat to whole seconds
+		iat, iatOk := mapClaim["iat"].(float64)
+		issuedAt := time.UnixMicro(int64(math.Round(iat * 1e6)))
+		expiresAt, expErr := mapClaim.GetExpirationTime()
+		if !ok || username == "" || !jtiOk || jti == "" || !iatOk || expErr != nil || expiresAt == nil {
+			api.UnauthorizedError(w, api.ErrInvalidTokenClaims)
 			return
 		}
 
+		revoked, err := revocations.IsTokenRevoked(jti, username, issuedAt)
+		if err != nil {
+			api.DatabaseError(w, err)
+			return
+		}
+		if revoked {
+			api.UnauthorizedError(w, api.ErrTokenRevoked)
+			return
+		}
+
+		// Tokens issued before roles existed belong to regular users
+		role, _ := mapClaim["role"].(string)
+		if role == "" {
+			role = RoleUser
+		}
+
+		claims := TokenClaims{
+			Username:  username,
+			Role:      role,
+			ID:        jti,
+			IssuedAt:  issuedAt,
+			ExpiresAt: expiresAt.Time,
+		}
+		setUsername(r.Context(), username)
+		ctx := context.WithValue(r.Context(), claimsKey, claims)
+		next.ServeHTTP(w, r.WithContext(ctx))
 	}
 }
@@ -0,0 +1,172 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Oriseer/workout_tracker/api"
+	"github.com/Oriseer/workout_tracker/config"
+)
+
+// RequestIDHeader carries the id of a request. An id sent by the client, for
+// example by a proxy in front of the server, is kept; otherwise one is made
+// up. The id is returned in the response either way.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds ids sent by clients so they cannot flood the log.
+const maxRequestIDLength = 128
+
+const requestKey contextKey = "request"
+
+// NewLogger returns a logger that writes the records at or above the level
+// of cfg to w as text or JSON. cfg must be valid.
+func NewLogger(cfg config.LogConfig, w io.Writer) *slog.Logger {
+	level, _ := cfg.SlogLevel()
+	options := &slog.HandlerOptions{Level: level}
+	if cfg.Format == config.LogJSON {
+		return slog.New(slog.NewJSONHandler(w, options))
+	}
+	return slog.New(slog.NewTextHandler(w, options))
+}
+
+// requestInfo is shared by AccessLog and the middleware and handlers it
+// wraps. JwtAuth fills in the username once it is known.
+type requestInfo struct {
+	logger *slog.Logger
+	id     string
+
+	mu       sync.Mutex
+	username string
+}
+
+// AccessLog returns middleware that logs every request to logger when it
+// is done: the method, path, status, response size, latency, request id
+// and, for authenticated requests, the username. Requests answered with an
+// error are logged with the error, server errors at the error level.
+func AccessLog(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			id := r.Header.Get(RequestIDHeader)
+			if id == "" || len(id) > maxRequestIDLength {
+				id = newRequestID()
+			}
+			w.Header().Set(RequestIDHeader, id)
+
+			info := &requestInfo{logger: logger.With("request_id", id), id: id}
+			recorder := &responseRecorder{ResponseWriter: w}
+			next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), requestKey, info)))
+
+			status := recorder.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			attrs := []slog.Attr{
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", status),
+				slog.Int64("bytes", recorder.bytes),
+				slog.Duration("latency", time.Since(start)),
+			}
+			if username := info.getUsername(); username != "" {
+				attrs = append(attrs, slog.String("username", username))
+			}
+			if recorder.err != nil {
+				attrs = append(attrs, slog.String("error", recorder.err.Error()))
+			}
+			level := slog.LevelInfo
+			if status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			info.logger.LogAttrs(r.Context(), level, "request", attrs...)
+		})
+	}
+}
+
+// Logger returns the logger of the request AccessLog stored in ctx, which
+// adds the request id and the username to every record. Outside of requests
+// it returns the default logger.
+func Logger(ctx context.Context) *slog.Logger {
+	info, ok := ctx.Value(requestKey).(*requestInfo)
+	if !ok {
+		return slog.Default()
+	}
+	if username := info.getUsername(); username != "" {
+		return info.logger.With("username", username)
+	}
+	return info.logger
+}
+
+// RequestID returns the id AccessLog gave the request of ctx.
+func RequestID(ctx context.Context) (string, bool) {
+	info, ok := ctx.Value(requestKey).(*requestInfo)
+	if !ok {
+		return "", false
+	}
+	return info.id, true
+}
+
+// setUsername records the authenticated user for the access log.
+func setUsername(ctx context.Context, username string) {
+	if info, ok := ctx.Value(requestKey).(*requestInfo); ok {
+		info.mu.Lock()
+		info.username = username
+		info.mu.Unlock()
+	}
+}
+
+func (info *requestInfo) getUsername() string {
+	info.mu.Lock()
+	defer info.mu.Unlock()
+	return info.username
+}
+
+func newRequestID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// responseRecorder keeps the status, size and error of a response. Like
+// net/http, only the first status written counts.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+	err    error
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// RecordError keeps err and passes it on to recorders further out.
+func (r *responseRecorder) RecordError(err error) {
+	r.err = err
+	if recorder, ok := r.ResponseWriter.(api.ErrorRecorder); ok {
+		recorder.RecordError(err)
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,148 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Oriseer/workout_tracker/api"
+	"github.com/Oriseer/workout_tracker/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type noRevocations struct{}
+
+func (noRevocations) IsTokenRevoked(string, string, time.Time) (bool, error) {
+	return false, nil
+}
+
+// logRecords decodes the JSON lines written by a logger.
+func logRecords(t *testing.T, output *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	decoder := json.NewDecoder(output)
+	for decoder.More() {
+		record := map[string]any{}
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestAccessLog(t *testing.T) {
+	newLogger := func(output *bytes.Buffer) func(http.Handler) http.Handler {
+		return AccessLog(NewLogger(config.LogConfig{Level: "debug", Format: config.LogJSON}, output))
+	}
+
+	t.Run("logs the request and returns its id", func(t *testing.T) {
+		output := &bytes.Buffer{}
+		handler := newLogger(output)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("created"))
+		}))
+
+		request := httptest.NewRequest(http.MethodPost, "/sessions", nil)
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+
+		records := logRecords(t, output)
+		if len(records) != 1 {
+			t.Fatalf("Expected 1 record, got %d", len(records))
+		}
+		record := records[0]
+		id := response.Header().Get(RequestIDHeader)
+		if id == "" || record["request_id"] != id {
+			t.Errorf("Expected request id %q in the log, got %v", id, record["request_id"])
+		}
+		if record["level"] != "INFO" || record["method"] != "POST" || record["path"] != "/sessions" || record["status"] != 201.0 || record["bytes"] != 7.0 {
+			t.Errorf("Expected an info record of the request, got %v", record)
+		}
+		if _, ok := record["latency"]; !ok {
+			t.Errorf("Expected the latency in the log, got %v", record)
+		}
+	})
+
+	t.Run("keeps request ids sent by clients", func(t *testing.T) {
+		output := &bytes.Buffer{}
+		handler := newLogger(output)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+		request := httptest.NewRequest(http.MethodGet, "/exercises", nil)
+		request.Header.Set(RequestIDHeader, "from-proxy")
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+
+		if got := response.Header().Get(RequestIDHeader); got != "from-proxy" {
+			t.Errorf("Expected from-proxy, got %q", got)
+		}
+		if record := logRecords(t, output)[0]; record["request_id"] != "from-proxy" || record["status"] != 200.0 {
+			t.Errorf("Expected the id of the client and status 200, got %v", record)
+		}
+	})
+
+	t.Run("logs server errors at the error level", func(t *testing.T) {
+		output := &bytes.Buffer{}
+		handler := newLogger(output)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			api.DatabaseError(w, errors.New("connection refused"))
+		}))
+
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/workouts", nil))
+
+		record := logRecords(t, output)[0]
+		if record["level"] != "ERROR" || record["status"] != 500.0 || record["error"] != "connection refused" {
+			t.Errorf("Expected an error record with the error, got %v", record)
+		}
+	})
+
+	t.Run("adds the authenticated user to the access log and handler logs", func(t *testing.T) {
+		output := &bytes.Buffer{}
+		auth := JwtAuth(config.JWTConfig{Key: "test-key"}, noRevocations{})
+		handler := newLogger(output)(auth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Logger(r.Context()).Warn("from the handler")
+		})))
+
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"username": "alice",
+			"jti":      "token-id",
+			"iat":      time.Now().Unix(),
+			"exp":      time.Now().Add(time.Minute).Unix(),
+		}).SignedString([]byte("test-key"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		request := httptest.NewRequest(http.MethodGet, "/workouts", nil)
+		request.Header.Set("Authorization", "Bearer "+token)
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+
+		records := logRecords(t, output)
+		if len(records) != 2 {
+			t.Fatalf("Expected 2 records, got %d", len(records))
+		}
+		id := response.Header().Get(RequestIDHeader)
+		for _, record := range records {
+			if record["username"] != "alice" || record["request_id"] != id {
+				t.Errorf("Expected the user and request id in %v", record)
+			}
+		}
+		if records[0]["msg"] != "from the handler" || records[0]["level"] != "WARN" {
+			t.Errorf("Expected the record of the handler first, got %v", records[0])
+		}
+	})
+
+	t.Run("leaves out records below the configured level", func(t *testing.T) {
+		output := &bytes.Buffer{}
+		logger := NewLogger(config.LogConfig{Level: "warn", Format: config.LogText}, output)
+		AccessLog(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).
+			ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/exercises", nil))
+
+		if output.Len() != 0 {
+			t.Errorf("Expected no info records, got %q", output.String())
+		}
+	})
+}
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// unmatchedRoute labels requests no route matched, so that scans of random
+// paths do not create a time series each.
+const unmatchedRoute = "unmatched"
+
+// RequestMetrics returns middleware that counts requests and observes their
+// latency by method, route and status in metrics registered with
+// registerer. The route is the pattern of the http.ServeMux that next must
+// be, /exercises/{id} rather than /exercises/7.
+func RequestMetrics(registerer prometheus.Registerer) func(http.Handler) http.Handler {
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by method, route and status.",
+	}, []string{"method", "route", "status"})
+	duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Latency of HTTP requests by method, route and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+	registerer.MustRegister(requests, duration)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			recorder := &responseRecorder{ResponseWriter: w}
+			next.ServeHTTP(recorder, r)
+
+			status := recorder.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			labels := prometheus.Labels{
+				"method": r.Method,
+				"route":  route(r.Pattern),
+				"status": strconv.Itoa(status),
+			}
+			requests.With(labels).Inc()
+			duration.With(labels).Observe(time.Since(start).Seconds())
+		})
+	}
+}
+
+// route drops the method from a pattern such as GET /exercises/{id}, the
+// method is a label of its own.
+func route(pattern string) string {
+	if pattern == "" {
+		return unmatchedRoute
+	}
+	if _, path, ok := strings.Cut(pattern, " "); ok {
+		return path
+	}
+	return pattern
+}
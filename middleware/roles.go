@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/Oriseer/workout_tracker/api"
+)
+
+// Roles a user can have. Every account starts as RoleUser. RoleCoach is
+// reserved: no route requires it yet, so coaches can do what users can.
+const (
+	RoleUser  = "user"
+	RoleCoach = "coach"
+	RoleAdmin = "admin"
+)
+
+// Roles lists the valid roles.
+var Roles = []string{RoleUser, RoleCoach, RoleAdmin}
+
+// RequireRole returns middleware that only lets through users whose token
+// carries one of roles. It must be wrapped by JwtAuth.
+func RequireRole(roles ...string) func(http.Handler) http.HandlerFunc {
+	return func(next http.Handler) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := Claims(r.Context())
+			if !ok || !slices.Contains(roles, claims.Role) {
+				api.ForbiddenError(w, api.ErrForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+	}
+}